@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/scaffold"
+	"github.com/babarot/afx/internal/state"
+)
+
+type addCmd struct {
+	metaCmd
+
+	opt addOpt
+}
+
+type addOpt struct {
+	file    string
+	name    string
+	install bool
+}
+
+var (
+	// addLong is long description of add command
+	addLong = templates.LongDesc(`
+		Add a package entry to config from a URL or owner/repo.
+
+		GitHub repositories whose latest release has an asset for your
+		platform are added as release binaries, with an asset filename
+		templated with OS and arch. Others are looked into to find plugins
+		or commands. Gist pages and files on any websites (HTTP) can be
+		added as well. Commands to link are proposed from the downloaded
+		files, so it's worth reviewing the added entry.
+
+		The entry is appended to a config file keeping its comments.
+		`)
+
+	// addExample is examples for add command
+	addExample = templates.Examples(`
+		$ afx add junegunn/fzf
+		$ afx add github.com/zsh-users/zsh-autosuggestions --file plugins.yaml
+		$ afx add https://gist.github.com/babarot/bb820b99fdba605ea4bd4fb29046ce58
+		$ afx add https://example.com/tool_linux_amd64.tar.gz --name tool --install
+	`)
+)
+
+// newAddCmd creates a new add command
+func (m metaCmd) newAddCmd() *cobra.Command {
+	c := &addCmd{metaCmd: m}
+
+	addCmd := &cobra.Command{
+		Use:                   "add <url|owner/repo>",
+		Short:                 "Add a package to config from a URL",
+		Long:                  addLong,
+		Example:               addExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.run(github.NewContext(context.Background(), c.githubOpts...), args[0])
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			return m.printForUpdate()
+		},
+	}
+
+	flag := addCmd.Flags()
+	flag.StringVarP(&c.opt.file, "file", "f", "", "Config file to add the package to (relative to the config directory)")
+	flag.StringVarP(&c.opt.name, "name", "", "", "Package name instead of the guessed one")
+	flag.BoolVarP(&c.opt.install, "install", "", false, "Install the package after added")
+
+	return addCmd
+}
+
+func (c *addCmd) run(ctx context.Context, arg string) error {
+	target, err := scaffold.Parse(arg, c.main.GitHubHost)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Looking into %s...\n", arg)
+	entry, err := scaffold.Guess(ctx, target,
+		scaffold.WithName(c.opt.name),
+		scaffold.WithGitHubHost(c.main.GitHubHost),
+		scaffold.WithCache(c.cache),
+	)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range c.packages {
+		if pkg.GetName() == entry.Name() {
+			return fmt.Errorf("%s: already exists in config, use --name to add it with another name", entry.Name())
+		}
+	}
+
+	file, err := c.configFile()
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	out, err := scaffold.Append(src, entry)
+	if err != nil {
+		return fmt.Errorf("%s: failed to add package: %w", file, err)
+	}
+	if _, err := manager.Decode(bytes.NewReader(out)); err != nil {
+		return fmt.Errorf("%s: added package is invalid: %w", entry.Name(), err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, out, 0644); err != nil {
+		return err
+	}
+
+	b, err := entry.Marshal()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%s\n", b)
+	fmt.Println(color.WhiteString("Added %s to %s", entry.Name(), file))
+
+	if !c.opt.install {
+		return nil
+	}
+	return c.install(entry.Name())
+}
+
+// configFile returns a config file to add a package to.
+// It's chosen from existing ones if not given
+func (c *addCmd) configFile() (string, error) {
+	if c.opt.file != "" {
+		file := c.opt.file
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(manager.ConfigDir(), file)
+		}
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml":
+			return file, nil
+		default:
+			return "", fmt.Errorf("%s: config file should be YAML (.yaml or .yml)", c.opt.file)
+		}
+	}
+
+	var files []string
+	for file := range c.configs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	switch len(files) {
+	case 0:
+		return filepath.Join(manager.ConfigDir(), "main.yaml"), nil
+	case 1:
+		return files[0], nil
+	}
+	selected, err := c.choose(&survey.Select{
+		Message: "Choose a config file to add the package to:",
+		Options: files,
+	})
+	if errors.Is(err, errNoInput) {
+		return "", fmt.Errorf("%d config files found, specify one with --file", len(files))
+	}
+	return selected, err
+}
+
+// install installs the added package after reloading config
+func (c *addCmd) install(name string) error {
+	if err := c.reload(); err != nil {
+		return err
+	}
+	defer c.state.Close()
+
+	resource, ok := state.Map(c.state.Additions)[name]
+	if !ok {
+		fmt.Printf("%s: already installed\n", name)
+		return nil
+	}
+	pkgs := c.GetPackages([]state.Resource{resource})
+	if err := c.env.AskWhen(map[string]bool{
+		"GITHUB_TOKEN":      manager.HasGitHubReleaseBlock(pkgs),
+		"AFX_SUDO_PASSWORD": manager.HasSudoInCommandBuildSteps(pkgs),
+	}); err != nil {
+		return err
+	}
+
+	install := &installCmd{metaCmd: c.metaCmd}
+	return install.run(pkgs)
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/helpers/templates"
+	manager "github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/plan"
+	"github.com/babarot/afx/internal/runner"
+)
+
+type applyCmd struct {
+	metaCmd
+
+	opt applyOpt
+}
+
+type applyOpt struct {
+	parallel int
+}
+
+var (
+	// applyLong is long description of apply command
+	applyLong = templates.LongDesc(`
+		Install, reinstall, update and uninstall packages at once
+		so that they match the config file.
+	`)
+
+	// applyExample is examples for apply command
+	applyExample = templates.Examples(`
+		afx apply
+
+		Makes a plan (same as afx plan) and performs it after confirmation.
+
+		afx apply afx.plan
+
+		Performs the plan saved by "afx plan --out afx.plan".
+		It fails if the state or the config has been changed since then.
+	`)
+)
+
+// newApplyCmd creates a new apply command
+func (m metaCmd) newApplyCmd() *cobra.Command {
+	c := &applyCmd{metaCmd: m}
+
+	applyCmd := &cobra.Command{
+		Use:                   "apply [plan-file]",
+		Short:                 "Apply changes required by the current config",
+		Long:                  applyLong,
+		Example:               applyExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateParallel(c.opt.parallel); err != nil {
+				return err
+			}
+			// resolved before desired resources are taken, as packages get resolved versions
+			resolved := c.resolveChanges(context.Background())
+			p := plan.New(c.state, c.GetResources(), resolved)
+			if len(args) > 0 {
+				saved, err := plan.Read(args[0])
+				if err != nil {
+					return err
+				}
+				if err := saved.Validate(c.state, c.GetResources()); err != nil {
+					return fmt.Errorf("%s: %w", args[0], err)
+				}
+				p = saved
+			}
+
+			p.Render(os.Stdout)
+			if p.Empty() {
+				return nil
+			}
+			fmt.Println()
+
+			yes, err := m.askRunCommand(*c, p.Names())
+			if err != nil {
+				return fmt.Errorf("failed to confirm: %w", err)
+			}
+			if !yes {
+				fmt.Println("Canceled")
+				return nil
+			}
+
+			return c.run(p)
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			return m.printForUpdate()
+		},
+	}
+
+	addParallelFlag(applyCmd, &c.opt.parallel)
+
+	return applyCmd
+}
+
+func (c *applyCmd) run(p *plan.Plan) error {
+	log.Printf("[DEBUG] (apply): start to apply %d actions", len(p.Actions))
+
+	c.seedLock(context.Background())
+
+	var errs []error
+	results := map[string]error{}
+
+	ops := map[string]plan.Op{}
+	for _, action := range p.Filter(plan.Install, plan.Reinstall, plan.Update) {
+		ops[action.Name] = action.Op
+	}
+	var pkgs []manager.Package
+	for _, pkg := range c.packages {
+		if _, ok := ops[pkg.GetName()]; ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	// inputs are asked before anything is changed
+	if err := c.env.AskWhen(map[string]bool{
+		"GITHUB_TOKEN":      manager.HasGitHubReleaseBlock(pkgs),
+		"AFX_SUDO_PASSWORD": manager.HasSudoInCommandBuildSteps(pkgs),
+	}); err != nil {
+		return err
+	}
+
+	for _, action := range p.Filter(plan.Uninstall) {
+		err := c.uninstallResource(action.Resource)
+		results[action.Name] = err
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to uninstall: %w", action.Name, err))
+			continue
+		}
+		fmt.Printf("deleted %s\n", action.Resource.Home)
+	}
+
+	if len(pkgs) > 0 {
+		runnerPkgs := make([]runner.Package, len(pkgs))
+		for i, pkg := range pkgs {
+			runnerPkgs[i] = pkg
+		}
+
+		var mu sync.Mutex
+		err := runner.Execute(runnerPkgs, func(p runner.Package) runner.TaskFunc {
+			pkg, _ := p.(manager.Package)
+			task := c.installTask(pkg)
+			if ops[pkg.GetName()] == plan.Update {
+				task = c.updateTask(pkg)
+			}
+			return func(ctx context.Context, completion chan<- runner.Status) error {
+				err := task(ctx, completion)
+				mu.Lock()
+				results[pkg.GetName()] = err
+				mu.Unlock()
+				return err
+			}
+		}, c.concurrency(c.opt.parallel))
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if saveErr := c.lock.Save(); saveErr != nil {
+		log.Printf("[ERROR] failed to save lock file: %v", saveErr)
+	}
+
+	fmt.Println()
+	fmt.Println(summarize(p, results))
+
+	err := errors.Join(errs...)
+	if err != nil {
+		_ = c.env.Refresh()
+	}
+	return err
+}
+
+// summarize returns a summary of the applied plan.
+// Packages without results are ones skipped due to failed dependencies.
+func summarize(p *plan.Plan, results map[string]error) string {
+	done := map[plan.Op]int{}
+	failed, skipped := 0, 0
+	for _, action := range p.Actions {
+		err, ok := results[action.Name]
+		switch {
+		case !ok:
+			skipped++
+		case err != nil:
+			failed++
+		default:
+			done[action.Op]++
+		}
+	}
+
+	summary := fmt.Sprintf("%d installed, %d reinstalled, %d updated, %d uninstalled",
+		done[plan.Install], done[plan.Reinstall], done[plan.Update], done[plan.Uninstall])
+	if failed+skipped == 0 {
+		return color.GreenString("Apply complete! ") + summary + "."
+	}
+
+	var problems []string
+	if failed > 0 {
+		problems = append(problems, fmt.Sprintf("%d failed", failed))
+	}
+	if skipped > 0 {
+		problems = append(problems, fmt.Sprintf("%d skipped", skipped))
+	}
+	return color.RedString("Apply incomplete! ") + summary + ", " + strings.Join(problems, ", ") + "."
+}
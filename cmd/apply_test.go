@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fatih/color"
+
+	"github.com/babarot/afx/internal/plan"
+)
+
+func TestSummarize(t *testing.T) {
+	color.NoColor = true
+
+	p := &plan.Plan{Actions: []plan.Action{
+		{Op: plan.Uninstall, Name: "old"},
+		{Op: plan.Install, Name: "base"},
+		{Op: plan.Install, Name: "app"},
+		{Op: plan.Update, Name: "tool"},
+	}}
+
+	tests := map[string]struct {
+		results map[string]error
+		want    string
+	}{
+		"complete": {
+			results: map[string]error{"old": nil, "base": nil, "app": nil, "tool": nil},
+			want:    "Apply complete! 2 installed, 0 reinstalled, 1 updated, 1 uninstalled.",
+		},
+		"failed and skipped": {
+			results: map[string]error{"old": nil, "base": errors.New("failed"), "tool": nil},
+			want:    "Apply incomplete! 0 installed, 0 reinstalled, 1 updated, 1 uninstalled, 1 failed, 1 skipped.",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := summarize(p, tt.results); got != tt.want {
+				t.Errorf("summarize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
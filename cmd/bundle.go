@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/bundle"
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/git"
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
+)
+
+type bundleCmd struct {
+	metaCmd
+
+	opt bundleOpt
+}
+
+type bundleOpt struct {
+	force bool
+}
+
+var (
+	// bundleLong is long description of bundle command
+	bundleLong = templates.LongDesc(`
+		Export and import packages for machines without network access.
+
+		"afx bundle create" packs config files, afx.lock, release assets and
+		HTTP artifacts recorded in afx.lock, and git bundles of cloned
+		repositories into a single file. Packages should be installed
+		before creating a bundle.
+
+		"afx bundle install" copies config files and afx.lock in the bundle
+		to the config directory, and installs packages from the bundle as
+		"afx install --locked" does, without any network access.
+		`)
+
+	// bundleExample is examples for bundle command
+	bundleExample = templates.Examples(`
+		$ afx bundle create out.tar.zst
+		$ afx bundle install out.tar.zst
+	`)
+)
+
+// newBundleCmd creates a new bundle command
+func (m metaCmd) newBundleCmd() *cobra.Command {
+	c := &bundleCmd{metaCmd: m}
+
+	bundleCmd := &cobra.Command{
+		Use:                   "bundle [create|install]",
+		Short:                 "Export and import packages for offline machines",
+		Long:                  bundleLong,
+		Example:               bundleExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MaximumNArgs(1),
+	}
+
+	bundleCmd.AddCommand(
+		c.newBundleCreateCmd(),
+		c.newBundleInstallCmd(),
+	)
+
+	return bundleCmd
+}
+
+func (c *bundleCmd) newBundleCreateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "create <file>",
+		Short:                 "Create a bundle of installed packages",
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(1),
+		Annotations:           map[string]string{annotationStateLock: "shared"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := c.create(context.Background(), args[0]); err != nil {
+				return err
+			}
+			fmt.Println(color.WhiteString("Successfully created %s", args[0]))
+			return nil
+		},
+	}
+}
+
+func (c *bundleCmd) newBundleInstallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "install <file>",
+		Short:                 "Install packages from a bundle without network access",
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.install(context.Background(), args[0])
+		},
+	}
+	cmd.Flags().BoolVarP(&c.opt.force, "force", "", false, "Overwrite config files and afx.lock which differ from the bundle")
+	return cmd
+}
+
+// create packs config files, afx.lock and what is recorded in afx.lock into a bundle
+func (c *bundleCmd) create(ctx context.Context, out string) error {
+	dir, err := os.MkdirTemp("", "afx-bundle")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	root := manager.ConfigDir()
+	for file := range c.configs {
+		rel, err := filepath.Rel(root, file)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			rel = filepath.Base(file)
+		}
+		if err := copyFile(file, filepath.Join(dir, bundle.ConfigDir, rel)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(manager.LockFile()); err != nil {
+		return fmt.Errorf("%s: lock file not found, please run afx install first: %w", manager.LockFile(), err)
+	}
+	if err := copyFile(manager.LockFile(), filepath.Join(dir, bundle.LockFile)); err != nil {
+		return err
+	}
+
+	manifest := bundle.Manifest{
+		CreatedAt:    time.Now(),
+		Repositories: map[string]string{},
+	}
+	files := cache.New(filepath.Join(dir, bundle.CacheDir), cache.WithMaxSize(0))
+	gitCmd := git.NewRunner()
+
+	var errs []error
+	for _, pkg := range c.packages {
+		if pkg.GetResource().Type == "Local" {
+			continue
+		}
+		if gh, isGH := pkg.(*manager.GitHub); isGH && gh.IsGHExtension() {
+			log.Printf("[WARN] %s: gh extensions cannot be bundled, skip", pkg.GetName())
+			continue
+		}
+		locked, ok := c.lock.Get(pkg.GetName())
+
+		if cloner, isCloner := pkg.(manager.Cloner); isCloner && cloner.CloneURL() != "" {
+			if !ok || locked.Commit == "" {
+				errs = append(errs, fmt.Errorf("%s: not found in lock file", pkg.GetName()))
+				continue
+			}
+			name := path.Join(bundle.GitDir, fmt.Sprintf("%d.bundle", len(manifest.Repositories)))
+			if err := os.MkdirAll(filepath.Join(dir, bundle.GitDir), 0755); err != nil {
+				return err
+			}
+			if err := gitCmd.Bundle(ctx, pkg.GetHome(), filepath.Join(dir, name)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: failed to create git bundle: %w", pkg.GetName(), err))
+				continue
+			}
+			manifest.Repositories[cloner.CloneURL()] = name
+			continue
+		}
+
+		switch {
+		case !ok:
+			err = errors.New("not found in lock file")
+		case locked.Asset != nil:
+			err = c.bundleFile(ctx, files, locked.Asset.URL, locked.Asset.SHA256)
+		case locked.URL != "":
+			err = c.bundleFile(ctx, files, locked.URL, locked.SHA256)
+		default:
+			log.Printf("[WARN] %s: nothing to bundle", pkg.GetName())
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", pkg.GetName(), err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	return bundle.Pack(ctx, dir, manifest, out)
+}
+
+// bundleFile copies a file recorded in lock file into the bundle.
+// It's taken from the download cache if possible
+func (c *bundleCmd) bundleFile(ctx context.Context, files *cache.Cache, url, digest string) error {
+	r, w := io.Pipe()
+	go func() {
+		_, err := c.cache.Fetch(ctx, url, w, cache.WithDigest(digest))
+		w.CloseWithError(err)
+	}()
+	entry, err := files.Add(url, r)
+	r.CloseWithError(err)
+	if err != nil {
+		return err
+	}
+	if entry.SHA256 != digest {
+		return fmt.Errorf("%s: sha256 drifted from lock file (locked: %q, got: %q)", url, digest, entry.SHA256)
+	}
+	return nil
+}
+
+// install installs packages from a bundle going through the same way as
+// "afx install --locked", with git repositories and downloads redirected to the bundle
+func (c *bundleCmd) install(ctx context.Context, file string) error {
+	dir, err := os.MkdirTemp("", "afx-bundle")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := bundle.Unpack(ctx, file, dir)
+	if err != nil {
+		return err
+	}
+
+	if err := c.installConfigs(dir); err != nil {
+		return err
+	}
+
+	// reload config files and afx.lock copied from the bundle
+	if err := c.reload(); err != nil {
+		return err
+	}
+	defer c.state.Close()
+
+	c.gitBundles = manifest.GitBundles(dir)
+	c.cache = cache.New(filepath.Join(dir, bundle.CacheDir), cache.WithOffline(), cache.WithMaxSize(0))
+
+	resources := c.state.Additions
+	if len(resources) == 0 {
+		fmt.Println("No packages to install")
+		return nil
+	}
+
+	install := &installCmd{metaCmd: c.metaCmd, opt: installOpt{locked: true}}
+	return install.run(c.GetPackages(resources))
+}
+
+// installConfigs copies config files and afx.lock in the bundle to the config directory.
+// Existing files are not overwritten if they differ unless forced
+func (c *bundleCmd) installConfigs(dir string) error {
+	type copying struct{ src, dst string }
+	var files []copying
+
+	root := filepath.Join(dir, bundle.ConfigDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, copying{path, filepath.Join(manager.ConfigDir(), rel)})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	files = append(files, copying{filepath.Join(dir, bundle.LockFile), manager.LockFile()})
+
+	var errs []error
+	for _, f := range files {
+		if c.opt.force {
+			continue
+		}
+		existing, err := os.ReadFile(f.dst)
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(f.src)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(existing, content) {
+			errs = append(errs, fmt.Errorf("%s: already exists and differs from the bundle (use --force to overwrite)", f.dst))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := copyFile(f.src, f.dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
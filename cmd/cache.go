@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/printers"
+)
+
+type cacheCmd struct {
+	metaCmd
+
+	opt cacheOpt
+}
+
+type cacheOpt struct {
+	maxSize string
+}
+
+var (
+	// cacheLong is long description of cache command
+	cacheLong = templates.LongDesc(`
+		Manage the download cache shared across packages and updates.
+		Release assets and files of HTTP packages are cached by their URLs
+		and digests, so reinstalling them doesn't need to download again.
+		`)
+
+	// cacheExample is examples for cache command
+	cacheExample = templates.Examples(`
+		$ afx cache list
+		$ afx cache prune --max-size 500MB
+		$ afx cache clear
+	`)
+)
+
+// newCacheCmd creates a new cache command
+func (m metaCmd) newCacheCmd() *cobra.Command {
+	c := &cacheCmd{metaCmd: m}
+
+	cacheCmd := &cobra.Command{
+		Use:                   "cache [list|prune|clear]",
+		Short:                 "Manage the download cache",
+		Long:                  cacheLong,
+		Example:               cacheExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MaximumNArgs(1),
+	}
+
+	cacheCmd.AddCommand(
+		c.newCacheListCmd(),
+		c.newCachePruneCmd(),
+		c.newCacheClearCmd(),
+	)
+
+	return cacheCmd
+}
+
+func (c *cacheCmd) newCacheListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "list",
+		Short:                 "List cached files",
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Aliases:               []string{"ls"},
+		Args:                  cobra.ExactArgs(0),
+		Annotations:           map[string]string{annotationStateLock: "none"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := c.cache.List()
+			if err != nil {
+				return fmt.Errorf("failed to list cache: %w", err)
+			}
+			w := printers.GetNewTabWriter(os.Stdout)
+			fmt.Fprintf(w, "%s\n", strings.Join([]string{"URL", "SIZE", "SHA256", "LAST USED"}, "\t"))
+			var total int64
+			for _, entry := range entries {
+				fmt.Fprintf(w, "%s\n", strings.Join([]string{
+					entry.URL,
+					cache.FormatSize(entry.Size),
+					entry.SHA256[:12],
+					entry.UsedAt.Local().Format("2006-01-02 15:04"),
+				}, "\t"))
+				total += entry.Size
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Printf("\n%d files, %s in %s\n", len(entries), cache.FormatSize(total), c.cache.Dir())
+			return nil
+		},
+	}
+}
+
+func (c *cacheCmd) newCachePruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "prune",
+		Short:                 "Evict least recently used files to fit in the size limit",
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			maxSize, err := c.main.CacheMaxSize()
+			if err != nil {
+				return err
+			}
+			if c.opt.maxSize != "" {
+				maxSize, err = cache.ParseSize(c.opt.maxSize)
+				if err != nil {
+					return err
+				}
+			}
+			evicted, err := c.cache.Prune(maxSize)
+			if err != nil {
+				return fmt.Errorf("failed to prune cache: %w", err)
+			}
+			var total int64
+			for _, entry := range evicted {
+				total += entry.Size
+			}
+			fmt.Println(color.WhiteString("Pruned %d files (%s)", len(evicted), cache.FormatSize(total)))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&c.opt.maxSize, "max-size", "", "", "Size limit of the cache (e.g. 500MB, default: cache_size in config)")
+	return cmd
+}
+
+func (c *cacheCmd) newCacheClearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "clear",
+		Short:                 "Delete all cached files",
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := c.cache.Clear(); err != nil {
+				return fmt.Errorf("failed to clear cache: %w", err)
+			}
+			fmt.Println(color.WhiteString("Successfully cleared"))
+			return nil
+		},
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/babarot/afx/internal/github"
 	"github.com/babarot/afx/internal/helpers/templates"
 	manager "github.com/babarot/afx/internal/manager"
 	"github.com/babarot/afx/internal/runner"
@@ -15,6 +16,13 @@ import (
 
 type checkCmd struct {
 	metaCmd
+
+	opt checkOpt
+}
+
+type checkOpt struct {
+	output   string
+	parallel int
 }
 
 var (
@@ -28,6 +36,12 @@ var (
 		By default, it tries to check packages if new version is
 		available or not.
 		If any args are given, it tries to check only them.
+
+		afx check -o json
+
+		Writes events of each package as NDJSON instead of
+		printing progress. A done event has "old_version" and
+		"new_version" if the latest release is found.
 	`)
 )
 
@@ -44,12 +58,19 @@ func (m metaCmd) newCheckCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		SilenceUsage:          true,
 		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "shared"},
 		Args:                  cobra.MinimumNArgs(0),
 		ValidArgs:             state.Keys(m.state.NoChanges),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(c.opt.output); err != nil {
+				return err
+			}
+			if err := validateParallel(c.opt.parallel); err != nil {
+				return err
+			}
 			resources := m.state.NoChanges
 			if len(resources) == 0 {
-				fmt.Println("No packages to check")
+				fmt.Fprintln(messages(c.opt.output), "No packages to check")
 				return nil
 			}
 
@@ -70,26 +91,31 @@ func (m metaCmd) newCheckCmd() *cobra.Command {
 				return fmt.Errorf("failed to confirm: %w", err)
 			}
 			if !yes {
-				fmt.Println("Canceled")
+				fmt.Fprintln(messages(c.opt.output), "Canceled")
 				return nil
 			}
 
 			pkgs := m.GetPackages(resources)
-			m.env.AskWhen(map[string]bool{
+			if err := m.env.AskWhen(map[string]bool{
 				"GITHUB_TOKEN": manager.HasGitHubReleaseBlock(pkgs),
-			})
+			}); err != nil {
+				return err
+			}
 
-			return c.run(pkgs)
+			return c.run(pkgs, resources)
 		},
 		PostRunE: func(cmd *cobra.Command, args []string) error {
 			return m.printForUpdate()
 		},
 	}
 
+	addOutputFlag(checkCmd, &c.opt.output)
+	addParallelFlag(checkCmd, &c.opt.parallel)
+
 	return checkCmd
 }
 
-func (c *checkCmd) run(pkgs []manager.Package) error {
+func (c *checkCmd) run(pkgs []manager.Package, resources []state.Resource) error {
 	log.Printf("[DEBUG] (check): start to run each pkg.Check()")
 
 	runnerPkgs := make([]runner.Package, len(pkgs))
@@ -97,12 +123,13 @@ func (c *checkCmd) run(pkgs []manager.Package) error {
 		runnerPkgs[i] = p
 	}
 
+	events := c.newEvents(c.opt.output, resources, false)
 	err := runner.Execute(runnerPkgs, func(p runner.Package) runner.TaskFunc {
 		pkg, _ := p.(manager.Package)
 		return func(ctx context.Context, completion chan<- runner.Status) error {
-			return pkg.Check(ctx, completion)
+			return pkg.Check(github.NewContext(ctx, c.githubOpts...), completion)
 		}
-	})
+	}, runner.WithEvents(events), c.concurrency(c.opt.parallel))
 
 	if err != nil {
 		_ = c.env.Refresh()
@@ -58,6 +58,7 @@ func (m metaCmd) newCompletionCmd() *cobra.Command {
 		SilenceErrors:         true,
 		ValidArgs:             []string{"bash", "zsh", "fish"},
 		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Annotations:           map[string]string{annotationStateAsIs: "true", annotationStateLock: "none"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			switch args[0] {
 			case "bash":
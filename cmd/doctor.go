@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/doctor"
+	"github.com/babarot/afx/internal/gh"
+	"github.com/babarot/afx/internal/git"
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/printers"
+	"github.com/babarot/afx/internal/state"
+)
+
+type doctorCmd struct {
+	metaCmd
+
+	opt doctorOpt
+}
+
+type doctorOpt struct {
+	fix bool
+}
+
+var (
+	// doctorLong is long description of doctor command
+	doctorLong = templates.LongDesc(`
+		Diagnose the environment and installed packages.
+
+		It checks if AFX_COMMAND_PATH is on PATH, git and gh are installed,
+		packages recorded in state exist, symlinks in AFX_COMMAND_PATH are
+		valid, state is not locked by a killed process, command names are
+		not duplicated across packages, and GitHub tokens are valid.
+
+		With --fix, problems which can be repaired safely are fixed, that is
+		removing dangling symlinks and stale lock files, and making linked
+		commands executable. Files not managed by afx are never changed.
+		`)
+
+	// doctorExample is examples for doctor command
+	doctorExample = templates.Examples(`
+		$ afx doctor
+		$ afx doctor --fix
+	`)
+)
+
+// newDoctorCmd creates a new doctor command
+func (m metaCmd) newDoctorCmd() *cobra.Command {
+	c := &doctorCmd{metaCmd: m}
+
+	doctorCmd := &cobra.Command{
+		Use:                   "doctor",
+		Short:                 "Diagnose the environment and installed packages",
+		Long:                  doctorLong,
+		Example:               doctorExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(0),
+		Annotations:           map[string]string{annotationStateAsIs: "true", annotationStateLock: "shared"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.run(context.Background())
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			return m.printForUpdate()
+		},
+	}
+
+	doctorCmd.Flags().BoolVarP(&c.opt.fix, "fix", "", false, "Fix problems which can be repaired safely")
+
+	return doctorCmd
+}
+
+func (c *doctorCmd) run(ctx context.Context) error {
+	var resources []state.Resource
+	if c.stateErr == nil {
+		var err error
+		resources, err = c.state.List()
+		if err != nil {
+			return fmt.Errorf("failed to list state: %w", err)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Name < resources[j].Name
+	})
+
+	// the lock held by this process is released so as not to be
+	// regarded as the one held by another process
+	if err := c.state.Close(); err != nil {
+		return err
+	}
+
+	var gitRequired, ghRequired bool
+	for _, pkg := range c.packages {
+		if cloner, ok := pkg.(manager.Cloner); ok && cloner.CloneURL() != "" {
+			gitRequired = true
+		}
+		if g, ok := pkg.(*manager.GitHub); ok && g.IsGHExtension() {
+			ghRequired = true
+		}
+	}
+
+	bin := os.Getenv("AFX_COMMAND_PATH")
+	report := doctor.Report{
+		doctor.CommandPath(bin, os.Getenv("PATH")),
+		doctor.Git(ctx, git.NewRunner(), gitRequired),
+		doctor.GH(ctx, gh.NewRunner(), ghRequired),
+	}
+	report = append(report, doctor.StatePaths(resources)...)
+	report = append(report, doctor.Symlinks(bin, manager.DataDir())...)
+	report = append(report, doctor.StateFile(manager.StateFile()))
+	report = append(report, doctor.StateLock(manager.StateFile()))
+	report = append(report, doctor.DuplicateCommands(c.packages)...)
+	client := github.NewClient()
+	for _, host := range manager.GitHubHosts(c.packages) {
+		report = append(report, doctor.GitHubToken(ctx, client, host))
+	}
+
+	if c.opt.fix {
+		report = report.Fix()
+	}
+
+	w := printers.GetNewTabWriter(os.Stdout)
+	fmt.Fprintf(w, "%s\n", strings.Join([]string{"STATUS", "CHECK", "MESSAGE"}, "\t"))
+	var fixable int
+	for _, result := range report {
+		if result.Fix != nil && result.Status != doctor.Pass {
+			fixable++
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join([]string{
+			statusColor(result.Status)(result.Status.String()),
+			result.Check,
+			result.Message,
+		}, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d passed, %d warnings, %d failed", report.Count(doctor.Pass), report.Count(doctor.Warn), report.Count(doctor.Fail))
+	if fixed := report.Count(doctor.Fixed); fixed > 0 {
+		fmt.Printf(", %d fixed", fixed)
+	}
+	fmt.Println()
+	if fixable > 0 {
+		fmt.Printf("%d problems can be fixed with %s\n", fixable, color.CyanString("afx doctor --fix"))
+	}
+
+	if failed := report.Count(doctor.Fail); failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+func statusColor(status doctor.Status) func(string, ...any) string {
+	switch status {
+	case doctor.Pass, doctor.Fixed:
+		return color.GreenString
+	case doctor.Warn:
+		return color.YellowString
+	default:
+		return color.RedString
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/runner"
+	"github.com/babarot/afx/internal/state"
+)
+
+const (
+	outputDefault = "default"
+	// outputJSON writes events of packages as NDJSON
+	outputJSON = "json"
+)
+
+// addOutputFlag adds --output flag to commands which can write events of packages
+func addOutputFlag(cmd *cobra.Command, output *string) {
+	cmd.Flags().StringVarP(output, "output", "o", outputDefault, "Output style [default,json]")
+	_ = cmd.RegisterFlagCompletionFunc("output",
+		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{outputDefault, outputJSON}, cobra.ShellCompDirectiveNoFileComp
+		})
+}
+
+func validateOutput(output string) error {
+	switch output {
+	case outputDefault, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("%s: not supported output style", output)
+	}
+}
+
+// messages returns where to print messages. They're printed to stderr
+// while events are written to stdout
+func messages(output string) io.Writer {
+	if output == outputJSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// newEvents returns events of given resources written to stdout if output is json,
+// otherwise nil. installs tells if the resources are going to be installed, so that
+// their versions are new ones. Old versions are what are installed
+func (m metaCmd) newEvents(output string, resources []state.Resource, installs bool) *runner.Events {
+	if output != outputJSON {
+		return nil
+	}
+	// messages in events are plain text
+	color.NoColor = true
+
+	infos := map[string]runner.Info{}
+	for _, resource := range resources {
+		info := runner.Info{Type: resource.Type}
+		if installed, ok := m.state.Installed(resource.ID); ok {
+			info.OldVersion = installed.Version
+		}
+		if installs {
+			info.NewVersion = resource.Version
+		}
+		infos[resource.Name] = info
+	}
+	return runner.NewEvents(os.Stdout, infos)
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/gc"
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/printers"
+)
+
+type gcCmd struct {
+	metaCmd
+
+	opt gcOpt
+}
+
+type gcOpt struct {
+	dryRun bool
+}
+
+var (
+	// gcLong is long description of gc command
+	gcLong = templates.LongDesc(`
+		Remove files left behind which no package refers to.
+
+		Clones of packages renamed or removed from config without
+		uninstalling, backups left by updates, and symlinks to packages
+		which are no longer created (e.g. "to" of a link was changed) are
+		collected from the data directory, AFX_COMMAND_PATH and the
+		directory of gh extensions. Packages removed from config but still
+		in state are not collected, run afx uninstall for them.
+
+		They're removed after confirmation.
+		`)
+
+	// gcExample is examples for gc command
+	gcExample = templates.Examples(`
+		$ afx gc --dry-run
+		$ afx gc
+	`)
+)
+
+// newGCCmd creates a new gc command
+func (m metaCmd) newGCCmd() *cobra.Command {
+	c := &gcCmd{metaCmd: m}
+
+	gcCmd := &cobra.Command{
+		Use:                   "gc",
+		Short:                 "Remove files which no package refers to",
+		Long:                  gcLong,
+		Example:               gcExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(0),
+		Annotations:           map[string]string{annotationStateLock: "dry-run"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.run()
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			return m.printForUpdate()
+		},
+	}
+
+	gcCmd.Flags().BoolVarP(&c.opt.dryRun, "dry-run", "n", false, "Only show what would be removed")
+
+	return gcCmd
+}
+
+func (c *gcCmd) run() error {
+	// packages to be uninstalled are still referred by state
+	resources := slices.Clone(c.state.Deletions)
+	for _, pkg := range c.packages {
+		resource := pkg.GetResource()
+		if installed, ok := c.state.Installed(resource.ID); ok {
+			resource.Generations = installed.Generations
+		}
+		resources = append(resources, resource)
+	}
+
+	garbage, err := gc.Find(gc.Dirs{
+		Data:        manager.DataDir(),
+		Bin:         os.Getenv("AFX_COMMAND_PATH"),
+		GHExtension: manager.GHExtensionDir(),
+		Keep:        []string{manager.CacheDir()},
+	}, resources)
+	if err != nil {
+		return fmt.Errorf("failed to find files to be removed: %w", err)
+	}
+	if len(garbage) == 0 {
+		fmt.Println("No files to remove")
+		return nil
+	}
+
+	w := printers.GetNewTabWriter(os.Stdout)
+	fmt.Fprintf(w, "%s\n", strings.Join([]string{"KIND", "PATH", "SIZE"}, "\t"))
+	var total int64
+	for _, g := range garbage {
+		size := cache.FormatSize(g.Size)
+		if g.Kind == gc.Symlink {
+			size = "-> " + g.Target
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join([]string{g.Kind.String(), g.Path, size}, "\t"))
+		total += g.Size
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d files (%s) can be removed\n", len(garbage), cache.FormatSize(total))
+
+	if c.opt.dryRun {
+		return nil
+	}
+
+	yes, err := c.confirm(&survey.Confirm{
+		Message: fmt.Sprintf("OK to remove these %d files?", len(garbage)),
+	})
+	if err != nil {
+		return err
+	}
+	if !yes {
+		fmt.Println("Canceled")
+		return nil
+	}
+
+	var errs []error
+	var removed int
+	for _, g := range garbage {
+		if err := g.Remove(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	fmt.Println(color.WhiteString("Removed %d files", removed))
+	return errors.Join(errs...)
+}
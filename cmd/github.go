@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/printers"
+)
+
+type githubCmd struct {
+	metaCmd
+
+	opt githubOpt
+}
+
+type githubOpt struct {
+	host   string
+	output string
+}
+
+var (
+	// githubLong is long description of github command
+	githubLong = templates.LongDesc(`
+		Show information about GitHub API used by afx.
+		`)
+
+	// githubExample is examples for github command
+	githubExample = templates.Examples(`
+		$ afx github rate-limit
+		$ afx github rate-limit --host ghe.example.com
+	`)
+)
+
+// newGitHubCmd creates a new github command
+func (m metaCmd) newGitHubCmd() *cobra.Command {
+	c := &githubCmd{metaCmd: m}
+
+	githubCmd := &cobra.Command{
+		Use:                   "github [rate-limit]",
+		Short:                 "Show information about GitHub API",
+		Long:                  githubLong,
+		Example:               githubExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MaximumNArgs(1),
+		Annotations:           map[string]string{annotationStateAsIs: "true", annotationStateLock: "none"},
+	}
+
+	githubCmd.AddCommand(
+		c.newGitHubRateLimitCmd(),
+	)
+
+	return githubCmd
+}
+
+// rateLimit is the rate limit of a host, printed as JSON
+type rateLimit struct {
+	Host  string `json:"host"`
+	Token bool   `json:"token"`
+
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"reset,omitzero"`
+
+	// Error is set if the rate limit cannot be got from the host
+	Error string `json:"error,omitempty"`
+}
+
+func (c *githubCmd) newGitHubRateLimitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "rate-limit",
+		Short:                 "Show the remaining quota of GitHub API",
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(0),
+		Annotations:           map[string]string{annotationStateAsIs: "true", annotationStateLock: "none"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(c.opt.output); err != nil {
+				return err
+			}
+			hosts := manager.GitHubHosts(c.packages)
+			if c.opt.host != "" {
+				hosts = []string{c.opt.host}
+			}
+
+			ctx := context.Background()
+			client := github.NewClient()
+			var limits []rateLimit
+			var errs []error
+			for _, host := range hosts {
+				limit := rateLimit{Host: host, Token: github.Token(host) != ""}
+				got, err := client.ForHost(host).GetRateLimit(ctx)
+				switch {
+				case errors.Is(err, github.ErrRateLimitDisabled):
+					limit.Error = err.Error()
+				case err != nil:
+					limit.Error = err.Error()
+					errs = append(errs, fmt.Errorf("%s: %w", host, err))
+				default:
+					limit.Limit = got.Limit
+					limit.Remaining = got.Remaining
+					limit.Used = got.Used
+					limit.Reset = got.Reset
+				}
+				limits = append(limits, limit)
+			}
+
+			if c.opt.output == outputJSON {
+				if err := printJSON(limits); err != nil {
+					return err
+				}
+				return errors.Join(errs...)
+			}
+
+			w := printers.GetNewTabWriter(os.Stdout)
+			fmt.Fprintf(w, "%s\n", strings.Join([]string{"HOST", "TOKEN", "REMAINING", "LIMIT", "RESET"}, "\t"))
+			for _, limit := range limits {
+				token := "not set"
+				if limit.Token {
+					token = "set"
+				}
+				if limit.Error != "" {
+					fmt.Fprintf(w, "%s\n", strings.Join([]string{limit.Host, token, "-", "-", limit.Error}, "\t"))
+					continue
+				}
+				fmt.Fprintf(w, "%s\n", strings.Join([]string{
+					limit.Host,
+					token,
+					strconv.Itoa(limit.Remaining),
+					strconv.Itoa(limit.Limit),
+					fmt.Sprintf("%s (in %s)", limit.Reset.Local().Format("15:04:05"),
+						time.Until(limit.Reset).Round(time.Second)),
+				}, "\t"))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			return errors.Join(errs...)
+		},
+	}
+
+	flag := cmd.Flags()
+	flag.StringVarP(&c.opt.host, "host", "", "", "Host to show (default: github.com and hosts of packages)")
+	flag.StringVarP(&c.opt.output, "output", "o", outputDefault, "Output style [default,json]")
+
+	return cmd
+}
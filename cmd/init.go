@@ -41,6 +41,7 @@ func (m metaCmd) newInitCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		SilenceUsage:          true,
 		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "shared"},
 		Args:                  cobra.MaximumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
 			for _, pkg := range m.packages {
@@ -7,7 +7,11 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/babarot/afx/internal/bundle"
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/github"
 	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/lock"
 	"github.com/babarot/afx/internal/logging"
 	manager "github.com/babarot/afx/internal/manager"
 	"github.com/babarot/afx/internal/runner"
@@ -16,6 +20,14 @@ import (
 
 type installCmd struct {
 	metaCmd
+
+	opt installOpt
+}
+
+type installOpt struct {
+	locked   bool
+	output   string
+	parallel int
 }
 
 var (
@@ -29,6 +41,16 @@ var (
 		By default, it tries to install all packages which are newly
 		added to config file.
 		If any args are given, it tries to install only them.
+
+		afx install --locked
+
+		Installs exactly what is recorded in afx.lock.
+		It fails if a package drifts from the lock file.
+
+		afx install -o json
+
+		Writes events of each package as NDJSON instead of
+		printing progress.
 	`)
 )
 
@@ -48,9 +70,16 @@ func (m metaCmd) newInstallCmd() *cobra.Command {
 		Args:                  cobra.MinimumNArgs(0),
 		ValidArgs:             state.Keys(m.state.Additions),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(c.opt.output); err != nil {
+				return err
+			}
+			if err := validateParallel(c.opt.parallel); err != nil {
+				return err
+			}
+			m.seedLock(context.Background())
 			resources := m.state.Additions
 			if len(resources) == 0 {
-				fmt.Println("No packages to install")
+				fmt.Fprintln(messages(c.opt.output), "No packages to install")
 				return nil
 			}
 
@@ -71,15 +100,17 @@ func (m metaCmd) newInstallCmd() *cobra.Command {
 				return fmt.Errorf("failed to confirm: %w", err)
 			}
 			if !yes {
-				fmt.Println("Canceled")
+				fmt.Fprintln(messages(c.opt.output), "Canceled")
 				return nil
 			}
 
 			pkgs := m.GetPackages(resources)
-			m.env.AskWhen(map[string]bool{
+			if err := m.env.AskWhen(map[string]bool{
 				"GITHUB_TOKEN":      manager.HasGitHubReleaseBlock(pkgs),
 				"AFX_SUDO_PASSWORD": manager.HasSudoInCommandBuildSteps(pkgs),
-			})
+			}); err != nil {
+				return err
+			}
 
 			return c.run(pkgs)
 		},
@@ -88,38 +119,62 @@ func (m metaCmd) newInstallCmd() *cobra.Command {
 		},
 	}
 
+	flag := installCmd.Flags()
+	flag.BoolVarP(&c.opt.locked, "locked", "", false, "Refuse to install packages drifting from afx.lock")
+	addOutputFlag(installCmd, &c.opt.output)
+	addParallelFlag(installCmd, &c.opt.parallel)
+
 	return installCmd
 }
 
 func (c *installCmd) run(pkgs []manager.Package) error {
 	log.Printf("[DEBUG] (install): start to run each pkg.Install()")
 
+	c.lock.SetLocked(c.opt.locked)
+
 	runnerPkgs := make([]runner.Package, len(pkgs))
+	resources := make([]state.Resource, len(pkgs))
 	for i, p := range pkgs {
 		runnerPkgs[i] = p
+		resources[i] = p.GetResource()
 	}
 
+	events := c.newEvents(c.opt.output, resources, true)
 	err := runner.Execute(runnerPkgs, func(p runner.Package) runner.TaskFunc {
 		pkg, _ := p.(manager.Package)
-		return func(ctx context.Context, completion chan<- runner.Status) error {
-			err := pkg.Install(ctx, completion)
-			switch err {
-			case nil:
-				if saveErr := c.state.Add(pkg); saveErr != nil {
-					log.Printf("[ERROR] %s: failed to save state: %v", pkg.GetName(), saveErr)
-				}
-			default:
-				if !logging.IsSet() {
-					log.Printf("[DEBUG] uninstall %q because installation failed", pkg.GetName())
-					_ = pkg.Uninstall(ctx)
-				}
-			}
-			return err
-		}
-	})
+		return c.installTask(pkg)
+	}, runner.WithEvents(events), c.concurrency(c.opt.parallel))
+
+	if saveErr := c.lock.Save(); saveErr != nil {
+		log.Printf("[ERROR] failed to save lock file: %v", saveErr)
+	}
 
 	if err != nil {
 		_ = c.env.Refresh()
 	}
 	return err
 }
+
+// installTask installs a package and records it to the state.
+// The package is uninstalled if the installation failed.
+func (m metaCmd) installTask(pkg manager.Package) runner.TaskFunc {
+	return func(ctx context.Context, completion chan<- runner.Status) error {
+		ctx = lock.NewContext(ctx, m.lock)
+		ctx = cache.NewContext(ctx, m.cache)
+		ctx = github.NewContext(ctx, m.githubOpts...)
+		ctx = bundle.NewContext(ctx, m.gitBundles)
+		err := pkg.Install(ctx, completion)
+		switch err {
+		case nil:
+			if saveErr := m.state.Add(pkg); saveErr != nil {
+				log.Printf("[ERROR] %s: failed to save state: %v", pkg.GetName(), saveErr)
+			}
+		default:
+			if !logging.IsSet() {
+				log.Printf("[DEBUG] uninstall %q because installation failed", pkg.GetName())
+				_ = pkg.Uninstall(ctx)
+			}
+		}
+		return err
+	}
+}
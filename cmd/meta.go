@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -11,10 +12,13 @@ import (
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
+	"github.com/spf13/cobra"
 
+	"github.com/babarot/afx/internal/cache"
 	"github.com/babarot/afx/internal/env"
 	"github.com/babarot/afx/internal/gh"
 	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/lock"
 	manager "github.com/babarot/afx/internal/manager"
 	"github.com/babarot/afx/internal/printers"
 	"github.com/babarot/afx/internal/state"
@@ -26,12 +30,28 @@ type metaCmd struct {
 	packages []manager.Package
 	main     *manager.Main
 	state    *state.State
+	lock     *lock.Lock
+	cache    *cache.Cache
 	configs  map[string]manager.Config
 
+	// githubOpts are options of GitHub API clients, carried by contexts of tasks
+	githubOpts []github.ClientOption
+
+	// gitBundles are git bundles to clone repositories from, keyed by clone URLs.
+	// They're set when installing from an afx bundle
+	gitBundles map[string]string
+
+	interaction *interaction
+
+	// stateErr is set when the state file is corrupted.
+	// Commands other than restoring the state fail with this error
+	stateErr error
+
 	updateMessageChan chan *update.ReleaseInfo
 }
 
 func (m *metaCmd) init() error {
+	m.interaction = &interaction{}
 	m.updateMessageChan = make(chan *update.ReleaseInfo)
 	go func() {
 		log.Printf("[DEBUG] (goroutine): checking new updates...")
@@ -42,6 +62,11 @@ func (m *metaCmd) init() error {
 		m.updateMessageChan <- release
 	}()
 
+	return m.load()
+}
+
+// load reads config files, the lock file and the state file.
+func (m *metaCmd) load() error {
 	if err := m.loadConfigs(); err != nil {
 		return err
 	}
@@ -52,9 +77,34 @@ func (m *metaCmd) init() error {
 	if err := m.initEnv(); err != nil {
 		return err
 	}
+	if err := m.initLock(); err != nil {
+		return err
+	}
+	if err := m.initCache(); err != nil {
+		return err
+	}
+	if err := m.initGitHub(); err != nil {
+		return err
+	}
 	return m.initState()
 }
 
+// reload loads config files and the state again (e.g. after config files are
+// changed by a command). The exclusive state lock is kept held while reloading
+func (m *metaCmd) reload() error {
+	prev := m.state
+	if err := m.load(); err != nil {
+		return err
+	}
+	if err := m.state.Lock(state.ExclusiveLock); err != nil {
+		return err
+	}
+	if prev != nil {
+		_ = prev.Close()
+	}
+	return nil
+}
+
 // loadConfigs reads and parses all YAML config files from the config directory.
 func (m *metaCmd) loadConfigs() error {
 	cfgRoot := manager.ConfigDir()
@@ -86,6 +136,7 @@ func (m *metaCmd) loadConfigs() error {
 		}
 	}
 
+	app.SetDefaults(pkgs)
 	m.main = app
 	m.packages = pkgs
 	return nil
@@ -121,7 +172,12 @@ func (m *metaCmd) initEnv() error {
 	cfgRoot := manager.ConfigDir()
 	cache := filepath.Join(root, "cache.json")
 
-	m.env = env.New(cache)
+	store, err := m.main.SecretStore()
+	if err != nil {
+		return err
+	}
+	m.env = env.New(cache, env.WithSecretStore(store))
+	m.env.NoInput = !m.interaction.canPrompt()
 	_ = m.env.Add(env.Variables{
 		"AFX_CONFIG_PATH":  env.Variable{Value: cfgRoot},
 		"AFX_LOG":          env.Variable{},
@@ -134,6 +190,7 @@ func (m *metaCmd) initEnv() error {
 				Message: "Please enter sudo command password",
 				Help:    "Some packages build steps requires sudo command",
 			},
+			Secret: true,
 		},
 		"GITHUB_TOKEN": env.Variable{
 			Input: env.Input{
@@ -141,6 +198,8 @@ func (m *metaCmd) initEnv() error {
 				Message: "Please type your GITHUB_TOKEN",
 				Help:    "To fetch GitHub Releases, GitHub token is required",
 			},
+			Command: m.main.TokenCommand,
+			Secret:  true,
 		},
 		"AFX_NO_UPDATE_NOTIFIER": env.Variable{},
 	})
@@ -149,23 +208,96 @@ func (m *metaCmd) initEnv() error {
 		log.Printf("[DEBUG] main: set env: %s=%s", k, v)
 		os.Setenv(k, v)
 	}
+	// API clients take the token from the secret store or token_command
+	// even if commands don't ask for it
+	github.Getenv = m.env.Getenv
 
 	_ = os.MkdirAll(root, os.ModePerm)
 	_ = os.MkdirAll(os.Getenv("AFX_COMMAND_PATH"), os.ModePerm)
 	return nil
 }
 
+// initLock reads the lock file placed next to config files.
+func (m *metaCmd) initLock() error {
+	l, err := lock.Open(manager.LockFile())
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	m.lock = l
+	return nil
+}
+
+// seedLock records packages installed before afx.lock was introduced into
+// the lock file, so that they can be installed with --locked elsewhere
+func (m *metaCmd) seedLock(ctx context.Context) {
+	for _, resource := range m.state.NoChanges {
+		if _, ok := m.lock.Get(resource.Name); ok {
+			continue
+		}
+		pkg := m.GetPackage(resource)
+		if pkg == nil {
+			continue
+		}
+		if entry, ok := manager.LockEntry(ctx, pkg, resource); ok {
+			m.lock.Seed(resource.Name, entry)
+		}
+	}
+	if err := m.lock.Save(); err != nil {
+		log.Printf("[ERROR] failed to save lock file: %v", err)
+	}
+}
+
+// initGitHub sets up options of GitHub API clients to wait for the rate limit
+// to be reset, and to cache responses next to the download cache.
+func (m *metaCmd) initGitHub() error {
+	wait, err := m.main.GitHubRateLimitWait()
+	if err != nil {
+		return err
+	}
+	m.githubOpts = []github.ClientOption{
+		github.WithRateLimitWait(wait),
+		github.WithETagCache(manager.GitHubCacheDir()),
+	}
+	return nil
+}
+
+// initCache sets up the download cache with the size limit in config.
+func (m *metaCmd) initCache() error {
+	size, err := m.main.CacheMaxSize()
+	if err != nil {
+		return err
+	}
+	m.cache = cache.New(manager.CacheDir(), cache.WithMaxSize(size))
+	return nil
+}
+
 // initState opens the state file and logs the current state summary.
 func (m *metaCmd) initState() error {
-	root := manager.DataDir()
-
 	resourcers := make([]state.Resourcer, len(m.packages))
 	for i, pkg := range m.packages {
 		resourcers[i] = pkg
 	}
 
-	s, err := state.Open(filepath.Join(root, "state.json"), resourcers)
+	timeout, err := m.main.StateLockTimeout()
 	if err != nil {
+		return err
+	}
+
+	// the state is locked before running commands (see lockState), not here,
+	// since the lock mode depends on commands. Outdated state is also migrated
+	// then, so that migrations can be reviewed by "afx state migrate --dry-run"
+	s, err := state.Open(manager.StateFile(), resourcers,
+		state.WithLock(state.NoLock),
+		state.WithLockTimeout(timeout, func(holder *state.Holder) {
+			by := "other afx processes"
+			if holder != nil {
+				by = holder.String()
+			}
+			fmt.Fprintf(os.Stderr, "Waiting for the state lock held by %s...\n", by)
+		}),
+		state.WithoutMigration(),
+	)
+	if err := m.setStateErr(err); err != nil {
 		return fmt.Errorf("failed to open state file: %w", err)
 	}
 	m.state = s
@@ -178,6 +310,71 @@ func (m *metaCmd) initState() error {
 	return nil
 }
 
+// setStateErr keeps an error of reading state which doesn't prevent some
+// commands from running, e.g. restoring a corrupted state. Other errors are returned
+func (m *metaCmd) setStateErr(err error) error {
+	var corrupted *state.CorruptedError
+	var outdated *state.OutdatedError
+	switch {
+	case err == nil:
+		m.stateErr = nil
+	case errors.As(err, &corrupted), errors.As(err, &outdated):
+		log.Printf("[WARN] %v", err)
+		m.stateErr = err
+	default:
+		return err
+	}
+	return nil
+}
+
+// lockState acquires the state lock in the mode which a command needs,
+// then makes sure the state can be used by the command
+func (m *metaCmd) lockState(cmd *cobra.Command) error {
+	var mode state.LockMode
+	switch cmd.Annotations[annotationStateLock] {
+	case "none":
+		return nil
+	case "shared":
+		mode = state.SharedLock
+	case "dry-run":
+		mode = state.ExclusiveLock
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			mode = state.SharedLock
+		}
+	default:
+		mode = state.ExclusiveLock
+	}
+	switch cmd.Name() {
+	case "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return nil
+	}
+
+	if err := m.setStateErr(m.state.Lock(mode)); err != nil {
+		return err
+	}
+	if m.stateErr == nil || cmd.Annotations[annotationStateAsIs] != "" {
+		return nil
+	}
+	var outdated *state.OutdatedError
+	if !errors.As(m.stateErr, &outdated) {
+		return m.stateErr
+	}
+	if mode != state.ExclusiveLock {
+		// migrations need the exclusive lock even if commands only read state
+		if err := m.setStateErr(m.state.Lock(state.ExclusiveLock)); err != nil {
+			return err
+		}
+	}
+	plan, err := m.state.Migrate()
+	if err != nil {
+		return fmt.Errorf("failed to migrate state: %w", err)
+	}
+	m.stateErr = nil
+	fmt.Fprintf(os.Stderr, "Migrated state schema from version %d to %d (backup: %s)\n",
+		plan.From, plan.To, plan.Backup)
+	return nil
+}
+
 func printForUpdate(uriCh chan *update.ReleaseInfo) {
 	switch Version {
 	case "unset":
@@ -215,6 +412,8 @@ func (m *metaCmd) askRunCommand(op any, pkgs []string) (bool, error) {
 		do = "update"
 	case checkCmd:
 		do = "check"
+	case applyCmd:
+		do = "apply"
 	default:
 		return false, errors.New("unsupported command type")
 	}
@@ -225,7 +424,6 @@ func (m *metaCmd) askRunCommand(op any, pkgs []string) (bool, error) {
 		target = fmt.Sprintf("%s, ... (%d packages)", strings.Join(pkgs[:length], ", "), len(pkgs))
 	}
 
-	yes := false
 	confirm := survey.Confirm{
 		Message: fmt.Sprintf("OK to %s these packages? %s", do, color.YellowString(target)),
 	}
@@ -240,10 +438,7 @@ func (m *metaCmd) askRunCommand(op any, pkgs []string) (bool, error) {
 		confirm.Help = sb.String()
 	}
 
-	if err := survey.AskOne(&confirm, &yes); err != nil {
-		return false, fmt.Errorf("failed to get input from console: %w", err)
-	}
-	return yes, nil
+	return m.confirm(&confirm)
 }
 
 func shouldCheckForUpdate() bool {
@@ -286,6 +481,19 @@ func (m metaCmd) GetPackages(resources []state.Resource) []manager.Package {
 	return pkgs
 }
 
+// GetResources returns resources of packages which are managed in the state.
+func (m metaCmd) GetResources() []state.Resource {
+	var resources []state.Resource
+	for _, pkg := range m.packages {
+		resource := pkg.GetResource()
+		if resource.Type == "Local" {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+	return resources
+}
+
 func (m metaCmd) GetConfig() manager.Config {
 	var all manager.Config
 	for _, cfg := range m.configs {
@@ -293,6 +501,9 @@ func (m metaCmd) GetConfig() manager.Config {
 			all.Main = cfg.Main
 		}
 		all.GitHub = append(all.GitHub, cfg.GitHub...)
+		all.GitLab = append(all.GitLab, cfg.GitLab...)
+		all.Gitea = append(all.Gitea, cfg.Gitea...)
+		all.Git = append(all.Git, cfg.Git...)
 		all.Gist = append(all.Gist, cfg.Gist...)
 		all.HTTP = append(all.HTTP, cfg.HTTP...)
 		all.Local = append(all.Local, cfg.Local...)
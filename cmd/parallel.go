@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/runner"
+)
+
+// addParallelFlag adds --parallel flag to commands which run packages at the same time
+func addParallelFlag(cmd *cobra.Command, parallel *int) {
+	cmd.Flags().IntVarP(parallel, "parallel", "", 0,
+		fmt.Sprintf("Number of packages run at the same time (default: concurrency in main config, or %d)", runner.DefaultConcurrency))
+}
+
+func validateParallel(parallel int) error {
+	if parallel < 0 {
+		return fmt.Errorf("--parallel: must be positive: %d", parallel)
+	}
+	return nil
+}
+
+// concurrency returns the number of packages run at the same time.
+// --parallel flag takes precedence over concurrency in main config
+func (m metaCmd) concurrency(parallel int) runner.Option {
+	if parallel > 0 {
+		return runner.WithConcurrency(parallel)
+	}
+	return runner.WithConcurrency(m.main.Concurrency)
+}
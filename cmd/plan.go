@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/plan"
+)
+
+type planCmd struct {
+	metaCmd
+
+	opt planOpt
+}
+
+type planOpt struct {
+	out string
+}
+
+var (
+	// planLong is long description of plan command
+	planLong = templates.LongDesc(`
+		Show which packages will be installed, reinstalled, updated and
+		uninstalled to match the config file, with the reasons.
+		Nothing is changed by this command.
+	`)
+
+	// planExample is examples for plan command
+	planExample = templates.Examples(`
+		afx plan
+
+		afx plan --out afx.plan
+		afx apply afx.plan
+
+		Saves the plan to a file and applies exactly that plan later.
+	`)
+)
+
+// newPlanCmd creates a new plan command
+func (m metaCmd) newPlanCmd() *cobra.Command {
+	c := &planCmd{metaCmd: m}
+
+	planCmd := &cobra.Command{
+		Use:                   "plan",
+		Short:                 "Show changes required by the current config",
+		Long:                  planLong,
+		Example:               planExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "shared"},
+		Args:                  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// resolved before desired resources are taken, as packages get resolved versions
+			resolved := c.resolveChanges(context.Background())
+			p := plan.New(c.state, c.GetResources(), resolved)
+			p.Render(os.Stdout)
+
+			if c.opt.out == "" || p.Empty() {
+				return nil
+			}
+			if err := p.Save(c.opt.out); err != nil {
+				return fmt.Errorf("%s: failed to save plan: %w", c.opt.out, err)
+			}
+			fmt.Printf("\nSaved the plan to %s. To perform exactly these actions, run: afx apply %s\n",
+				c.opt.out, c.opt.out)
+			return nil
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			return m.printForUpdate()
+		},
+	}
+
+	flag := planCmd.Flags()
+	flag.StringVar(&c.opt.out, "out", "", "Save the plan to a file")
+
+	return planCmd
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/babarot/afx/internal/printers"
+)
+
+// errNoInput is returned when afx needs to prompt but it's disabled
+var errNoInput = errors.New("cannot prompt in non-interactive mode")
+
+// interaction tells how afx interacts with the user. It's set by global flags
+type interaction struct {
+	// yes answers yes to all confirmations
+	yes bool
+	// noInput makes afx fail instead of prompting
+	noInput bool
+}
+
+// nonInteractive returns true if AFX_NONINTERACTIVE is set,
+// which is the same as running with both --yes and --no-input
+func nonInteractive() bool {
+	return os.Getenv("AFX_NONINTERACTIVE") != ""
+}
+
+func (i *interaction) assumeYes() bool {
+	return i.yes || nonInteractive()
+}
+
+// canPrompt returns false if prompting is disabled, or stdin is not
+// a terminal (e.g. in scripts or Docker builds) so the user cannot answer
+func (i *interaction) canPrompt() bool {
+	return !i.noInput && !nonInteractive() && printers.IsTerminal(os.Stdin)
+}
+
+// confirm asks the user for confirmation unless it's answered by --yes
+func (m metaCmd) confirm(prompt *survey.Confirm) (bool, error) {
+	if m.interaction.assumeYes() {
+		return true, nil
+	}
+	if !m.interaction.canPrompt() {
+		return false, errors.New("confirmation required in non-interactive mode, run with --yes to proceed")
+	}
+	yes := false
+	if err := survey.AskOne(prompt, &yes, surveyOpts()...); err != nil {
+		return false, fmt.Errorf("failed to get input from console: %w", err)
+	}
+	return yes, nil
+}
+
+// choose asks the user to choose one of options
+func (m metaCmd) choose(prompt *survey.Select) (string, error) {
+	if !m.interaction.canPrompt() {
+		return "", errNoInput
+	}
+	var selected string
+	if err := survey.AskOne(prompt, &selected, surveyOpts()...); err != nil {
+		return "", fmt.Errorf("failed to get input from console: %w", err)
+	}
+	return selected, nil
+}
+
+func surveyOpts() []survey.AskOpt {
+	var opts []survey.AskOpt
+	if !printers.IsTerminal(os.Stdout) {
+		// keep stdout for the output, e.g. events written as NDJSON
+		opts = append(opts, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	}
+	return opts
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/babarot/afx/internal/printers"
+)
+
+func TestConfirm_nonInteractive(t *testing.T) {
+	orig := printers.IsTerminal
+	defer func() { printers.IsTerminal = orig }()
+
+	tests := map[string]struct {
+		interaction interaction
+		env         string
+		terminal    bool
+		want        bool
+		wantErr     bool
+	}{
+		"yes": {
+			interaction: interaction{yes: true},
+			want:        true,
+		},
+		"AFX_NONINTERACTIVE": {
+			env:  "1",
+			want: true,
+		},
+		"no input": {
+			interaction: interaction{noInput: true},
+			terminal:    true,
+			wantErr:     true,
+		},
+		"stdin is not a terminal": {
+			terminal: false,
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("AFX_NONINTERACTIVE", tt.env)
+			printers.IsTerminal = func(*os.File) bool { return tt.terminal }
+
+			m := metaCmd{interaction: &tt.interaction}
+			got, err := m.confirm(&survey.Confirm{Message: "OK?"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("confirm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("confirm() = %v, want %v", got, tt.want)
+			}
+
+			if _, err := m.choose(&survey.Select{Message: "Choose:", Options: []string{"a"}}); !errors.Is(err, errNoInput) {
+				t.Errorf("choose() error = %v, want errNoInput", err)
+			}
+		})
+	}
+}
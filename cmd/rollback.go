@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/babarot/afx/internal/generation"
+	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/state"
+)
+
+type rollbackCmd struct {
+	metaCmd
+
+	opt rollbackOpt
+}
+
+type rollbackOpt struct {
+	to string
+}
+
+var (
+	// rollbackLong is long description of rollback command
+	rollbackLong = templates.LongDesc(`
+		Roll back a package to a previous installation.
+
+		Previous installations are kept as generations when packages are
+		updated (3 generations by default, see "generations" in main).
+		The package is switched to the newest generation unless --to is
+		given. The current installation is kept as a generation in turn,
+		so rolling back can be undone by rolling back to it again.
+
+		Note that "afx update" installs the version in config again, so
+		pin the version in config to stay at the rolled back one.
+		`)
+
+	// rollbackExample is examples for rollback command
+	rollbackExample = templates.Examples(`
+		$ afx rollback junegunn/fzf
+		$ afx rollback junegunn/fzf --to v0.44.0
+	`)
+)
+
+// newRollbackCmd creates a new rollback command
+func (m metaCmd) newRollbackCmd() *cobra.Command {
+	c := &rollbackCmd{metaCmd: m}
+
+	var names []string
+	for _, resource := range m.state.Resources {
+		if len(resource.Generations) > 0 {
+			names = append(names, resource.Name)
+		}
+	}
+
+	rollbackCmd := &cobra.Command{
+		Use:                   "rollback <package>",
+		Short:                 "Roll back a package to a previous installation",
+		Long:                  rollbackLong,
+		Example:               rollbackExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             names,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.run(args[0])
+		},
+		PostRunE: func(cmd *cobra.Command, args []string) error {
+			return m.printForUpdate()
+		},
+	}
+
+	rollbackCmd.Flags().StringVarP(&c.opt.to, "to", "", "", "Version (or name) of the generation to roll back to")
+
+	return rollbackCmd
+}
+
+func (c *rollbackCmd) run(name string) error {
+	resource, err := c.state.Get(name)
+	if err != nil {
+		return err
+	}
+	i, err := generation.Find(resource.Generations, c.opt.to)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	target := resource.Generations[i]
+
+	// the current installation becomes a generation to roll forward to
+	locked, _ := c.lock.Get(name)
+	current := state.Generation{
+		Name:    generation.Name(resource.Version, locked.Commit, time.Now()),
+		Version: resource.Version,
+		Paths:   resource.Paths,
+		Links:   generation.Links(resource.Paths, resource.Home),
+	}
+	if locked.Type != "" {
+		current.Lock = &locked
+	}
+	current.Dir = generation.Dir(manager.GenerationsDir(), resource.ID, current.Name)
+	if current.Dir == target.Dir {
+		// e.g. the same version was installed again
+		current.Name = generation.Name("", "", time.Now())
+		current.Dir = generation.Dir(manager.GenerationsDir(), resource.ID, current.Name)
+	}
+
+	if err := generation.Swap(resource.Home, target.Dir, current.Dir); err != nil {
+		return fmt.Errorf("%s: failed to switch to %s: %w", name, target.Name, err)
+	}
+	if err := generation.Relink(current.Links, target.Links); err != nil {
+		log.Printf("[ERROR] %s: failed to switch links: %v", name, err)
+		fmt.Printf("%s: some links could not be switched, run afx doctor to check them\n", name)
+	}
+
+	gens := []state.Generation{current}
+	for j, g := range resource.Generations {
+		if j != i && g.Dir != current.Dir {
+			gens = append(gens, g)
+		}
+	}
+	gens, dropped := generation.Keep(gens, max(c.main.KeepGenerations(), 1))
+	for _, g := range dropped {
+		_ = os.RemoveAll(g.Dir)
+	}
+
+	resource.Version = target.Version
+	resource.Paths = target.Paths
+	resource.Generations = gens
+	if err := c.state.Update(resource); err != nil {
+		return fmt.Errorf("%s: failed to save state: %w", name, err)
+	}
+
+	switch {
+	case target.Lock != nil:
+		if err := c.lock.Record(name, *target.Lock); err != nil {
+			log.Printf("[ERROR] %s: failed to record lock: %v", name, err)
+		}
+	case locked.Type != "":
+		c.lock.Delete(name)
+	}
+	if err := c.lock.Save(); err != nil {
+		log.Printf("[ERROR] failed to save lock file: %v", err)
+	}
+
+	fmt.Println(color.WhiteString("Rolled back %s from %s to %s", name, current.Name, target.Name))
+	return nil
+}
@@ -19,6 +19,16 @@ var (
 	rootLong = templates.LongDesc(`Package manager for CLI`)
 )
 
+// annotationStateAsIs is an annotation of commands which run with the state
+// file as it is, even if it's corrupted or outdated (e.g. restoring the state)
+const annotationStateAsIs = "afx/state-as-is"
+
+// annotationStateLock is an annotation of commands which need the state lock
+// other than the exclusive one: "shared" for commands which only read the
+// state, "none" for commands which don't use the state, and "dry-run" for
+// commands which only read the state when --dry-run is given
+const annotationStateLock = "afx/state-lock"
+
 var (
 	// Version is the version number
 	Version = "unset"
@@ -39,6 +49,11 @@ func newRootCmd(m metaCmd) *cobra.Command {
 		SilenceErrors:      true,
 		DisableSuggestions: false,
 		Version:            fmt.Sprintf("%s (%s/%s)", Version, BuildTag, BuildSHA),
+		Annotations:        map[string]string{annotationStateLock: "none"},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			m.env.NoInput = !m.interaction.canPrompt()
+			return m.lockState(cmd)
+		},
 		PreRun: func(cmd *cobra.Command, args []string) {
 			uriCh := make(chan *update.ReleaseInfo)
 			go func() {
@@ -63,16 +78,29 @@ func newRootCmd(m metaCmd) *cobra.Command {
 		},
 	}
 
+	flag := rootCmd.PersistentFlags()
+	flag.BoolVarP(&m.interaction.yes, "yes", "y", false, "Answer yes to all confirmations")
+	flag.BoolVarP(&m.interaction.noInput, "no-input", "", false, "Fail instead of prompting for inputs")
+
 	rootCmd.AddCommand(
 		m.newInitCmd(),
 		m.newInstallCmd(),
+		m.newAddCmd(),
 		m.newUninstallCmd(),
 		m.newUpdateCmd(),
 		m.newCheckCmd(),
+		m.newPlanCmd(),
+		m.newApplyCmd(),
 		m.newSelfUpdateCmd(),
 		m.newShowCmd(),
 		m.newCompletionCmd(),
 		m.newStateCmd(),
+		m.newCacheCmd(),
+		m.newGitHubCmd(),
+		m.newBundleCmd(),
+		m.newDoctorCmd(),
+		m.newGCCmd(),
+		m.newRollbackCmd(),
 	)
 
 	return rootCmd
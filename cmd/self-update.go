@@ -57,11 +57,14 @@ func (m metaCmd) newSelfUpdateCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		SilenceUsage:          true,
 		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "none"},
 		Args:                  cobra.MaximumNArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			m.env.AskWhen(map[string]bool{
+			if err := m.env.AskWhen(map[string]bool{
 				"GITHUB_TOKEN": true,
-			})
+			}); err != nil {
+				return err
+			}
 			return c.run(args)
 		},
 	}
@@ -99,18 +102,18 @@ func (c *selfUpdateCmd) run(args []string) error {
 		return nil
 	}
 
-	yes := false
-	if err := survey.AskOne(&survey.Confirm{
+	yes, err := c.confirm(&survey.Confirm{
 		Message: fmt.Sprintf("Do you update to %s? (current version: %s)",
 			latest.Version(), Version),
-	}, &yes); err != nil {
-		return fmt.Errorf("cannot get answer from console: %w", err)
+	})
+	if err != nil {
+		return err
 	}
 	if !yes {
 		return nil
 	}
 
-	release, err := github.NewRelease(ctx, "babarot", "afx", "v"+latest.Version(), github.WithVerbose())
+	release, err := github.NewRelease(ctx, github.DefaultHost, "babarot", "afx", "v"+latest.Version(), github.WithVerbose())
 	if err != nil {
 		return err
 	}
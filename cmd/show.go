@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
@@ -9,7 +11,9 @@ import (
 	"github.com/goccy/go-yaml"
 	"github.com/spf13/cobra"
 
+	"github.com/babarot/afx/internal/github"
 	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
 	"github.com/babarot/afx/internal/printers"
 	"github.com/babarot/afx/internal/state"
 )
@@ -22,6 +26,7 @@ type showCmd struct {
 
 type showOpt struct {
 	output string
+	assets bool
 }
 
 var (
@@ -32,6 +37,7 @@ var (
 	showExample = templates.Examples(`
 		$ afx show
 		$ afx show -o json | jq .github
+		$ afx show --assets cli/cli
 	`)
 )
 
@@ -47,9 +53,13 @@ func (m metaCmd) newShowCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		SilenceUsage:          true,
 		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "shared"},
 		ValidArgs:             state.Keys(m.state.NoChanges),
 		Args:                  cobra.MinimumNArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if c.opt.assets {
+				return c.showAssets(args)
+			}
 			cfg := m.GetConfig()
 			if len(args) > 0 {
 				cfg = cfg.Contains(args...)
@@ -86,6 +96,7 @@ func (m metaCmd) newShowCmd() *cobra.Command {
 
 	flag := showCmd.Flags()
 	flag.StringVarP(&c.opt.output, "output", "o", "default", "Output style [default,json,yaml,path,name]")
+	flag.BoolVarP(&c.opt.assets, "assets", "", false, "Show which release asset is picked for given packages and why")
 
 	_ = showCmd.RegisterFlagCompletionFunc("output",
 		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -168,3 +179,47 @@ func (c *showCmd) run(args []string) error {
 
 	return w.Flush()
 }
+
+// showAssets explains which release asset is picked for given packages
+func (c *showCmd) showAssets(args []string) error {
+	if len(args) == 0 {
+		return errors.New("package name is required with --assets")
+	}
+
+	w := printers.GetNewTabWriter(os.Stdout)
+	fmt.Fprintf(w, "%s\n", strings.Join([]string{"NAME", "", "ASSET", "REASON"}, "\t"))
+
+	for _, arg := range args {
+		var pkg manager.Package
+		for _, p := range c.packages {
+			if p.GetName() == arg {
+				pkg = p
+				break
+			}
+		}
+		if pkg == nil {
+			return fmt.Errorf("%s: no such package", arg)
+		}
+		selector, ok := pkg.(manager.AssetSelector)
+		if !ok {
+			return fmt.Errorf("%s: not a package installed from releases", arg)
+		}
+		selection, err := selector.SelectAsset(github.NewContext(context.Background(), c.githubOpts...))
+		if err != nil && len(selection.Candidates) == 0 {
+			return err
+		}
+		for _, candidate := range selection.Candidates {
+			mark := ""
+			if candidate.Picked {
+				mark = "*"
+			}
+			fmt.Fprintf(w, "%s\n", strings.Join([]string{arg, mark, candidate.Asset.Name, candidate.Reason}, "\t"))
+		}
+		if err != nil {
+			_ = w.Flush()
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+	}
+
+	return w.Flush()
+}
@@ -1,13 +1,21 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/printers"
 	"github.com/babarot/afx/internal/state"
 )
 
@@ -18,7 +26,10 @@ type stateCmd struct {
 }
 
 type stateOpt struct {
-	force bool
+	force  bool
+	list   bool
+	dryRun bool
+	output string
 }
 
 var (
@@ -34,7 +45,7 @@ func (m metaCmd) newStateCmd() *cobra.Command {
 	c := &stateCmd{metaCmd: m}
 
 	stateCmd := &cobra.Command{
-		Use:                   "state [list|refresh|remove]",
+		Use:                   "state [list|show|mv|import|refresh|remove|restore|migrate|unlock]",
 		Short:                 "Advanced state management",
 		Long:                  stateLong,
 		Example:               stateExample,
@@ -47,34 +58,251 @@ func (m metaCmd) newStateCmd() *cobra.Command {
 
 	stateCmd.AddCommand(
 		c.newStateListCmd(),
+		c.newStateShowCmd(),
+		c.newStateMoveCmd(),
+		c.newStateImportCmd(),
 		c.newStateRefreshCmd(),
 		c.newStateRemoveCmd(),
+		c.newStateRestoreCmd(),
+		c.newStateMigrateCmd(),
+		c.newStateUnlockCmd(),
 	)
 
 	return stateCmd
 }
 
 func (c stateCmd) newStateListCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:                   "list",
 		Short:                 "List your state items",
 		DisableFlagsInUseLine: true,
 		SilenceUsage:          true,
 		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "shared"},
 		Args:                  cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			resources, err := c.state.List()
 			if err != nil {
 				return err
 			}
-			for _, resource := range resources {
-				fmt.Println(resource.Name)
+			sort.Slice(resources, func(i, j int) bool {
+				return resources[i].Name < resources[j].Name
+			})
+			switch c.opt.output {
+			case "default":
+				for _, resource := range resources {
+					fmt.Println(resource.Name)
+				}
+			case "json":
+				items := []stateItem{}
+				for _, resource := range resources {
+					items = append(items, newStateItem(resource))
+				}
+				return printJSON(items)
+			default:
+				return fmt.Errorf("%s: not supported output style", c.opt.output)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&c.opt.output, "output", "o", "default", "Output style [default,json]")
+	_ = cmd.RegisterFlagCompletionFunc("output",
+		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"default", "json"}, cobra.ShellCompDirectiveNoFileComp
+		})
+	return cmd
+}
+
+// stateItem is a resource in state file with the paths missing on the filesystem
+type stateItem struct {
+	state.Resource
+	Missing []string `json:"missing"`
+}
+
+func newStateItem(resource state.Resource) stateItem {
+	missing := resource.Missing()
+	if missing == nil {
+		missing = []string{}
+	}
+	return stateItem{Resource: resource, Missing: missing}
+}
+
+func printJSON(v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func (c stateCmd) newStateShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "show <package>",
+		Short:                 "Show a state item in detail",
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "shared"},
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             state.Keys(c.state.NoChanges),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource, err := c.state.Get(args[0])
+			if err != nil {
+				return err
+			}
+			item := newStateItem(resource)
+			switch c.opt.output {
+			case "default":
+			case "json":
+				return printJSON(item)
+			default:
+				return fmt.Errorf("%s: not supported output style", c.opt.output)
+			}
+
+			w := printers.GetNewTabWriter(os.Stdout)
+			fmt.Fprintf(w, "ID:\t%s\n", item.ID)
+			fmt.Fprintf(w, "Name:\t%s\n", item.Name)
+			fmt.Fprintf(w, "Type:\t%s\n", item.Type)
+			fmt.Fprintf(w, "Version:\t%s\n", item.Version)
+			fmt.Fprintf(w, "Home:\t%s\n", item.Home)
+			fmt.Fprintf(w, "Paths:\n")
+			for _, path := range item.Paths {
+				status := color.GreenString("exists")
+				if contains(item.Missing, path) {
+					status = color.RedString("missing")
+				}
+				fmt.Fprintf(w, "  %s\t%s\n", path, status)
+			}
+			if len(item.Generations) > 0 {
+				fmt.Fprintf(w, "Generations:\n")
+				for _, gen := range item.Generations {
+					fmt.Fprintf(w, "  %s\t%s\n", gen.Name, gen.Dir)
+				}
+			}
+			if c.findResource(item.Name) == nil && c.findResource(string(item.ID)) == nil {
+				fmt.Fprintf(w, "%s\n", color.YellowString("Not found in config files (uninstalled by the next 'afx uninstall')"))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&c.opt.output, "output", "o", "default", "Output style [default,json]")
+	_ = cmd.RegisterFlagCompletionFunc("output",
+		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"default", "json"}, cobra.ShellCompDirectiveNoFileComp
+		})
+	return cmd
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// findResource returns the resource of a package in config files by its name or ID
+func (c stateCmd) findResource(name string) *state.Resource {
+	for _, resource := range c.GetResources() {
+		if resource.Name == name || string(resource.ID) == name {
+			return &resource
+		}
+	}
+	return nil
+}
+
+func (c stateCmd) newStateMoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "mv <old> <new>",
+		Short: "Move a state item to a package in config files without reinstalling",
+		Long: templates.LongDesc(`
+			Move a state item to a package in config files without reinstalling.
+
+			The ID of a state item depends on how the package is installed.
+			For example, a package in github changes its ID once the release
+			block is added to it, and it's reinstalled by 'afx install' as a new
+			package. This command records the installed package as the new one
+			instead, taking over its version and generations.
+
+			<old> is a name or an ID in state file, and <new> is a name or an
+			ID of the package in config files.
+			`),
+		Example: templates.Examples(`
+			$ afx state mv github.com/stedolan/jq github.com/release/stedolan/jq
+			`),
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Aliases:               []string{"move"},
+		Args:                  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			old, err := c.state.Get(args[0])
+			if err != nil {
+				return err
+			}
+			resource := c.findResource(args[1])
+			if resource == nil {
+				return fmt.Errorf("%s: no such package in config files", args[1])
+			}
+			if err := c.state.Move(old.ID, *resource); err != nil {
+				return fmt.Errorf("failed to move state: %w", err)
+			}
+			fmt.Println(color.WhiteString("Moved %s to %s", old.ID, resource.ID))
+			warnMissing(*resource)
+			return nil
+		},
+	}
+}
+
+func (c stateCmd) newStateImportCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import <package>",
+		Short: "Import a package already present on the filesystem into state file",
+		Long: templates.LongDesc(`
+			Import a package already present on the filesystem into state file.
+
+			The package in config files is recorded as installed if its
+			home directory exists, e.g. a repository cloned by hand or
+			by another tool, so that afx doesn't install it again.
+			`),
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             state.Keys(c.state.Additions),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource := c.findResource(args[0])
+			if resource == nil {
+				return fmt.Errorf("%s: no such package in config files", args[0])
+			}
+			if _, ok := c.state.Installed(resource.ID); ok {
+				return fmt.Errorf("%s: already exists in state file", resource.Name)
+			}
+			if _, err := os.Stat(resource.Home); err != nil {
+				return fmt.Errorf("%s: cannot import: %w", resource.Name, err)
 			}
+			if err := c.state.Add(*resource); err != nil {
+				return fmt.Errorf("%s: failed to save state: %w", resource.Name, err)
+			}
+			fmt.Println(color.WhiteString("Imported %s from %s", resource.Name, resource.Home))
+			warnMissing(*resource)
 			return nil
 		},
 	}
 }
 
+// warnMissing warns paths of a resource which are missing on the filesystem
+func warnMissing(resource state.Resource) {
+	missing := resource.Missing()
+	if len(missing) == 0 {
+		return
+	}
+	fmt.Println(color.YellowString("%s: some paths are missing, run 'afx install' to complete them:\n  %s",
+		resource.Name, strings.Join(missing, "\n  ")))
+}
+
 func (c stateCmd) newStateRefreshCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:                   "refresh",
@@ -120,12 +348,15 @@ func (c stateCmd) newStateRemoveCmd() *cobra.Command {
 				for _, r := range rs {
 					items = append(items, r.Name)
 				}
-				var selected string
-				if err := survey.AskOne(&survey.Select{
+				selected, err := c.choose(&survey.Select{
 					Message: "Choose a package:",
 					Options: items,
-				}, &selected); err != nil {
-					return fmt.Errorf("failed to get input from console: %w", err)
+				})
+				if errors.Is(err, errNoInput) {
+					return errors.New("package name is required in non-interactive mode")
+				}
+				if err != nil {
+					return err
 				}
 				resource, err := c.state.Get(selected)
 				if err != nil {
@@ -145,8 +376,150 @@ func (c stateCmd) newStateRemoveCmd() *cobra.Command {
 				if err := c.state.Remove(resource); err != nil {
 					return fmt.Errorf("%s: failed to save state: %w", resource.Name, err)
 				}
+				if pkg := c.findResource(string(resource.ID)); pkg != nil {
+					fmt.Println(color.YellowString("%s: still in config files, so it will be installed again by 'afx install'", resource.Name))
+				}
 			}
 			return nil
 		},
 	}
 }
+
+func (c stateCmd) newStateRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore [N]",
+		Short: "Restore your state file from a backup",
+		Long: templates.LongDesc(`
+			Restore your state file from a backup.
+
+			The last 5 state files are kept as state.json.1 (the newest) to
+			state.json.5 (the oldest) each time the state is changed. The newest
+			backup which is not corrupted is restored unless N is given.
+			Backups are listed with --list.
+			`),
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MaximumNArgs(1),
+		Annotations:           map[string]string{annotationStateAsIs: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := manager.StateFile()
+			if c.opt.list {
+				w := printers.GetNewTabWriter(os.Stdout)
+				fmt.Fprintln(w, "N\tMODIFIED\tSTATUS")
+				for _, backup := range state.Backups(path) {
+					status := color.GreenString("ok")
+					if !backup.Valid {
+						status = color.RedString("corrupted")
+					}
+					fmt.Fprintf(w, "%d\t%s\t%s\n", backup.N, backup.ModTime.Local().Format("2006-01-02 15:04:05"), status)
+				}
+				return w.Flush()
+			}
+			var n int
+			if len(args) > 0 {
+				var err error
+				n, err = strconv.Atoi(args[0])
+				if err != nil || n < 1 || n > state.MaxBackups {
+					return fmt.Errorf("%s: backup number must be 1 to %d", args[0], state.MaxBackups)
+				}
+			}
+			backup, err := state.Restore(path, n)
+			if err != nil {
+				return fmt.Errorf("failed to restore state: %w", err)
+			}
+			fmt.Println(color.WhiteString("Restored state from %s (%s)",
+				backup.Path, backup.ModTime.Local().Format("2006-01-02 15:04:05")))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&c.opt.list, "list", "l", false, "list backups")
+	return cmd
+}
+
+func (c stateCmd) newStateMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate your state file to the current schema",
+		Long: templates.LongDesc(`
+			Migrate your state file to the current schema.
+
+			State file is migrated automatically when other commands run,
+			so this command is mainly to review migrations with --dry-run
+			before afx changes the state file. The state file before
+			migrations is kept as state.json.v<N> where N is its schema version.
+			`),
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(0),
+		Annotations:           map[string]string{annotationStateAsIs: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, err := c.state.PlanMigration()
+			if err != nil {
+				return fmt.Errorf("failed to plan migrations: %w", err)
+			}
+			if len(plan.Migrations) == 0 {
+				fmt.Printf("State schema is up to date (version %d)\n", plan.To)
+				return nil
+			}
+			if c.opt.dryRun {
+				fmt.Printf("State schema will be migrated from version %d to %d:\n", plan.From, plan.To)
+				for _, m := range plan.Migrations {
+					fmt.Printf("  %d: %s\n", m.Version, m.Description)
+				}
+				fmt.Printf("\n%s", plan.Diff())
+				return nil
+			}
+			plan, err = c.state.Migrate()
+			if err != nil {
+				return fmt.Errorf("failed to migrate state: %w", err)
+			}
+			fmt.Println(color.WhiteString("Migrated state schema from version %d to %d (backup: %s)",
+				plan.From, plan.To, plan.Backup))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&c.opt.dryRun, "dry-run", "n", false, "show changes without migrating")
+	return cmd
+}
+
+func (c stateCmd) newStateUnlockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unlock",
+		Short: "Remove the lock of your state file",
+		Long: templates.LongDesc(`
+			Remove the lock of your state file.
+
+			afx locks the state file while running so that other afx processes
+			don't change it at the same time, and they wait for the lock up to
+			"lock_timeout" in main (30s by default). The lock is released even
+			if afx is killed, but a hung afx process keeps holding it. In that
+			case, stop the process or remove the lock with --force.
+			`),
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{annotationStateLock: "none"},
+		Args:                  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info, err := state.Unlock(manager.StateFile(), c.opt.force)
+			if err != nil {
+				return fmt.Errorf("failed to unlock state: %w", err)
+			}
+			switch {
+			case !info.Exists:
+				fmt.Println("State is not locked")
+			case info.Held && info.Holder != nil:
+				fmt.Println(color.YellowString("Removed the state lock held by %s", info.Holder))
+			case info.Held:
+				fmt.Println(color.YellowString("Removed the state lock held by other afx processes"))
+			default:
+				fmt.Println(color.WhiteString("Removed the state lock"))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&c.opt.force, "force", "", false, "remove the lock even if it's held by a running process")
+	return cmd
+}
@@ -5,15 +5,23 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/runner"
 	"github.com/babarot/afx/internal/state"
 )
 
 type uninstallCmd struct {
 	metaCmd
+
+	opt uninstallOpt
+}
+
+type uninstallOpt struct {
+	output string
 }
 
 var (
@@ -27,6 +35,10 @@ var (
 		By default, it tries to uninstall all packages deleted from config file.
 		If any args are given, it tries to uninstall only them.
 		But it's needed also to be deleted from config file.
+
+		afx uninstall -o json
+
+		Writes events of each package as NDJSON.
 	`)
 )
 
@@ -47,9 +59,12 @@ func (m metaCmd) newUninstallCmd() *cobra.Command {
 		Args:                  cobra.MinimumNArgs(0),
 		ValidArgs:             state.Keys(m.state.Deletions),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(c.opt.output); err != nil {
+				return err
+			}
 			resources := m.state.Deletions
 			if len(resources) == 0 {
-				fmt.Println("No packages to uninstall")
+				fmt.Fprintln(messages(c.opt.output), "No packages to uninstall")
 				return nil
 			}
 
@@ -70,7 +85,7 @@ func (m metaCmd) newUninstallCmd() *cobra.Command {
 				return fmt.Errorf("failed to confirm: %w", err)
 			}
 			if !yes {
-				fmt.Println("Canceled")
+				fmt.Fprintln(messages(c.opt.output), "Canceled")
 				return nil
 			}
 
@@ -81,33 +96,62 @@ func (m metaCmd) newUninstallCmd() *cobra.Command {
 		},
 	}
 
+	addOutputFlag(uninstallCmd, &c.opt.output)
+
 	return uninstallCmd
 }
 
 func (c *uninstallCmd) run(resources []state.Resource) error {
-	var errs []error
-
-	delete := func(paths ...string) error {
-		var errs []error
-		for _, path := range paths {
-			if err := os.RemoveAll(path); err != nil {
-				errs = append(errs, err)
-			}
-		}
-		return errors.Join(errs...)
-	}
+	events := c.newEvents(c.opt.output, resources, false)
 
+	var errs []error
 	for _, resource := range resources {
-		err := delete(append(resource.Paths, resource.Home)...)
+		var started time.Time
+		if events != nil {
+			started = events.Start(resource.Name)
+		}
+		err := c.uninstallResource(resource)
 		if err != nil {
 			errs = append(errs, err)
-			continue
 		}
-		if saveErr := c.state.Remove(resource); saveErr != nil {
-			log.Printf("[ERROR] %s: failed to save state: %v", resource.Name, saveErr)
+		message := fmt.Sprintf("deleted %s", resource.Home)
+		switch {
+		case events != nil && err != nil:
+			events.Done(runner.Status{Name: resource.Name, Done: true, Err: true}, err, started)
+		case events != nil:
+			events.Done(runner.Status{Name: resource.Name, Done: true, Message: message}, nil, started)
+		case err == nil:
+			fmt.Println(message)
 		}
-		fmt.Printf("deleted %s\n", resource.Home)
+	}
+
+	if err := c.lock.Save(); err != nil {
+		log.Printf("[ERROR] failed to save lock file: %v", err)
 	}
 
 	return errors.Join(errs...)
 }
+
+// uninstallResource deletes files of a resource and removes it from the state.
+func (m metaCmd) uninstallResource(resource state.Resource) error {
+	paths := append(resource.Paths, resource.Home)
+	for _, gen := range resource.Generations {
+		paths = append(paths, gen.Dir)
+	}
+
+	var errs []error
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if saveErr := m.state.Remove(resource); saveErr != nil {
+		log.Printf("[ERROR] %s: failed to save state: %v", resource.Name, saveErr)
+	}
+	m.lock.Delete(resource.Name)
+	return nil
+}
@@ -5,10 +5,17 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/generation"
+	"github.com/babarot/afx/internal/github"
 	"github.com/babarot/afx/internal/helpers/templates"
+	"github.com/babarot/afx/internal/lock"
 	manager "github.com/babarot/afx/internal/manager"
 	"github.com/babarot/afx/internal/runner"
 	"github.com/babarot/afx/internal/state"
@@ -16,6 +23,13 @@ import (
 
 type updateCmd struct {
 	metaCmd
+
+	opt updateOpt
+}
+
+type updateOpt struct {
+	output   string
+	parallel int
 }
 
 var (
@@ -27,8 +41,14 @@ var (
 		afx update [args...]
 
 		By default, it tries to update packages only if something are
-		changed in config file.
+		changed in config file, or a newer release matching a semver
+		constraint tag (e.g. "~1.4") has been published.
 		If any args are given, it tries to update only them.
+
+		afx update -o json
+
+		Writes events of each package as NDJSON instead of
+		printing progress.
 	`)
 )
 
@@ -46,11 +66,21 @@ func (m metaCmd) newUpdateCmd() *cobra.Command {
 		SilenceUsage:          true,
 		SilenceErrors:         true,
 		Args:                  cobra.MinimumNArgs(0),
-		ValidArgs:             state.Keys(m.state.Changes),
+		// packages updatable by constraints or channels are resolved
+		// only when completing, as it needs to ask their releases
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return state.Keys(m.updatable(context.Background())), cobra.ShellCompDirectiveNoFileComp
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			resources := m.state.Changes
+			if err := validateOutput(c.opt.output); err != nil {
+				return err
+			}
+			if err := validateParallel(c.opt.parallel); err != nil {
+				return err
+			}
+			resources := m.updatable(context.Background())
 			if len(resources) == 0 {
-				fmt.Println("No packages to update")
+				fmt.Fprintln(messages(c.opt.output), "No packages to update")
 				return nil
 			}
 
@@ -71,73 +101,192 @@ func (m metaCmd) newUpdateCmd() *cobra.Command {
 				return fmt.Errorf("failed to confirm: %w", err)
 			}
 			if !yes {
-				fmt.Println("Canceled")
+				fmt.Fprintln(messages(c.opt.output), "Canceled")
 				return nil
 			}
 
 			pkgs := m.GetPackages(resources)
-			m.env.AskWhen(map[string]bool{
+			if err := m.env.AskWhen(map[string]bool{
 				"GITHUB_TOKEN":      manager.HasGitHubReleaseBlock(pkgs),
 				"AFX_SUDO_PASSWORD": manager.HasSudoInCommandBuildSteps(pkgs),
-			})
+			}); err != nil {
+				return err
+			}
 
-			return c.run(pkgs)
+			return c.run(pkgs, resources)
 		},
 		PostRunE: func(cmd *cobra.Command, args []string) error {
 			return m.printForUpdate()
 		},
 	}
 
+	addOutputFlag(updateCmd, &c.opt.output)
+	addParallelFlag(updateCmd, &c.opt.parallel)
+
 	return updateCmd
 }
 
-func (c *updateCmd) run(pkgs []manager.Package) error {
+func (c *updateCmd) run(pkgs []manager.Package, resources []state.Resource) error {
 	log.Printf("[DEBUG] (update): start to run each pkg.Install()")
 
+	c.seedLock(context.Background())
+
 	runnerPkgs := make([]runner.Package, len(pkgs))
 	for i, p := range pkgs {
 		runnerPkgs[i] = p
 	}
 
+	events := c.newEvents(c.opt.output, resources, true)
 	err := runner.Execute(runnerPkgs, func(p runner.Package) runner.TaskFunc {
 		pkg, _ := p.(manager.Package)
-		return func(ctx context.Context, completion chan<- runner.Status) error {
-			home := pkg.GetHome()
-			backup := home + ".bak"
-
-			// Backup existing installation before updating
-			if _, err := os.Stat(home); err == nil {
-				_ = os.RemoveAll(backup) // remove stale backup if any
-				if err := os.Rename(home, backup); err != nil {
-					log.Printf("[WARN] %s: failed to backup, falling back to remove: %v", pkg.GetName(), err)
-					_ = os.RemoveAll(home)
-				}
-			}
+		return c.updateTask(pkg)
+	}, runner.WithEvents(events), c.concurrency(c.opt.parallel))
 
-			err := pkg.Install(ctx, completion)
-			switch err {
-			case nil:
-				if saveErr := c.state.Update(pkg); saveErr != nil {
-					log.Printf("[ERROR] %s: failed to save state: %v", pkg.GetName(), saveErr)
-				}
-				_ = os.RemoveAll(backup) // clean up backup on success
-			default:
-				// Restore backup on failure
-				if _, statErr := os.Stat(backup); statErr == nil {
-					log.Printf("[DEBUG] restoring %q from backup after failed update", pkg.GetName())
-					_ = os.RemoveAll(home)
-					_ = os.Rename(backup, home)
-				} else {
-					log.Printf("[DEBUG] uninstall %q because updating failed (no backup)", pkg.GetName())
-					_ = pkg.Uninstall(ctx)
-				}
-			}
-			return err
-		}
-	})
+	if saveErr := c.lock.Save(); saveErr != nil {
+		log.Printf("[ERROR] failed to save lock file: %v", saveErr)
+	}
 
 	if err != nil {
 		_ = c.env.Refresh()
 	}
 	return err
 }
+
+// updatable returns the resources changed in config file and the ones
+// whose constraint or channel resolves a newer version
+func (m metaCmd) updatable(ctx context.Context) []state.Resource {
+	return slices.Concat(m.state.Changes, m.resolveChanges(ctx))
+}
+
+// resolveChanges resolves semver constraint tags of installed packages and
+// returns the resources whose resolved version differs from the installed one.
+func (m metaCmd) resolveChanges(ctx context.Context) []state.Resource {
+	ctx = github.NewContext(ctx, m.githubOpts...)
+	var resources []state.Resource
+	for _, resource := range m.state.NoChanges {
+		pkg := m.GetPackage(resource)
+		resolver, ok := pkg.(manager.Resolver)
+		if !ok {
+			continue
+		}
+		if err := resolver.Resolve(ctx); err != nil {
+			log.Printf("[ERROR] %v", err)
+			continue
+		}
+		resolved := pkg.GetResource()
+		installed, ok := m.state.Resources[resolved.ID]
+		if !ok || resolved.Version == "" || resolved.Version == installed.Version {
+			continue
+		}
+		log.Printf("[DEBUG] %s: %s -> %s", resolved.Name, installed.Version, resolved.Version)
+		resources = append(resources, resolved)
+	}
+	return resources
+}
+
+// updateTask reinstalls a package and updates it in the state.
+// The previous installation is restored if the update failed.
+func (m metaCmd) updateTask(pkg manager.Package) runner.TaskFunc {
+	return func(ctx context.Context, completion chan<- runner.Status) error {
+		home := pkg.GetHome()
+		backup := home + ".bak"
+
+		// links are looked into before they're replaced by the update
+		installed, _ := m.state.Installed(pkg.GetResource().ID)
+		links := generation.Links(installed.Paths, installed.Home)
+		locked, _ := m.lock.Get(pkg.GetName())
+
+		// Backup existing installation before updating
+		if _, err := os.Stat(home); err == nil {
+			_ = os.RemoveAll(backup) // remove stale backup if any
+			if err := os.Rename(home, backup); err != nil {
+				log.Printf("[WARN] %s: failed to backup, falling back to remove: %v", pkg.GetName(), err)
+				_ = os.RemoveAll(home)
+			}
+		}
+
+		ctx = lock.NewContext(ctx, m.lock)
+		ctx = cache.NewContext(ctx, m.cache)
+		ctx = github.NewContext(ctx, m.githubOpts...)
+		err := pkg.Install(ctx, completion)
+		switch err {
+		case nil:
+			resource := pkg.GetResource()
+			if gens, ok := m.keepGeneration(installed, links, locked, backup); ok {
+				resource.Generations = gens
+			}
+			if saveErr := m.state.Update(resource); saveErr != nil {
+				log.Printf("[ERROR] %s: failed to save state: %v", pkg.GetName(), saveErr)
+			}
+			_ = os.RemoveAll(backup) // clean up backup on success
+		default:
+			// Restore backup on failure
+			if _, statErr := os.Stat(backup); statErr == nil {
+				log.Printf("[DEBUG] restoring %q from backup after failed update", pkg.GetName())
+				_ = os.RemoveAll(home)
+				_ = os.Rename(backup, home)
+			} else {
+				log.Printf("[DEBUG] uninstall %q because updating failed (no backup)", pkg.GetName())
+				_ = pkg.Uninstall(ctx)
+			}
+		}
+		return err
+	}
+}
+
+// keepGeneration keeps the backup of a previous installation as a generation
+// to roll back to, and drops old generations beyond the number to be kept.
+// It returns generations of the resource if they're changed
+func (m metaCmd) keepGeneration(installed state.Resource, links []state.Link, locked lock.Package, backup string) ([]state.Generation, bool) {
+	if installed.ID == "" {
+		return nil, false
+	}
+	keep := m.main.KeepGenerations()
+	if keep == 0 {
+		if len(installed.Generations) == 0 {
+			return nil, false
+		}
+		for _, g := range installed.Generations {
+			_ = os.RemoveAll(g.Dir)
+		}
+		return []state.Generation{}, true
+	}
+	if _, err := os.Stat(backup); err != nil {
+		return nil, false
+	}
+
+	gen := state.Generation{
+		Name:    generation.Name(installed.Version, locked.Commit, time.Now()),
+		Version: installed.Version,
+		Paths:   installed.Paths,
+		Links:   links,
+	}
+	if locked.Type != "" {
+		gen.Lock = &locked
+	}
+	gen.Dir = generation.Dir(manager.GenerationsDir(), installed.ID, gen.Name)
+
+	_ = os.RemoveAll(gen.Dir)
+	if err := os.MkdirAll(filepath.Dir(gen.Dir), 0755); err != nil {
+		log.Printf("[ERROR] %s: failed to keep generation: %v", installed.Name, err)
+		return nil, false
+	}
+	if err := os.Rename(backup, gen.Dir); err != nil {
+		log.Printf("[ERROR] %s: failed to keep generation: %v", installed.Name, err)
+		return nil, false
+	}
+	log.Printf("[DEBUG] %s: kept %s as generation %s", installed.Name, installed.Home, gen.Name)
+
+	gens := []state.Generation{gen}
+	for _, g := range installed.Generations {
+		if g.Dir != gen.Dir {
+			gens = append(gens, g)
+		}
+	}
+	gens, dropped := generation.Keep(gens, keep)
+	for _, g := range dropped {
+		log.Printf("[DEBUG] %s: drop generation %s", installed.Name, g.Name)
+		_ = os.RemoveAll(g.Dir)
+	}
+	return gens, true
+}
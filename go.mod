@@ -24,6 +24,7 @@ require (
 	github.com/russross/blackfriday v1.6.0
 	github.com/schollz/progressbar/v3 v3.13.0
 	github.com/spf13/cobra v1.6.1
+	golang.org/x/crypto v0.36.0
 	golang.org/x/sync v0.17.0
 	golang.org/x/term v0.30.0
 )
@@ -65,7 +66,6 @@ require (
 	github.com/ulikunitz/xz v0.5.15 // indirect
 	github.com/xanzy/go-gitlab v0.80.2 // indirect
 	go4.org v0.0.0-20230225012048-214862532bf5 // indirect
-	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/net v0.38.0 // indirect
 	golang.org/x/oauth2 v0.5.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
@@ -0,0 +1,159 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mholt/archives"
+)
+
+// Version is a version of the bundle layout
+const Version = 1
+
+// Layout of a bundle.
+// A bundle is a tar.zst archive of a directory which has these files
+const (
+	// ConfigDir has config files with the same structure as the config directory
+	ConfigDir = "config"
+	// LockFile is afx.lock which pins what is bundled
+	LockFile = "afx.lock"
+	// CacheDir is a download cache which has release assets and HTTP artifacts
+	CacheDir = "cache"
+	// GitDir has git bundles of cloned repositories
+	GitDir = "git"
+
+	manifestFile = "manifest.json"
+)
+
+// Manifest describes what is in a bundle
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Repositories maps clone URLs to git bundles in the bundle
+	Repositories map[string]string `json:"repositories"`
+}
+
+// format is a format of bundle files
+var format = archives.CompressedArchive{
+	Archival:    archives.Tar{},
+	Extraction:  archives.Tar{},
+	Compression: archives.Zstd{},
+}
+
+// Pack writes a manifest into dir and archives dir into a bundle file
+func Pack(ctx context.Context, dir string, manifest Manifest, out string) error {
+	manifest.Version = Version
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), append(b, '\n'), 0644); err != nil {
+		return err
+	}
+
+	files, err := archives.FilesFromDisk(ctx, nil, map[string]string{
+		dir + string(os.PathSeparator): "",
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := format.Archive(ctx, f, files); err != nil {
+		os.Remove(out)
+		return fmt.Errorf("%s: failed to archive: %w", out, err)
+	}
+	return f.Close()
+}
+
+// Unpack extracts a bundle file into dir and returns its manifest
+func Unpack(ctx context.Context, path, dir string) (Manifest, error) {
+	var manifest Manifest
+
+	f, err := os.Open(path)
+	if err != nil {
+		return manifest, err
+	}
+	defer f.Close()
+
+	err = format.Extract(ctx, f, func(ctx context.Context, info archives.FileInfo) error {
+		dest := filepath.Join(dir, info.NameInArchive)
+		if !strings.HasPrefix(filepath.Clean(dest), filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path in bundle: %s", info.NameInArchive)
+		}
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			// bundles have only regular files
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		src, err := info.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+		_, err = io.Copy(dst, src)
+		return err
+	})
+	if err != nil {
+		return manifest, fmt.Errorf("%s: failed to extract bundle: %w", path, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return manifest, fmt.Errorf("%s: not an afx bundle: %w", path, err)
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return manifest, fmt.Errorf("%s: broken manifest: %w", path, err)
+	}
+	if manifest.Version > Version {
+		return manifest, fmt.Errorf("%s: bundle version %d is not supported, please update afx", path, manifest.Version)
+	}
+	return manifest, nil
+}
+
+// GitBundles returns paths of git bundles extracted in dir, keyed by clone URLs
+func (m Manifest) GitBundles(dir string) map[string]string {
+	bundles := make(map[string]string, len(m.Repositories))
+	for url, name := range m.Repositories {
+		bundles[url] = filepath.Join(dir, filepath.FromSlash(name))
+	}
+	return bundles
+}
+
+type contextKey struct{}
+
+// NewContext returns a new context carrying git bundles keyed by clone URLs,
+// which repositories are cloned from instead of their URLs
+func NewContext(ctx context.Context, bundles map[string]string) context.Context {
+	return context.WithValue(ctx, contextKey{}, bundles)
+}
+
+// GitBundle returns a git bundle in ctx to clone the repository of url from.
+// Only the exact URL matches, unlike url.<base>.insteadOf of git
+func GitBundle(ctx context.Context, url string) (string, bool) {
+	bundles, _ := ctx.Value(contextKey{}).(map[string]string)
+	path, ok := bundles[url]
+	return path, ok
+}
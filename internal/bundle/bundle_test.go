@@ -0,0 +1,108 @@
+package bundle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPack_Unpack(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"config/afx.yaml":            "github: []\n",
+		"config/sub/http.yaml":       "http: []\n",
+		"afx.lock":                   "{}\n",
+		"git/0.bundle":               "bundle",
+		"cache/blobs/sha256/abcdef0": "blob",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := Manifest{
+		Version:      Version,
+		CreatedAt:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Repositories: map[string]string{"https://github.com/babarot/enhancd": "git/0.bundle"},
+	}
+	out := filepath.Join(t.TempDir(), "out.tar.zst")
+	if err := Pack(context.Background(), src, want, out); err != nil {
+		t.Fatalf("Pack() error: %v", err)
+	}
+
+	dir := t.TempDir()
+	got, err := Unpack(context.Background(), out, dir)
+	if err != nil {
+		t.Fatalf("Unpack() error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("manifest mismatch (-want +got):\n%s", diff)
+	}
+	for name, content := range files {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: not extracted: %v", name, err)
+			continue
+		}
+		if string(b) != content {
+			t.Errorf("%s: content = %q, want %q", name, b, content)
+		}
+	}
+}
+
+func TestUnpack_notBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.tar.zst")
+	if err := os.WriteFile(path, []byte("not a bundle"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unpack(context.Background(), path, t.TempDir()); err == nil {
+		t.Error("Unpack() expected error for non-bundle file")
+	}
+}
+
+func TestManifest_GitBundles(t *testing.T) {
+	m := Manifest{Repositories: map[string]string{
+		"https://gitlab.com/owner/tool.git":  "git/1.bundle",
+		"https://github.com/babarot/enhancd": "git/0.bundle",
+	}}
+	want := map[string]string{
+		"https://github.com/babarot/enhancd": filepath.Join("/tmp/b", "git", "0.bundle"),
+		"https://gitlab.com/owner/tool.git":  filepath.Join("/tmp/b", "git", "1.bundle"),
+	}
+	if diff := cmp.Diff(want, m.GitBundles("/tmp/b")); diff != "" {
+		t.Errorf("GitBundles() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGitBundle(t *testing.T) {
+	ctx := NewContext(context.Background(), map[string]string{
+		"https://github.com/owner/foo": "/tmp/b/git/0.bundle",
+	})
+	tests := map[string]struct {
+		ctx  context.Context
+		url  string
+		want string
+		ok   bool
+	}{
+		"bundled":          {ctx: ctx, url: "https://github.com/owner/foo", want: "/tmp/b/git/0.bundle", ok: true},
+		"prefix of bundle": {ctx: ctx, url: "https://github.com/owner/foo-bar"},
+		"no bundles":       {ctx: context.Background(), url: "https://github.com/owner/foo"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := GitBundle(tt.ctx, tt.url)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("GitBundle() = (%q, %v), want (%q, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
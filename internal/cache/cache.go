@@ -0,0 +1,564 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+// DefaultMaxSize is a default size limit of the cache
+const DefaultMaxSize int64 = 1 << 30
+
+// Cache is a download cache shared across packages and updates.
+//
+// Downloaded files are stored in content-addressed blobs (blobs/sha256/<digest>)
+// and indexed by their URLs (index/<sha256 of URL>.json) with ETag and
+// Last-Modified, so that the same file is stored only once even if it's
+// downloaded from several URLs, and re-downloading is done by conditional requests.
+type Cache struct {
+	dir     string
+	maxSize int64
+	client  *http.Client
+	offline bool
+
+	mu sync.Mutex
+}
+
+// Entry is an index of a cached file
+type Entry struct {
+	URL          string    `json:"url"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	UsedAt       time.Time `json:"used_at"`
+}
+
+type Option func(c *Cache)
+
+// WithMaxSize sets a size limit of the cache.
+// Least recently used files are evicted when the cache exceeds it
+func WithMaxSize(size int64) Option {
+	return func(c *Cache) {
+		c.maxSize = size
+	}
+}
+
+// WithClient sets a HTTP client to download files
+func WithClient(client *http.Client) Option {
+	return func(c *Cache) {
+		c.client = client
+	}
+}
+
+// WithOffline never accesses the network, e.g. to install from a bundle.
+// Files which are not cached can't be fetched
+func WithOffline() Option {
+	return func(c *Cache) {
+		c.offline = true
+	}
+}
+
+// New returns a cache placed in a given directory
+func New(dir string, opts ...Option) *Cache {
+	c := &Cache{
+		dir:     dir,
+		maxSize: DefaultMaxSize,
+		client:  http.DefaultClient,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Dir returns a directory of the cache
+func (c *Cache) Dir() string {
+	if c == nil {
+		return ""
+	}
+	return c.dir
+}
+
+// Offline returns true if the cache never accesses the network
+func (c *Cache) Offline() bool {
+	if c == nil {
+		return false
+	}
+	return c.offline
+}
+
+// Has returns true if a file with a given digest is cached
+func (c *Cache) Has(digest string) bool {
+	if c == nil || digest == "" {
+		return false
+	}
+	_, err := os.Stat(c.blobPath(strings.ToLower(digest)))
+	return err == nil
+}
+
+type fetchOptions struct {
+	client   *http.Client
+	digest   string
+	limit    int64
+	progress func(size int64) io.Writer
+}
+
+type FetchOption func(o *fetchOptions)
+
+// WithDigest gives a known SHA-256 digest of the file.
+// If the file with the digest is cached, it's used without any requests
+func WithDigest(digest string) FetchOption {
+	return func(o *fetchOptions) {
+		o.digest = strings.ToLower(digest)
+	}
+}
+
+// WithHTTPClient downloads the file with a given client instead of
+// the one of the cache, e.g. to authenticate requests to its host
+func WithHTTPClient(client *http.Client) FetchOption {
+	return func(o *fetchOptions) {
+		o.client = client
+	}
+}
+
+// WithLimit limits a size of the file to be downloaded
+func WithLimit(limit int64) FetchOption {
+	return func(o *fetchOptions) {
+		o.limit = limit
+	}
+}
+
+// WithProgress shows a progress of downloading with a writer returned by fn.
+// fn is given a size of the file (-1 if unknown)
+func WithProgress(fn func(size int64) io.Writer) FetchOption {
+	return func(o *fetchOptions) {
+		o.progress = fn
+	}
+}
+
+// Fetch writes a file at a given URL to w from the cache or the network.
+//
+// A file is used without any requests if its digest is given and cached.
+// Otherwise a file cached for the URL is revalidated with a conditional request,
+// and it's also used if the network is unavailable.
+// All methods of Cache can be safely called with nil, which always downloads files.
+func (c *Cache) Fetch(ctx context.Context, url string, w io.Writer, opts ...FetchOption) (Entry, error) {
+	var o fetchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if c == nil {
+		client := http.DefaultClient
+		if o.client != nil {
+			client = o.client
+		}
+		return download(ctx, client, url, nil, w, o)
+	}
+
+	if o.digest != "" {
+		if entry, ok := c.lookup(url, o.digest); ok {
+			log.Printf("[DEBUG] cache: %s: hit by digest %s", url, o.digest)
+			return c.serve(entry, w)
+		}
+	}
+
+	entry, cached := c.lookup(url, "")
+	if c.offline {
+		if !cached {
+			return Entry{}, fmt.Errorf("%s: not cached for offline use", url)
+		}
+		return c.serve(entry, w)
+	}
+
+	header := http.Header{}
+	if cached {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	tmp, err := c.tempFile()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	client := c.client
+	if o.client != nil {
+		client = o.client
+	}
+	got, err := download(ctx, client, url, header, io.MultiWriter(tmp, w), o)
+	var netErr *networkError
+	switch {
+	case cached && errors.Is(err, errNotModified):
+		log.Printf("[DEBUG] cache: %s: not modified", url)
+		return c.serve(entry, w)
+	case cached && errors.As(err, &netErr):
+		log.Printf("[WARN] cache: %s: using cached file because of network error: %v", url, netErr.err)
+		return c.serve(entry, w)
+	case err != nil:
+		return got, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return got, err
+	}
+	if err := c.store(tmp.Name(), got); err != nil {
+		// it's not fatal because the file has been already written
+		log.Printf("[WARN] cache: %s: failed to store: %v", url, err)
+	}
+	return got, nil
+}
+
+// Add stores a file read from r as the one at a given URL
+func (c *Cache) Add(url string, r io.Reader) (Entry, error) {
+	tmp, err := c.tempFile()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Entry{}, err
+	}
+
+	now := time.Now()
+	entry := Entry{
+		URL:       url,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Size:      size,
+		FetchedAt: now,
+		UsedAt:    now,
+	}
+	return entry, c.store(tmp.Name(), entry)
+}
+
+// errNotModified is returned when the server returns 304
+var errNotModified = errors.New("not modified")
+
+// networkError is an error on sending a request
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string { return e.err.Error() }
+func (e *networkError) Unwrap() error { return e.err }
+
+// download gets a file at url and writes it to w
+func download(ctx context.Context, client *http.Client, url string, header http.Header, w io.Writer, o fetchOptions) (Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Entry{}, &networkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return Entry{}, errNotModified
+	default:
+		return Entry{}, transient.FromStatus(resp.StatusCode, fmt.Errorf("%s: failed to download: %s", url, resp.Status))
+	}
+
+	var body io.Reader = resp.Body
+	if o.limit > 0 {
+		body = io.LimitReader(body, o.limit)
+	}
+	hash := sha256.New()
+	w = io.MultiWriter(w, hash)
+	if o.progress != nil {
+		w = io.MultiWriter(w, o.progress(resp.ContentLength))
+	}
+	size, err := io.Copy(w, body)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if o.digest != "" && o.digest != digest {
+		log.Printf("[DEBUG] cache: %s: digest %s is different from given one %s", url, digest, o.digest)
+	}
+
+	now := time.Now()
+	return Entry{
+		URL:          url,
+		SHA256:       digest,
+		Size:         size,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    now,
+		UsedAt:       now,
+	}, nil
+}
+
+// lookup returns an entry for url. If digest is given, it returns the entry
+// with the digest even if it's cached for another URL.
+func (c *Cache) lookup(url, digest string) (Entry, bool) {
+	if digest != "" {
+		if _, err := os.Stat(c.blobPath(digest)); err != nil {
+			return Entry{}, false
+		}
+		entry, err := c.readIndex(url)
+		if err != nil || entry.SHA256 != digest {
+			// cached for another URL
+			entry = Entry{URL: url, SHA256: digest, FetchedAt: time.Now()}
+		}
+		return entry, true
+	}
+
+	entry, err := c.readIndex(url)
+	if err != nil {
+		return Entry{}, false
+	}
+	if _, err := os.Stat(c.blobPath(entry.SHA256)); err != nil {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// serve writes a cached file of the entry to w, verifying its digest
+func (c *Cache) serve(entry Entry, w io.Writer) (Entry, error) {
+	path := c.blobPath(entry.SHA256)
+	f, err := os.Open(path)
+	if err != nil {
+		return entry, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), f)
+	if err != nil {
+		return entry, err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != entry.SHA256 {
+		f.Close()
+		os.Remove(path)
+		return entry, fmt.Errorf("%s: cached file is corrupted (sha256: %s)", entry.URL, got)
+	}
+
+	entry.Size = size
+	entry.UsedAt = time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.writeIndex(entry); err != nil {
+		log.Printf("[WARN] cache: %s: failed to update index: %v", entry.URL, err)
+	}
+	return entry, nil
+}
+
+// store moves a downloaded file into blobs and indexes it
+func (c *Cache) store(tmp string, entry Entry) error {
+	if err := c.put(tmp, entry); err != nil {
+		return err
+	}
+	if c.maxSize > 0 {
+		if _, err := c.Prune(c.maxSize); err != nil {
+			log.Printf("[WARN] cache: failed to evict: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *Cache) put(tmp string, entry Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blob := c.blobPath(entry.SHA256)
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, blob); err != nil {
+		return err
+	}
+	return c.writeIndex(entry)
+}
+
+func (c *Cache) tempFile() (*os.File, error) {
+	dir := filepath.Join(c.dir, "tmp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, "download-*")
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.dir, "blobs", "sha256", digest)
+}
+
+func (c *Cache) indexPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, "index", hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) readIndex(url string) (Entry, error) {
+	var entry Entry
+	b, err := os.ReadFile(c.indexPath(url))
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return entry, err
+	}
+	if entry.URL != url || entry.SHA256 == "" {
+		return entry, fmt.Errorf("%s: invalid index", url)
+	}
+	return entry, nil
+}
+
+// writeIndex writes the entry. The caller must hold c.mu
+func (c *Cache) writeIndex(entry Entry) error {
+	path := c.indexPath(entry.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// List returns cached entries, recently used ones first
+func (c *Cache) List() ([]Entry, error) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list()
+}
+
+func (c *Cache) list() ([]Entry, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "index", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, file := range files {
+		var entry Entry
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &entry); err != nil || len(entry.SHA256) != sha256.Size*2 {
+			log.Printf("[WARN] cache: %s: broken index: %v", file, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].UsedAt.After(entries[j].UsedAt)
+	})
+	return entries, nil
+}
+
+// Prune evicts least recently used entries until the cache gets smaller than
+// maxSize, and deletes files which are no longer indexed. It returns evicted entries
+func (c *Cache) Prune(maxSize int64) ([]Entry, error) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	var evicted []Entry
+	used := map[string]bool{}
+	for _, entry := range entries {
+		size := entry.Size
+		if used[entry.SHA256] {
+			// stored once even if it's downloaded from several URLs
+			size = 0
+		}
+		if total+size > maxSize {
+			log.Printf("[DEBUG] cache: evict %s", entry.URL)
+			if err := os.Remove(c.indexPath(entry.URL)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return evicted, err
+			}
+			evicted = append(evicted, entry)
+			continue
+		}
+		total += size
+		used[entry.SHA256] = true
+	}
+
+	blobs, err := filepath.Glob(filepath.Join(c.dir, "blobs", "sha256", "*"))
+	if err != nil {
+		return evicted, err
+	}
+	for _, blob := range blobs {
+		if !used[filepath.Base(blob)] {
+			if err := os.Remove(blob); err != nil {
+				return evicted, err
+			}
+		}
+	}
+	return evicted, nil
+}
+
+// Clear deletes all cached files
+func (c *Cache) Clear() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return os.RemoveAll(c.dir)
+}
+
+type contextKey struct{}
+
+// NewContext returns a new context carrying the given cache.
+func NewContext(ctx context.Context, c *Cache) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the cache stored in ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Cache {
+	c, _ := ctx.Value(contextKey{}).(*Cache)
+	return c
+}
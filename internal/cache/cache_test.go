@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+func digestOf(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCache_Fetch(t *testing.T) {
+	requests, downloads := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, "content of "+r.URL.Path)
+	}))
+
+	c := New(t.TempDir())
+	ctx := context.Background()
+	fetch := func(path string, opts ...FetchOption) (string, Entry, error) {
+		var buf bytes.Buffer
+		entry, err := c.Fetch(ctx, server.URL+path, &buf, opts...)
+		return buf.String(), entry, err
+	}
+
+	got, entry, err := fetch("/a.tar.gz")
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if want := "content of /a.tar.gz"; got != want || entry.SHA256 != digestOf(want) {
+		t.Errorf("Fetch() = %q (%s), want %q", got, entry.SHA256, want)
+	}
+
+	// revalidated with ETag
+	if got, _, err := fetch("/a.tar.gz"); err != nil || got != "content of /a.tar.gz" {
+		t.Errorf("Fetch() = %q, %v", got, err)
+	}
+	if requests != 2 || downloads != 1 {
+		t.Errorf("requests = %d, downloads = %d, want 2 and 1", requests, downloads)
+	}
+
+	// no requests with a known digest
+	if _, _, err := fetch("/a.tar.gz", WithDigest(entry.SHA256)); err != nil {
+		t.Errorf("Fetch() error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+
+	// cached file is used when offline
+	server.Close()
+	if got, _, err := fetch("/a.tar.gz"); err != nil || got != "content of /a.tar.gz" {
+		t.Errorf("Fetch() offline = %q, %v", got, err)
+	}
+	if _, _, err := fetch("/b.tar.gz"); err == nil {
+		t.Error("Fetch() expected error for uncached file when offline")
+	}
+}
+
+func TestCache_Fetch_nil(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "content")
+	}))
+	defer server.Close()
+
+	var c *Cache
+	var buf bytes.Buffer
+	entry, err := c.Fetch(context.Background(), server.URL, &buf)
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if buf.String() != "content" || entry.SHA256 != digestOf("content") {
+		t.Errorf("Fetch() = %q (%s)", buf.String(), entry.SHA256)
+	}
+}
+
+func TestCache_Fetch_transient(t *testing.T) {
+	tests := map[string]struct {
+		code int
+		want bool
+	}{
+		"service unavailable": {code: http.StatusServiceUnavailable, want: true},
+		"too many requests":   {code: http.StatusTooManyRequests, want: true},
+		"not found":           {code: http.StatusNotFound, want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+			}))
+			defer server.Close()
+
+			_, err := New(t.TempDir()).Fetch(context.Background(), server.URL, io.Discard)
+			if err == nil {
+				t.Fatal("Fetch() expected error")
+			}
+			if got := transient.Is(err); got != tt.want {
+				t.Errorf("transient.Is(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte(r.URL.Path[1:2]), 100))
+	}))
+	defer server.Close()
+
+	// at most two files
+	c := New(t.TempDir(), WithMaxSize(250))
+	for _, path := range []string{"/a", "/b", "/c"} {
+		if _, err := c.Fetch(context.Background(), server.URL+path, io.Discard); err != nil {
+			t.Fatalf("Fetch() error: %v", err)
+		}
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	var urls []string
+	for _, entry := range entries {
+		urls = append(urls, entry.URL)
+	}
+	if len(urls) != 2 || urls[0] != server.URL+"/c" || urls[1] != server.URL+"/b" {
+		t.Errorf("List() = %v, want /c and /b", urls)
+	}
+
+	evicted, err := c.Prune(0)
+	if err != nil {
+		t.Fatalf("Prune() error: %v", err)
+	}
+	if len(evicted) != 2 {
+		t.Errorf("Prune() evicted %d entries, want 2", len(evicted))
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Errorf("List() after pruned = %d entries, want 0", len(entries))
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		"bytes":   {size: "512", want: 512},
+		"MB":      {size: "500MB", want: 500 << 20},
+		"GB":      {size: "2GB", want: 2 << 30},
+		"decimal": {size: "1.5 gb", want: 3 << 29},
+		"invalid": {size: "large", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.size, got, tt.want)
+			}
+		})
+	}
+}
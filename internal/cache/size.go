@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "500MB" or "2GB" (in binary units)
+func ParseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range units {
+		if num, ok := strings.CutSuffix(v, unit.suffix); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("%q: invalid size", s)
+			}
+			return int64(n * float64(unit.size)), nil
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q: invalid size", s)
+	}
+	return n, nil
+}
+
+// FormatSize formats a size in a human readable way
+func FormatSize(size int64) string {
+	for _, unit := range units {
+		if size >= unit.size && unit.size > 1 {
+			return fmt.Sprintf("%.1f%s", float64(size)/float64(unit.size), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
@@ -0,0 +1,133 @@
+package checksum
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+// MismatchError is returned when a digest of downloaded file
+// does not match the expected one.
+type MismatchError struct {
+	Name string
+	Want string
+	Got  string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s: checksum mismatch (want sha256:%s, got sha256:%s)", e.Name, e.Want, e.Got)
+}
+
+var sha256RE = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// bsdRE matches BSD style line: "SHA256 (filename) = digest"
+var bsdRE = regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-fA-F]{64})$`)
+
+// Normalize validates a given SHA-256 digest and returns it as lower hex.
+// The digest can be prefixed with "sha256:".
+func Normalize(digest string) (string, error) {
+	d := strings.ToLower(strings.TrimSpace(digest))
+	d = strings.TrimPrefix(d, "sha256:")
+	if !sha256RE.MatchString(d) {
+		return "", fmt.Errorf("%q: not valid sha256 digest", digest)
+	}
+	return d, nil
+}
+
+// Verify compares a given digest with the expected one.
+func Verify(name, want, got string) error {
+	w, err := Normalize(want)
+	if err != nil {
+		return err
+	}
+	g, err := Normalize(got)
+	if err != nil {
+		return err
+	}
+	if w != g {
+		return &MismatchError{Name: name, Want: w, Got: g}
+	}
+	log.Printf("[DEBUG] %s: checksum verified (sha256:%s)", name, g)
+	return nil
+}
+
+// Sum returns a hex-encoded SHA-256 digest of the file.
+func Sum(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Parse finds a digest of a given file name from a checksum file.
+// Both GNU coreutils style ("digest  filename", "digest *filename")
+// and BSD style ("SHA256 (filename) = digest") are supported.
+func Parse(r io.Reader, filename string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var digest, name string
+		if m := bsdRE.FindStringSubmatch(line); m != nil {
+			name, digest = m[1], m[2]
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			digest, name = fields[0], strings.TrimPrefix(fields[1], "*")
+		}
+
+		if path.Base(name) != filename {
+			continue
+		}
+		return Normalize(digest)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: not found in checksum file", filename)
+}
+
+// Fetch downloads a checksum file from a given URL with a given client
+// and returns a digest of a given file name written in it.
+func Fetch(ctx context.Context, client *http.Client, url, filename string) (string, error) {
+	log.Printf("[DEBUG] fetching checksum file: %s", url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", transient.FromStatus(resp.StatusCode, fmt.Errorf("%s: failed to get checksum file: %s", url, resp.Status))
+	}
+
+	// checksum files are small enough
+	const maxSize = 1 << 20
+	return Parse(io.LimitReader(resp.Body, maxSize), filename)
+}
@@ -0,0 +1,135 @@
+package checksum
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+const (
+	digestA = "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+	digestB = "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]struct {
+		digest  string
+		want    string
+		wantErr bool
+	}{
+		"plain":        {digest: digestA, want: digestA},
+		"prefixed":     {digest: "sha256:" + digestA, want: digestA},
+		"upper case":   {digest: strings.ToUpper(digestA), want: digestA},
+		"too short":    {digest: "abcd", wantErr: true},
+		"not hex":      {digest: strings.Repeat("z", 64), wantErr: true},
+		"other prefix": {digest: "md5:" + digestA, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Normalize(tt.digest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	content := strings.Join([]string{
+		"# comment",
+		digestA + "  tool_linux_amd64.tar.gz",
+		digestB + " *tool_darwin_amd64.tar.gz",
+		"SHA256 (dist/tool_windows_amd64.zip) = " + digestA,
+		"",
+	}, "\n")
+
+	tests := map[string]struct {
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		"gnu style":        {filename: "tool_linux_amd64.tar.gz", want: digestA},
+		"gnu binary style": {filename: "tool_darwin_amd64.tar.gz", want: digestB},
+		"bsd style":        {filename: "tool_windows_amd64.zip", want: digestA},
+		"not found":        {filename: "tool_linux_arm64.tar.gz", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(content), tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if err := Verify("tool", "sha256:"+digestA, digestA); err != nil {
+		t.Errorf("Verify() error: %v", err)
+	}
+
+	err := Verify("tool", digestA, digestB)
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Verify() error = %v, want MismatchError", err)
+	}
+	if mismatch.Want != digestA || mismatch.Got != digestB {
+		t.Errorf("MismatchError = %#v", mismatch)
+	}
+}
+
+func TestSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Sum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != digestB {
+		t.Errorf("Sum() = %q, want %q", got, digestB)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/checksums.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, digestA+"  tool.tar.gz\n")
+	}))
+	defer server.Close()
+
+	got, err := Fetch(context.Background(), http.DefaultClient, server.URL+"/checksums.txt", "tool.tar.gz")
+	if err != nil {
+		t.Fatalf("Fetch() error: %v", err)
+	}
+	if got != digestA {
+		t.Errorf("Fetch() = %q, want %q", got, digestA)
+	}
+
+	if _, err := Fetch(context.Background(), http.DefaultClient, server.URL+"/missing.txt", "tool.tar.gz"); err == nil {
+		t.Error("Fetch() expected error for 404")
+	}
+}
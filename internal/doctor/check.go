@@ -0,0 +1,235 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/babarot/afx/internal/gh"
+	"github.com/babarot/afx/internal/git"
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/state"
+)
+
+// CommandPath checks if a directory where commands are linked is on PATH
+func CommandPath(dir, pathEnv string) Result {
+	const check = "command path"
+	for _, p := range filepath.SplitList(pathEnv) {
+		if p != "" && filepath.Clean(p) == filepath.Clean(dir) {
+			return pass(check, "%s is on PATH", dir)
+		}
+	}
+	return fail(check, "%s is not on PATH, add it to PATH to run linked commands", dir)
+}
+
+// Git checks if git command is available.
+// It's required if there're packages installed by git clone
+func Git(ctx context.Context, runner git.Runner, required bool) Result {
+	return version(ctx, "git", runner.Run, git.ErrNotInstalled, required)
+}
+
+// GH checks if gh command is available.
+// It's required if there're packages installed as gh extensions
+func GH(ctx context.Context, runner gh.Runner, required bool) Result {
+	return version(ctx, "gh", runner.Run, gh.ErrNotInstalled, required)
+}
+
+func version(ctx context.Context, check string, run func(context.Context, ...string) ([]byte, error), notInstalled error, required bool) Result {
+	out, err := run(ctx, "--version")
+	switch {
+	case errors.Is(err, notInstalled) && required:
+		return fail(check, "%s is not installed but required by packages", check)
+	case errors.Is(err, notInstalled):
+		return warn(check, "%s is not installed", check)
+	case err != nil:
+		return fail(check, "%v", err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return pass(check, "%s", line)
+}
+
+// StatePaths checks if paths of packages recorded in state exist
+func StatePaths(resources []state.Resource) []Result {
+	const check = "packages"
+	var results []Result
+	for _, resource := range resources {
+		missing := resource.Missing()
+		if len(missing) == 0 {
+			continue
+		}
+		results = append(results, fail(check, "%s: %s not found, run afx install to reinstall",
+			resource.Name, strings.Join(missing, ", ")))
+	}
+	if len(results) == 0 {
+		return []Result{pass(check, "all %d packages in state are installed", len(resources))}
+	}
+	return results
+}
+
+// Symlinks checks symlinks in a directory where commands are linked.
+// Dangling symlinks and links to non-executable files are reported.
+// Only the problems related to files under root (the data directory of afx)
+// can be fixed, as other symlinks may be created by users
+func Symlinks(dir, root string) []Result {
+	const check = "symlinks"
+	entries, err := os.ReadDir(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return []Result{warn(check, "%s does not exist", dir)}
+	case err != nil:
+		return []Result{fail(check, "%v", err)}
+	}
+
+	var results []Result
+	var links int
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		links++
+		link := filepath.Join(dir, entry.Name())
+		target, err := os.Readlink(link)
+		if err != nil {
+			results = append(results, fail(check, "%s: %v", link, err))
+			continue
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		managed := within(target, root)
+
+		fi, err := os.Stat(link)
+		if err != nil {
+			result := fail(check, "%s: dangling symlink to %s", link, target)
+			if managed {
+				result.Fix = func() error { return os.Remove(link) }
+			}
+			results = append(results, result)
+			continue
+		}
+		if fi.IsDir() || fi.Mode()&0111 != 0 {
+			continue
+		}
+		result := warn(check, "%s: %s is not executable", link, target)
+		if managed {
+			result.Fix = func() error { return os.Chmod(target, fi.Mode()|0111) }
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return []Result{pass(check, "%d symlinks in %s are valid", links, dir)}
+	}
+	return results
+}
+
+// within returns true if path is under root
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// StateFile checks if the state file can be parsed. It's not fixed
+// automatically as restoring a backup may drop recent changes
+func StateFile(path string) Result {
+	const check = "state"
+	err := state.Verify(path)
+	var outdated *state.OutdatedError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return pass(check, "nothing is installed yet")
+	case errors.As(err, &outdated):
+		return warn(check, "%v", err)
+	case err != nil:
+		return fail(check, "%v", err)
+	}
+	return pass(check, "%s is valid", path)
+}
+
+// StateLock checks if a lock file of state is left by a process which
+// was killed. A stale lock file can be removed safely
+func StateLock(path string) Result {
+	const check = "state lock"
+	info, err := state.InspectLock(path)
+	switch {
+	case err != nil:
+		return fail(check, "%v", err)
+	case info.Held && info.Holder != nil:
+		return warn(check, "%s is held by %s", info.Path, info.Holder)
+	case info.Held:
+		return warn(check, "%s is held by other afx processes", info.Path)
+	case !info.Stale():
+		return pass(check, "state is not locked")
+	}
+	result := fail(check, "%s is stale (left by %s which was killed)", info.Path, info.Holder)
+	result.Fix = func() error { return os.Remove(info.Path) }
+	return result
+}
+
+// DuplicateCommands checks if the same command name is linked by several packages.
+// Only one of them is available as the links overwrite each other
+func DuplicateCommands(pkgs []manager.Package) []Result {
+	const check = "commands"
+	owners := map[string][]string{}
+	for _, pkg := range pkgs {
+		if !pkg.HasCommandBlock() {
+			continue
+		}
+		for _, link := range pkg.GetCommandBlock().Link {
+			dest := link.Dest()
+			if !slices.Contains(owners[dest], pkg.GetName()) {
+				owners[dest] = append(owners[dest], pkg.GetName())
+			}
+		}
+	}
+
+	var dests []string
+	for dest, names := range owners {
+		if len(names) > 1 {
+			dests = append(dests, dest)
+		}
+	}
+	sort.Strings(dests)
+
+	var results []Result
+	for _, dest := range dests {
+		results = append(results, fail(check, "%s is linked by several packages: %s",
+			filepath.Base(dest), strings.Join(owners[dest], ", ")))
+	}
+	if len(results) == 0 {
+		return []Result{pass(check, "no duplicate command names")}
+	}
+	return results
+}
+
+// GitHubToken checks if a token for a given host is valid and how many
+// requests remain before hitting the rate limit. The token is looked up
+// with github.Token, so that it's taken from the secret store too
+func GitHubToken(ctx context.Context, client *github.Client, host string) Result {
+	check := fmt.Sprintf("github token (%s)", host)
+	if github.Token(host) == "" {
+		return warn(check, "no token is set in the environment, token_command or secret store, "+
+			"requests to API are strictly rate limited")
+	}
+
+	limit, err := client.ForHost(host).GetRateLimit(ctx)
+	switch {
+	case errors.Is(err, github.ErrUnauthorized):
+		return fail(check, "token is invalid or expired")
+	case errors.Is(err, github.ErrRateLimitDisabled):
+		return pass(check, "token is valid (rate limiting is not enabled)")
+	case err != nil:
+		return warn(check, "failed to check token: %v", err)
+	}
+
+	if limit.Remaining < limit.Limit/10 {
+		return warn(check, "%d/%d requests remaining until %s",
+			limit.Remaining, limit.Limit, limit.Reset.Local().Format("15:04"))
+	}
+	return pass(check, "token is valid, %d/%d requests remaining", limit.Remaining, limit.Limit)
+}
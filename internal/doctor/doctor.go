@@ -0,0 +1,92 @@
+package doctor
+
+import (
+	"fmt"
+	"log"
+)
+
+// Status is a result of a check
+type Status int
+
+const (
+	// Pass means no problems are found
+	Pass Status = iota
+	// Warn means something may not work as expected
+	Warn
+	// Fail means something doesn't work
+	Fail
+	// Fixed means a problem has been repaired by its fix
+	Fixed
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "pass"
+	case Warn:
+		return "warn"
+	case Fail:
+		return "fail"
+	case Fixed:
+		return "fixed"
+	default:
+		return "unknown"
+	}
+}
+
+// Result is a result of a check
+type Result struct {
+	Check   string
+	Status  Status
+	Message string
+
+	// Fix repairs the problem. It's nil if the problem cannot be repaired
+	// safely (e.g. files which are not managed by afx would be changed)
+	Fix func() error
+}
+
+func pass(check, format string, a ...any) Result {
+	return Result{Check: check, Status: Pass, Message: fmt.Sprintf(format, a...)}
+}
+
+func warn(check, format string, a ...any) Result {
+	return Result{Check: check, Status: Warn, Message: fmt.Sprintf(format, a...)}
+}
+
+func fail(check, format string, a ...any) Result {
+	return Result{Check: check, Status: Fail, Message: fmt.Sprintf(format, a...)}
+}
+
+// Report is results of checks
+type Report []Result
+
+// Count returns the number of results with a given status
+func (r Report) Count(status Status) int {
+	var n int
+	for _, result := range r {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Fix runs fixes of problems and returns a report where repaired
+// problems are marked as fixed
+func (r Report) Fix() Report {
+	fixed := make(Report, len(r))
+	for i, result := range r {
+		fixed[i] = result
+		if result.Status == Pass || result.Fix == nil {
+			continue
+		}
+		if err := result.Fix(); err != nil {
+			log.Printf("[ERROR] %s: failed to fix: %v", result.Check, err)
+			fixed[i].Message = fmt.Sprintf("%s (failed to fix: %v)", result.Message, err)
+			continue
+		}
+		fixed[i].Status = Fixed
+		fixed[i].Fix = nil
+	}
+	return fixed
+}
@@ -0,0 +1,340 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/env"
+	"github.com/babarot/afx/internal/gh"
+	"github.com/babarot/afx/internal/git"
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/manager"
+	"github.com/babarot/afx/internal/state"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+// statuses returns statuses of results to compare them in tests
+func statuses(results []Result) []Status {
+	var s []Status
+	for _, result := range results {
+		s = append(s, result.Status)
+	}
+	return s
+}
+
+func TestCommandPath(t *testing.T) {
+	tests := map[string]struct {
+		dir     string
+		pathEnv string
+		want    Status
+	}{
+		"on PATH": {
+			dir:     "/home/user/bin",
+			pathEnv: "/usr/bin:/home/user/bin/:/bin",
+			want:    Pass,
+		},
+		"not on PATH": {
+			dir:     "/home/user/bin",
+			pathEnv: "/usr/bin:/bin",
+			want:    Fail,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := CommandPath(tt.dir, tt.pathEnv).Status; got != tt.want {
+				t.Errorf("CommandPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		required bool
+		want     Status
+	}{
+		"installed": {
+			want: Pass,
+		},
+		"not installed": {
+			err:  gh.ErrNotInstalled,
+			want: Warn,
+		},
+		"not installed but required": {
+			err:      gh.ErrNotInstalled,
+			required: true,
+			want:     Fail,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			runner := &gh.MockRunner{
+				Responses: map[string][]byte{"--version": []byte("gh version 2.40.0\nhttps://github.com/cli/cli")},
+				Errors:    map[string]error{},
+			}
+			if tt.err != nil {
+				runner.Errors["--version"] = tt.err
+			}
+			got := GH(context.Background(), runner, tt.required)
+			if got.Status != tt.want {
+				t.Errorf("GH() = %v, want %v", got.Status, tt.want)
+			}
+			if got.Status == Pass && got.Message != "gh version 2.40.0" {
+				t.Errorf("GH() message = %q, want the first line of version", got.Message)
+			}
+		})
+	}
+
+	runner := &gh.MockRunner{Errors: map[string]error{"--version": git.ErrNotInstalled}}
+	if got := Git(context.Background(), runner, true).Status; got != Fail {
+		t.Errorf("Git() = %v, want %v", got, Fail)
+	}
+}
+
+func TestStatePaths(t *testing.T) {
+	dir := t.TempDir()
+	resources := []state.Resource{
+		{Name: "installed", Paths: []string{dir}},
+		{Name: "removed", Paths: []string{dir, filepath.Join(dir, "removed")}},
+	}
+	got := StatePaths(resources)
+	if diff := cmp.Diff([]Status{Fail}, statuses(got)); diff != "" {
+		t.Fatalf("StatePaths() mismatch (-want +got):\n%s", diff)
+	}
+	if !strings.HasPrefix(got[0].Message, "removed:") {
+		t.Errorf("StatePaths() message = %q, want the missing package", got[0].Message)
+	}
+
+	if diff := cmp.Diff([]Status{Pass}, statuses(StatePaths(resources[:1]))); diff != "" {
+		t.Errorf("StatePaths() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	root := t.TempDir()
+	bin := t.TempDir()
+	mustWrite := func(path string, mode os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("#!/bin/sh"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustLink := func(target, link string) {
+		t.Helper()
+		if err := os.Symlink(target, filepath.Join(bin, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustWrite(filepath.Join(root, "ok"), 0755)
+	mustWrite(filepath.Join(root, "noexec"), 0644)
+	mustLink(filepath.Join(root, "ok"), "ok")
+	mustLink(filepath.Join(root, "noexec"), "noexec")
+	mustLink(filepath.Join(root, "removed"), "dangling")
+	mustLink("/nonexistent/afx-doctor-test", "users-own")
+
+	got := Symlinks(bin, root)
+	if diff := cmp.Diff([]Status{Fail, Warn, Fail}, statuses(got)); diff != "" {
+		t.Fatalf("Symlinks() mismatch (-want +got):\n%s", diff)
+	}
+	for i, fixable := range []bool{true, true, false} {
+		if (got[i].Fix != nil) != fixable {
+			t.Errorf("Symlinks()[%d] (%s) fixable = %v, want %v", i, got[i].Message, got[i].Fix != nil, fixable)
+		}
+	}
+
+	fixed := Report(got).Fix()
+	if diff := cmp.Diff([]Status{Fixed, Fixed, Fail}, statuses(fixed)); diff != "" {
+		t.Fatalf("Fix() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := os.Lstat(filepath.Join(bin, "dangling")); !os.IsNotExist(err) {
+		t.Errorf("dangling symlink should be removed: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "noexec")); err != nil || fi.Mode()&0111 == 0 {
+		t.Errorf("link target should be executable: %v", err)
+	}
+	if diff := cmp.Diff([]Status{Fail}, statuses(Symlinks(bin, root))); diff != "" {
+		t.Errorf("Symlinks() after fix mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStateLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if got := StateLock(path).Status; got != Pass {
+		t.Fatalf("StateLock() without lock file = %v, want %v", got, Pass)
+	}
+
+	f, err := os.Create(path + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// lock file is kept after afx finished
+	if got := StateLock(path).Status; got != Pass {
+		t.Fatalf("StateLock() with empty lock file = %v, want %v", got, Pass)
+	}
+	if _, err := fmt.Fprintf(f, `{"pid":%d,"command":"afx install","started":"2026-10-17T01:23:00Z"}`, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+	if got := StateLock(path); got.Status != Warn || !strings.Contains(got.Message, "(afx install)") {
+		t.Errorf("StateLock() held = %v %q, want %v with the holder", got.Status, got.Message, Warn)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := StateLock(path)
+	if stale.Status != Fail || stale.Fix == nil {
+		t.Fatalf("StateLock() stale = %v (fixable: %v), want fixable %v", stale.Status, stale.Fix != nil, Fail)
+	}
+	if err := stale.Fix(); err != nil {
+		t.Fatal(err)
+	}
+	if got := StateLock(path).Status; got != Pass {
+		t.Errorf("StateLock() after fix = %v, want %v", got, Pass)
+	}
+}
+
+func TestDuplicateCommands(t *testing.T) {
+	t.Setenv("AFX_COMMAND_PATH", "/home/user/bin")
+	command := func(links ...*manager.Link) *manager.Command {
+		return &manager.Command{Link: links}
+	}
+	pkgs := []manager.Package{
+		&manager.GitHub{Name: "a", Command: command(&manager.Link{From: "bin/tool"}, &manager.Link{From: "tool.sh", To: "tool"})},
+		&manager.GitHub{Name: "b", Command: command(&manager.Link{From: "**/tool"})},
+		&manager.HTTP{Name: "c", Command: command(&manager.Link{From: "c", To: "other"})},
+		&manager.GitHub{Name: "d"},
+	}
+
+	got := DuplicateCommands(pkgs)
+	if diff := cmp.Diff([]Status{Fail}, statuses(got)); diff != "" {
+		t.Fatalf("DuplicateCommands() mismatch (-want +got):\n%s", diff)
+	}
+	if want := "tool is linked by several packages: a, b"; got[0].Message != want {
+		t.Errorf("DuplicateCommands() message = %q, want %q", got[0].Message, want)
+	}
+
+	if diff := cmp.Diff([]Status{Pass}, statuses(DuplicateCommands(pkgs[2:]))); diff != "" {
+		t.Errorf("DuplicateCommands() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGitHubToken(t *testing.T) {
+	tests := map[string]struct {
+		token  string
+		status int
+		body   string
+		want   Status
+	}{
+		"no token": {
+			want: Warn,
+		},
+		"valid": {
+			token:  "valid",
+			status: http.StatusOK,
+			body:   `{"resources":{"core":{"limit":5000,"remaining":4000,"used":1000,"reset":1700000000}}}`,
+			want:   Pass,
+		},
+		"almost rate limited": {
+			token:  "valid",
+			status: http.StatusOK,
+			body:   `{"resources":{"core":{"limit":5000,"remaining":10,"used":4990,"reset":1700000000}}}`,
+			want:   Warn,
+		},
+		"invalid": {
+			token:  "invalid",
+			status: http.StatusUnauthorized,
+			body:   `{"message":"Bad credentials"}`,
+			want:   Fail,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+			t.Setenv("GH_ENTERPRISE_TOKEN", tt.token)
+
+			client := github.NewClient(github.ReplaceTripper(server.Client().Transport))
+			if got := GitHubToken(context.Background(), client, server.URL).Status; got != tt.want {
+				t.Errorf("GitHubToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubToken_secretStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token stored" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, `{"resources":{"core":{"limit":5000,"remaining":4000,"used":1000,"reset":1700000000}}}`)
+	}))
+	defer server.Close()
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+
+	store := env.NewMemoryStore()
+	if err := store.Set("GH_ENTERPRISE_TOKEN", "stored"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := env.New(filepath.Join(t.TempDir(), "cache.json"), env.WithSecretStore(store))
+	cfg.Env["GH_ENTERPRISE_TOKEN"] = env.Variable{Secret: true}
+	orig := github.Getenv
+	github.Getenv = cfg.Getenv
+	defer func() { github.Getenv = orig }()
+
+	client := github.NewClient(github.ReplaceTripper(server.Client().Transport))
+	if got := GitHubToken(context.Background(), client, server.URL).Status; got != Pass {
+		t.Errorf("GitHubToken() with stored token = %v, want %v", got, Pass)
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if got := StateFile(path).Status; got != Pass {
+		t.Errorf("StateFile() without state = %v, want %v", got, Pass)
+	}
+	if err := os.WriteFile(path, []byte(`{"resources":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := StateFile(path).Status; got != Warn {
+		t.Errorf("StateFile() outdated = %v, want %v", got, Warn)
+	}
+	if err := os.WriteFile(path, fmt.Appendf(nil, `{"version":%d,"resources":{}}`, state.Version), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := StateFile(path).Status; got != Pass {
+		t.Errorf("StateFile() = %v, want %v", got, Pass)
+	}
+	if err := os.WriteFile(path, []byte(`{"resou`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := StateFile(path); got.Status != Fail || got.Fix != nil {
+		t.Errorf("StateFile() corrupted = %v (fixable: %v), want %v", got.Status, got.Fix != nil, Fail)
+	}
+}
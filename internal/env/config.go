@@ -1,17 +1,50 @@
 package env
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/babarot/afx/internal/printers"
 )
 
 // Config represents data of environment variables and cache file path
 type Config struct {
 	Path string              `json:"path"`
 	Env  map[string]Variable `json:"env"`
+
+	// NoInput makes Ask fail with *MissingError instead of prompting
+	NoInput bool `json:"-"`
+
+	// store keeps values of secret variables instead of the cache file
+	store SecretStore
+
+	// looked records variables already looked up by Getenv
+	looked map[string]bool
+
+	// plaintext keeps secrets cached by older versions which couldn't be
+	// moved to the store, so that they're not lost from the cache file
+	plaintext map[string]string
+}
+
+// Option configures Config
+type Option func(c *Config)
+
+// WithSecretStore sets a store of secret variables.
+// Secrets are kept only in memory by default
+func WithSecretStore(store SecretStore) Option {
+	return func(c *Config) {
+		c.store = store
+	}
 }
 
 // Variables is a collection of Variable and its name
@@ -22,6 +55,36 @@ type Variable struct {
 	Value   string `json:"value,omitempty"`
 	Default string `json:"default,omitempty"`
 	Input   Input  `json:"input"`
+
+	// Command prints the value (e.g. "gh auth token"). It's run only when
+	// the value is needed but not set, instead of prompting for it
+	Command string `json:"-"`
+
+	// Secret variables are never written to the cache file.
+	// They are kept in the secret store instead
+	Secret bool `json:"-"`
+}
+
+// MissingError is returned when values need to be input but prompting is disabled
+type MissingError struct {
+	// Variables are the variables missing, keyed by their names
+	Variables map[string]Variable
+}
+
+func (e *MissingError) Error() string {
+	var names []string
+	for name := range e.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("missing inputs in non-interactive mode:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s: %s\n", name, e.Variables[name].Input.Help)
+	}
+	b.WriteString("set them as environment variables, or paths to files containing them as <NAME>_FILE")
+	return b.String()
 }
 
 // Input represents value input from terminal
@@ -32,10 +95,15 @@ type Input struct {
 }
 
 // New creates Config instance
-func New(path string) *Config {
+func New(path string, opts ...Option) *Config {
 	cfg := &Config{
-		Path: path,
-		Env:  map[string]Variable{},
+		Path:      path,
+		Env:       map[string]Variable{},
+		store:     NewMemoryStore(),
+		plaintext: map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(cfg)
 	}
 	if _, err := os.Stat(path); err == nil {
 		// already exist
@@ -82,6 +150,9 @@ func (c *Config) add(name string, v Variable) {
 	if exist {
 		v.Value = existing.Value
 	}
+	if exist && v.Secret && existing.Value != "" {
+		c.migrate(name, existing.Value)
+	}
 
 	if v.Value != os.Getenv(name) && os.Getenv(name) != "" {
 		v.Value = os.Getenv(name)
@@ -89,6 +160,9 @@ func (c *Config) add(name string, v Variable) {
 	if v.Value == "" {
 		v.Value = os.Getenv(name)
 	}
+	if value, ok := readFile(name); ok && os.Getenv(name) == "" {
+		v.Value = value
+	}
 	if v.Value == "" {
 		v.Value = v.Default
 	}
@@ -97,43 +171,104 @@ func (c *Config) add(name string, v Variable) {
 	c.Env[name] = v
 }
 
-// Refresh deletes existing file cache
+// migrate moves a secret cached in plaintext by older versions to the secret store
+func (c *Config) migrate(name, value string) {
+	if err := c.store.Set(name, value); err != nil {
+		// kept in the cache file until the next run, not to lose the value
+		c.plaintext[name] = value
+		log.Printf("[WARN] %s: failed to move to secret store, kept in %s: %v", name, c.Path, err)
+		return
+	}
+	delete(c.plaintext, name)
+	if c.store.Persistent() {
+		log.Printf("[INFO] %s: moved from %s to secret store", name, c.Path)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s was removed from %s as it was stored in plaintext. "+
+		"Set main.secrets to keep it across sessions\n", name, c.Path)
+}
+
+// Refresh deletes existing file cache, including secrets kept in plaintext.
+// Secrets in the store are kept as they may be fine, e.g. when failed by
+// network errors. They're replaced only when the user deletes them from the store
 func (c *Config) Refresh() error {
 	return c.delete()
 }
 
 // Ask asks the user for input using the given query
-func (c *Config) Ask(keys ...string) {
-	var update bool
+func (c *Config) Ask(keys ...string) error {
+	var asked []string
 	for _, key := range keys {
-		v, found := c.Env[key]
-		if !found {
-			continue
+		if v, found := c.Env[key]; found && v.Input.When {
+			asked = append(asked, key)
 		}
-		if len(v.Value) > 0 {
-			continue
+	}
+	return c.ask(asked)
+}
+
+// AskWhen asks the user for input of variables if they're needed
+func (c *Config) AskWhen(env map[string]bool) error {
+	var asked []string
+	for key, when := range env {
+		if when {
+			asked = append(asked, key)
 		}
-		if !v.Input.When {
-			continue
+	}
+	sort.Strings(asked)
+	return c.ask(asked)
+}
+
+// getenvMu guards Getenv called by tasks running concurrently
+var getenvMu sync.Mutex
+
+// Getenv returns a value of a variable from the environment. Variables which are
+// not set are taken from their commands or the secret store if possible, so that
+// they can be used without prompting (e.g. GITHUB_TOKEN for API clients).
+// They're looked up only when needed as the store may ask for a passphrase
+func (c *Config) Getenv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	getenvMu.Lock()
+	defer getenvMu.Unlock()
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	if c.looked == nil {
+		c.looked = map[string]bool{}
+	}
+	v, found := c.Env[name]
+	if !found || c.looked[name] {
+		return ""
+	}
+	c.looked[name] = true
+
+	if v.Command != "" {
+		value, err := run(v.Command)
+		if err == nil {
+			// not cached so that it's always taken from the command
+			os.Setenv(name, value)
+			return value
 		}
-		var opts []survey.AskOpt
-		opts = append(opts, survey.WithValidator(survey.Required))
-		_ = survey.AskOne(&survey.Password{
-			Message: v.Input.Message,
-			Help:    v.Input.Help,
-		}, &v.Value, opts...)
-		c.Env[key] = v
-		os.Setenv(key, v.Value)
-		update = true
+		log.Printf("[ERROR] %s: failed to get value from command: %v", name, err)
 	}
-	if update {
-		_ = c.save()
+	if v.Secret {
+		value, err := c.store.Get(name)
+		if err == nil && value != "" {
+			os.Setenv(name, value)
+			return value
+		}
+		if err != nil && !errors.Is(err, ErrSecretNotFound) {
+			log.Printf("[ERROR] %s: failed to get value from secret store: %v", name, err)
+		}
 	}
+	return ""
 }
 
-func (c *Config) AskWhen(env map[string]bool) {
+func (c *Config) ask(keys []string) error {
 	var update bool
-	for key, when := range env {
+	missing := map[string]Variable{}
+	for _, key := range keys {
 		v, found := c.Env[key]
 		if !found {
 			continue
@@ -141,22 +276,78 @@ func (c *Config) AskWhen(env map[string]bool) {
 		if len(v.Value) > 0 {
 			continue
 		}
-		if !when {
+		if value := c.Getenv(key); value != "" {
+			continue
+		}
+		if c.NoInput {
+			missing[key] = v
 			continue
 		}
-		var opts []survey.AskOpt
-		opts = append(opts, survey.WithValidator(survey.Required))
 		_ = survey.AskOne(&survey.Password{
 			Message: v.Input.Message,
 			Help:    v.Input.Help,
-		}, &v.Value, opts...)
+		}, &v.Value, askOpts()...)
 		c.Env[key] = v
 		os.Setenv(key, v.Value)
+		if v.Secret {
+			if err := c.store.Set(key, v.Value); err != nil {
+				log.Printf("[ERROR] %s: failed to save to secret store: %v", key, err)
+			}
+			if !c.store.Persistent() {
+				fmt.Fprintf(os.Stderr, "%s is kept only while afx is running and will be asked again next time. "+
+					"Set main.secrets to keep it across sessions\n", key)
+			}
+			continue
+		}
 		update = true
 	}
 	if update {
 		_ = c.save()
 	}
+	if len(missing) > 0 {
+		return &MissingError{Variables: missing}
+	}
+	return nil
+}
+
+// run runs a command printing a value of variable
+func run(command string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	value := strings.TrimSpace(string(out))
+	if value == "" {
+		return "", fmt.Errorf("%s: printed nothing", command)
+	}
+	return value, nil
+}
+
+func askOpts() []survey.AskOpt {
+	var opts []survey.AskOpt
+	opts = append(opts, survey.WithValidator(survey.Required))
+	if !printers.IsTerminal(os.Stdout) {
+		// keep stdout for the output, e.g. events written as NDJSON
+		opts = append(opts, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	}
+	return opts
+}
+
+// readFile reads a value of variable from a file given by <NAME>_FILE
+func readFile(name string) (string, bool) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[ERROR] %s: failed to read %s_FILE: %v", name, name, err)
+		return "", false
+	}
+	return strings.TrimSpace(string(b)), true
 }
 
 func (c *Config) read() error {
@@ -178,17 +369,24 @@ func (c *Config) save() error {
 	// Remove empty variable from c.Env
 	// to avoid adding empty item to cache
 	for name, v := range c.Env {
+		if v.Secret {
+			v.Value = c.plaintext[name]
+		}
 		if v.Value == "" && v.Default == "" {
 			continue
 		}
 		cfg.Env[name] = v
 	}
 
-	f, err := os.Create(c.Path)
+	f, err := os.OpenFile(c.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	// the file may have been created with looser permissions by older versions
+	if err := f.Chmod(0600); err != nil {
+		return err
+	}
 	return json.NewEncoder(f).Encode(cfg)
 }
 
@@ -2,8 +2,10 @@ package env
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -203,6 +205,95 @@ func TestAdd_priority_fallbackToDefault(t *testing.T) {
 	}
 }
 
+func TestAdd_priority_file(t *testing.T) {
+	// a file given by <NAME>_FILE takes precedence over the existing value
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+	secret := filepath.Join(dir, "token")
+	if err := os.WriteFile(secret, []byte("fromfile\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := New(path)
+	cfg.Env["PRIO_VAR5"] = Variable{Value: "stored"}
+	t.Setenv("PRIO_VAR5", "")
+	t.Setenv("PRIO_VAR5_FILE", secret)
+
+	if err := cfg.Add("PRIO_VAR5", Variable{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.Env["PRIO_VAR5"]; v.Value != "fromfile" {
+		t.Errorf("add() priority: expected file value %q, got %q", "fromfile", v.Value)
+	}
+
+	// the environment variable itself still wins
+	t.Setenv("PRIO_VAR5", "fromenv")
+	if err := cfg.Add("PRIO_VAR5", Variable{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.Env["PRIO_VAR5"]; v.Value != "fromenv" {
+		t.Errorf("add() priority: expected env value %q, got %q", "fromenv", v.Value)
+	}
+}
+
+func TestAskWhen_noInput(t *testing.T) {
+	tests := map[string]struct {
+		variable Variable
+		when     bool
+		want     string
+		wantErr  bool
+	}{
+		"missing": {
+			variable: Variable{Input: Input{Help: "token is required"}},
+			when:     true,
+			wantErr:  true,
+		},
+		"not needed": {
+			variable: Variable{},
+			when:     false,
+		},
+		"already set": {
+			variable: Variable{Value: "set"},
+			when:     true,
+			want:     "set",
+		},
+		"command": {
+			variable: Variable{Command: "echo fromcommand"},
+			when:     true,
+			want:     "fromcommand",
+		},
+		"failing command": {
+			variable: Variable{Command: "false"},
+			when:     true,
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("ASK_VAR", tt.variable.Value)
+			cfg := New(filepath.Join(t.TempDir(), "cache.json"))
+			cfg.NoInput = true
+			cfg.Env["ASK_VAR"] = tt.variable
+
+			err := cfg.AskWhen(map[string]bool{"ASK_VAR": tt.when})
+			var missing *MissingError
+			if errors.As(err, &missing) != tt.wantErr {
+				t.Fatalf("AskWhen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, ok := missing.Variables["ASK_VAR"]; !ok || !strings.Contains(err.Error(), "ASK_VAR") {
+					t.Errorf("AskWhen() error should list ASK_VAR: %v", err)
+				}
+				return
+			}
+			if got := os.Getenv("ASK_VAR"); got != tt.want {
+				t.Errorf("ASK_VAR = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestReadSave_roundtrip(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "cache.json")
 	cfg := New(path)
@@ -286,3 +377,141 @@ func TestRefresh(t *testing.T) {
 		t.Error("Refresh() did not remove the file")
 	}
 }
+
+func TestSave_secret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	// created by older versions
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := New(path)
+	cfg.Env["SECRET_VAR"] = Variable{Value: "s3cr3t", Secret: true}
+	cfg.Env["PLAIN_VAR"] = Variable{Value: "plain"}
+	if err := cfg.save(); err != nil {
+		t.Fatalf("save() error: %v", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("save() permission = %o, want 600", perm)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "s3cr3t") {
+		t.Errorf("save() should not write secret values: %s", content)
+	}
+	if !strings.Contains(string(content), "plain") {
+		t.Errorf("save() should write PLAIN_VAR: %s", content)
+	}
+}
+
+func TestAdd_migrateSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(path, []byte(`{"env":{"SECRET_VAR":{"value":"plaintext"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_VAR", "")
+
+	store := NewMemoryStore()
+	cfg := New(path, WithSecretStore(store))
+	if err := cfg.Add("SECRET_VAR", Variable{Secret: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("SECRET_VAR"); got != "plaintext" {
+		t.Errorf("SECRET_VAR = %q, want %q", got, "plaintext")
+	}
+	if got, err := store.Get("SECRET_VAR"); err != nil || got != "plaintext" {
+		t.Errorf("store.Get() = %q, %v, want %q", got, err, "plaintext")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "plaintext") {
+		t.Errorf("secret should be migrated out of cache file: %s", content)
+	}
+}
+
+type failingStore struct{ SecretStore }
+
+func (failingStore) Set(name, value string) error {
+	return errors.New("store is unavailable")
+}
+
+func TestAdd_migrateSecretFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(path, []byte(`{"env":{"SECRET_VAR":{"value":"plaintext"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_VAR", "")
+
+	cfg := New(path, WithSecretStore(failingStore{NewMemoryStore()}))
+	if err := cfg.Add("SECRET_VAR", Variable{Secret: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("SECRET_VAR"); got != "plaintext" {
+		t.Errorf("SECRET_VAR = %q, want %q", got, "plaintext")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "plaintext") {
+		t.Errorf("secret should be kept in cache file when the store fails: %s", content)
+	}
+}
+
+func TestAskWhen_secretStore(t *testing.T) {
+	t.Setenv("SECRET_VAR", "")
+
+	store := NewMemoryStore()
+	if err := store.Set("SECRET_VAR", "stored"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := New(filepath.Join(t.TempDir(), "cache.json"), WithSecretStore(store))
+	cfg.NoInput = true
+	cfg.Env["SECRET_VAR"] = Variable{Secret: true}
+
+	if err := cfg.AskWhen(map[string]bool{"SECRET_VAR": true}); err != nil {
+		t.Fatalf("AskWhen() error: %v", err)
+	}
+	if got := os.Getenv("SECRET_VAR"); got != "stored" {
+		t.Errorf("SECRET_VAR = %q, want %q", got, "stored")
+	}
+
+	if err := cfg.Refresh(); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	if got, err := store.Get("SECRET_VAR"); err != nil || got != "stored" {
+		t.Errorf("Refresh() should keep secrets in store, got %q, %v", got, err)
+	}
+}
+
+func TestGetenv_secretStore(t *testing.T) {
+	t.Setenv("SECRET_VAR", "")
+
+	store := NewMemoryStore()
+	if err := store.Set("SECRET_VAR", "stored"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := New(filepath.Join(t.TempDir(), "cache.json"), WithSecretStore(store))
+	cfg.Env["SECRET_VAR"] = Variable{Secret: true}
+
+	if got := cfg.Getenv("SECRET_VAR"); got != "stored" {
+		t.Errorf("Getenv() = %q, want %q", got, "stored")
+	}
+	if got := os.Getenv("SECRET_VAR"); got != "stored" {
+		t.Errorf("SECRET_VAR = %q, want %q", got, "stored")
+	}
+	if got := cfg.Getenv("UNKNOWN_VAR"); got != "" {
+		t.Errorf("Getenv() of unknown variable = %q, want empty", got)
+	}
+}
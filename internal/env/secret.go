@@ -0,0 +1,296 @@
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Backends of secret stores
+const (
+	BackendMemory        = "memory"
+	BackendSecretService = "secret-service"
+	BackendPass          = "pass"
+	BackendAge           = "age"
+)
+
+// ErrSecretNotFound is returned when a secret is not stored
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore stores values of secret variables (e.g. GITHUB_TOKEN)
+// instead of the cache file, which is written in plaintext
+type SecretStore interface {
+	// Get returns ErrSecretNotFound if the secret is not stored
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+	// Persistent returns false if secrets are kept only while afx is running
+	Persistent() bool
+}
+
+// DetectSecretStore returns a persistent store available on the system, which is
+// used if no backend is configured. Secret Service is used if secret-tool is
+// installed in a D-Bus session, and then pass if its password store exists.
+// Otherwise secrets are kept only in memory
+func DetectSecretStore() SecretStore {
+	if _, err := exec.LookPath("secret-tool"); err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return NewSecretServiceStore()
+	}
+	if _, err := exec.LookPath("pass"); err == nil {
+		dir := os.Getenv("PASSWORD_STORE_DIR")
+		if dir == "" {
+			home, _ := os.UserHomeDir()
+			dir = filepath.Join(home, ".password-store")
+		}
+		if _, err := os.Stat(dir); err == nil {
+			return NewPassStore("")
+		}
+	}
+	return NewMemoryStore()
+}
+
+// memoryStore keeps secrets only while afx is running
+type memoryStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// NewMemoryStore creates a store which keeps secrets only in memory
+func NewMemoryStore() SecretStore {
+	return &memoryStore{secrets: map[string]string{}}
+}
+
+func (s *memoryStore) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func (s *memoryStore) Set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[name] = value
+	return nil
+}
+
+func (s *memoryStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, name)
+	return nil
+}
+
+func (s *memoryStore) Persistent() bool {
+	return false
+}
+
+// secretServiceStore stores secrets in the freedesktop Secret Service
+// (e.g. GNOME Keyring, KWallet) over D-Bus with secret-tool
+type secretServiceStore struct{}
+
+// NewSecretServiceStore creates a store backed by the freedesktop Secret Service.
+// secret-tool (libsecret) is required
+func NewSecretServiceStore() SecretStore {
+	return secretServiceStore{}
+}
+
+func (secretServiceStore) attributes(name string) []string {
+	return []string{"service", "afx", "name", name}
+}
+
+func (s secretServiceStore) Get(name string) (string, error) {
+	out, err := runSecretCommand(nil, "secret-tool", append([]string{"lookup"}, s.attributes(name)...)...)
+	if err != nil {
+		// secret-tool exits with 1 without any message if not found
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) == 0 {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+func (s secretServiceStore) Set(name, value string) error {
+	args := append([]string{"store", "--label=afx: " + name}, s.attributes(name)...)
+	_, err := runSecretCommand(strings.NewReader(value), "secret-tool", args...)
+	return err
+}
+
+func (s secretServiceStore) Delete(name string) error {
+	_, err := runSecretCommand(nil, "secret-tool", append([]string{"clear"}, s.attributes(name)...)...)
+	return err
+}
+
+func (secretServiceStore) Persistent() bool {
+	return true
+}
+
+// passStore stores secrets in the password store of pass
+type passStore struct {
+	prefix string
+}
+
+// NewPassStore creates a store backed by pass (https://www.passwordstore.org/).
+// Secrets are stored under prefix (e.g. afx/GITHUB_TOKEN)
+func NewPassStore(prefix string) SecretStore {
+	if prefix == "" {
+		prefix = "afx"
+	}
+	return passStore{prefix: prefix}
+}
+
+func (s passStore) path(name string) string {
+	return s.prefix + "/" + name
+}
+
+func (s passStore) Get(name string) (string, error) {
+	out, err := runSecretCommand(nil, "pass", "show", s.path(name))
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "not in the password store") {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	// the first line is the password
+	value, _, _ := strings.Cut(out, "\n")
+	return value, nil
+}
+
+func (s passStore) Set(name, value string) error {
+	_, err := runSecretCommand(strings.NewReader(value+"\n"), "pass", "insert", "--multiline", "--force", s.path(name))
+	return err
+}
+
+func (s passStore) Delete(name string) error {
+	_, err := runSecretCommand(nil, "pass", "rm", "--force", s.path(name))
+	return err
+}
+
+func (passStore) Persistent() bool {
+	return true
+}
+
+// ageStore stores secrets in a file encrypted with age
+type ageStore struct {
+	mu        sync.Mutex
+	path      string
+	recipient string
+	identity  string
+}
+
+// NewAgeStore creates a store backed by a file in path encrypted with age
+// (https://age-encryption.org/). Secrets are encrypted to recipient and
+// decrypted with the private key in identity file. age is required
+func NewAgeStore(path, recipient, identity string) (SecretStore, error) {
+	if recipient == "" || identity == "" {
+		return nil, errors.New("age: both recipient and identity are required")
+	}
+	return &ageStore{path: path, recipient: recipient, identity: identity}, nil
+}
+
+func (s *ageStore) read() (map[string]string, error) {
+	secrets := map[string]string{}
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return secrets, nil
+	}
+	out, err := runSecretCommand(nil, "age", "--decrypt", "--identity", s.identity, s.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(out), &secrets); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return secrets, nil
+}
+
+func (s *ageStore) write(secrets map[string]string) error {
+	b, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if _, err := runSecretCommand(bytes.NewReader(b), "age", "--encrypt", "--recipient", s.recipient, "--output", tmp); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *ageStore) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func (s *ageStore) Set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return s.write(secrets)
+}
+
+func (s *ageStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return nil
+	}
+	delete(secrets, name)
+	return s.write(secrets)
+}
+
+func (*ageStore) Persistent() bool {
+	return true
+}
+
+// runSecretCommand runs a command of a secret store and returns its output.
+// The output is trimmed, and *exec.ExitError has stderr if it fails
+func runSecretCommand(stdin io.Reader, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("%s %s: %w: %s", name, args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
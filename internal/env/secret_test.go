@@ -0,0 +1,109 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakePass puts a fake pass command on PATH which keeps passwords in a directory
+func fakePass(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store")
+	script := `#!/bin/sh
+store="` + store + `"
+case "$1" in
+show)
+  [ -f "$store/$2" ] || { echo "Error: $2 is not in the password store." >&2; exit 1; }
+  cat "$store/$2" ;;
+insert)
+  mkdir -p "$(dirname "$store/$4")" && cat > "$store/$4" ;;
+rm)
+  rm -f "$store/$3" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "pass"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return store
+}
+
+func TestSecretStore(t *testing.T) {
+	tests := map[string]struct {
+		store      func(t *testing.T) SecretStore
+		persistent bool
+	}{
+		"memory": {
+			store:      func(t *testing.T) SecretStore { return NewMemoryStore() },
+			persistent: false,
+		},
+		"pass": {
+			store: func(t *testing.T) SecretStore {
+				fakePass(t)
+				return NewPassStore("")
+			},
+			persistent: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := tt.store(t)
+			if got := store.Persistent(); got != tt.persistent {
+				t.Errorf("Persistent() = %v, want %v", got, tt.persistent)
+			}
+
+			if _, err := store.Get("TOKEN"); !errors.Is(err, ErrSecretNotFound) {
+				t.Fatalf("Get() before Set() error = %v, want ErrSecretNotFound", err)
+			}
+			if err := store.Set("TOKEN", "secret"); err != nil {
+				t.Fatalf("Set() error: %v", err)
+			}
+			got, err := store.Get("TOKEN")
+			if err != nil {
+				t.Fatalf("Get() error: %v", err)
+			}
+			if got != "secret" {
+				t.Errorf("Get() = %q, want %q", got, "secret")
+			}
+			if err := store.Delete("TOKEN"); err != nil {
+				t.Fatalf("Delete() error: %v", err)
+			}
+			if _, err := store.Get("TOKEN"); !errors.Is(err, ErrSecretNotFound) {
+				t.Errorf("Get() after Delete() error = %v, want ErrSecretNotFound", err)
+			}
+		})
+	}
+}
+
+func TestPassStore_prefix(t *testing.T) {
+	dir := fakePass(t)
+	if err := NewPassStore("work/afx").Set("TOKEN", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "work", "afx", "TOKEN")); err != nil {
+		t.Errorf("secret should be stored under the prefix: %v", err)
+	}
+}
+
+func TestDetectSecretStore(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	if DetectSecretStore().Persistent() {
+		t.Error("DetectSecretStore() should fall back to memory")
+	}
+
+	fakePass(t)
+	dir := t.TempDir()
+	t.Setenv("PASSWORD_STORE_DIR", filepath.Join(dir, "missing"))
+	if DetectSecretStore().Persistent() {
+		t.Error("DetectSecretStore() should not use pass without its password store")
+	}
+	t.Setenv("PASSWORD_STORE_DIR", dir)
+	if _, ok := DetectSecretStore().(passStore); !ok {
+		t.Error("DetectSecretStore() should use pass with its password store")
+	}
+}
@@ -0,0 +1,60 @@
+// Package forge provides clients of releases API of git hosting services
+// other than GitHub, such as GitLab and Gitea (Forgejo).
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/logging"
+	"github.com/babarot/afx/internal/transient"
+)
+
+// Release is a release fetched from hosting services.
+type Release struct {
+	Tag    string
+	Assets github.Assets
+}
+
+var client = &http.Client{
+	Transport: logging.NewTransport("Forge", http.DefaultTransport),
+}
+
+// get calls a REST API and decodes a JSON response into data.
+func get(ctx context.Context, url string, header http.Header, data any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return transient.FromStatus(resp.StatusCode, fmt.Errorf("%s: %s: %s", url, resp.Status, strings.TrimSpace(string(body))))
+	}
+	return json.NewDecoder(resp.Body).Decode(data)
+}
+
+func isLatest(tag string) bool {
+	switch tag {
+	case "latest", "":
+		log.Printf("[DEBUG] forge: looking up latest release")
+		return true
+	}
+	return false
+}
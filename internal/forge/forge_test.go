@@ -0,0 +1,130 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/github"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+func TestGitLabRelease(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("PRIVATE-TOKEN"); got != "secret" {
+			t.Errorf("PRIVATE-TOKEN = %q, want %q", got, "secret")
+		}
+		release := map[string]any{
+			"tag_name": "v1.0.0",
+			"assets": map[string]any{
+				"links": []map[string]string{
+					{"name": "tool_linux_amd64.tar.gz", "url": "https://example.com/a", "direct_asset_url": "https://example.com/direct/a"},
+					{"name": "checksums.txt", "url": "https://example.com/b"},
+				},
+			},
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fsub%2Frepo/releases":
+			if r.URL.Query().Get("per_page") != "1" {
+				t.Errorf("per_page = %q, want 1", r.URL.Query().Get("per_page"))
+			}
+			_ = json.NewEncoder(w).Encode([]any{release})
+		case "/api/v4/projects/group%2Fsub%2Frepo/releases/v1.0.0":
+			_ = json.NewEncoder(w).Encode(release)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"message":"404 Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	want := Release{
+		Tag: "v1.0.0",
+		Assets: github.Assets{
+			{Name: "tool_linux_amd64.tar.gz", URL: "https://example.com/direct/a"},
+			{Name: "checksums.txt", URL: "https://example.com/b"},
+		},
+	}
+
+	tests := map[string]struct {
+		project string
+		tag     string
+		wantErr bool
+	}{
+		"latest":    {project: "group/sub/repo", tag: "latest"},
+		"tag":       {project: "group/sub/repo", tag: "v1.0.0"},
+		"not found": {project: "group/other", tag: "latest", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := GitLabRelease(context.Background(), server.URL, tt.project, tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GitLabRelease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("GitLabRelease() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGiteaRelease(t *testing.T) {
+	t.Setenv("GITEA_TOKEN", "secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token secret" {
+			t.Errorf("Authorization = %q, want %q", got, "token secret")
+		}
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/releases/latest", "/api/v1/repos/owner/repo/releases/tags/v2.0.0":
+			_, _ = io.WriteString(w, `{"tag_name":"v2.0.0","assets":[{"name":"tool.tar.gz","browser_download_url":"https://example.com/tool.tar.gz"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	want := Release{
+		Tag:    "v2.0.0",
+		Assets: github.Assets{{Name: "tool.tar.gz", URL: "https://example.com/tool.tar.gz"}},
+	}
+
+	tests := map[string]struct {
+		tag     string
+		wantErr bool
+	}{
+		"latest":    {tag: ""},
+		"tag":       {tag: "v2.0.0"},
+		"not found": {tag: "v0.0.1", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := GiteaRelease(context.Background(), server.URL+"/", "owner", "repo", tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GiteaRelease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("GiteaRelease() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/babarot/afx/internal/github"
+)
+
+type giteaRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// GiteaRelease gets a release of a repository from Gitea (or Forgejo) releases API.
+// baseURL is a URL of the instance (e.g. https://codeberg.org).
+// Private repositories can be accessed with $GITEA_TOKEN.
+func GiteaRelease(ctx context.Context, baseURL, owner, repo, tag string) (Release, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/repos/%s/%s/releases",
+		strings.TrimSuffix(baseURL, "/"), url.PathEscape(owner), url.PathEscape(repo))
+	if isLatest(tag) {
+		endpoint += "/latest"
+	} else {
+		endpoint += "/tags/" + url.PathEscape(tag)
+	}
+
+	header := http.Header{}
+	if token := os.Getenv("GITEA_TOKEN"); token != "" {
+		header.Set("Authorization", "token "+token)
+	}
+
+	var resp giteaRelease
+	if err := get(ctx, endpoint, header, &resp); err != nil {
+		return Release{}, err
+	}
+
+	release := Release{Tag: resp.TagName}
+	for _, asset := range resp.Assets {
+		release.Assets = append(release.Assets, github.Asset{Name: asset.Name, URL: asset.BrowserDownloadURL})
+	}
+	return release, nil
+}
@@ -0,0 +1,65 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/babarot/afx/internal/github"
+)
+
+type gitlabRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  struct {
+		Links []struct {
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+// GitLabRelease gets a release of a project from GitLab releases API.
+// baseURL is a URL of GitLab instance (e.g. https://gitlab.com) and
+// project is a full path of the project including subgroups.
+// Private projects can be accessed with $GITLAB_TOKEN.
+func GitLabRelease(ctx context.Context, baseURL, project, tag string) (Release, error) {
+	endpoint := fmt.Sprintf("%s/api/v4/projects/%s/releases",
+		strings.TrimSuffix(baseURL, "/"), url.PathEscape(project))
+
+	header := http.Header{}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		header.Set("PRIVATE-TOKEN", token)
+	}
+
+	var resp gitlabRelease
+	if isLatest(tag) {
+		// releases are sorted by released_at in descending order
+		var releases []gitlabRelease
+		if err := get(ctx, endpoint+"?per_page=1", header, &releases); err != nil {
+			return Release{}, err
+		}
+		if len(releases) == 0 {
+			return Release{}, errors.New("no releases found")
+		}
+		resp = releases[0]
+	} else {
+		if err := get(ctx, endpoint+"/"+url.PathEscape(tag), header, &resp); err != nil {
+			return Release{}, err
+		}
+	}
+
+	release := Release{Tag: resp.TagName}
+	for _, link := range resp.Assets.Links {
+		u := link.DirectAssetURL
+		if u == "" {
+			u = link.URL
+		}
+		release.Assets = append(release.Assets, github.Asset{Name: link.Name, URL: u})
+	}
+	return release, nil
+}
@@ -0,0 +1,272 @@
+package gc
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/babarot/afx/internal/state"
+)
+
+// Kind is a kind of garbage
+type Kind int
+
+const (
+	// Unreferenced is a file or directory in the data directory which no
+	// package refers to, e.g. a clone of a package renamed or removed from config
+	Unreferenced Kind = iota
+	// Backup is a backup of a package left by an update
+	Backup
+	// Symlink is a symlink to files managed by afx which no package creates,
+	// e.g. a link whose "to" was changed. Dangling symlinks are included
+	Symlink
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Unreferenced:
+		return "unreferenced"
+	case Backup:
+		return "backup"
+	case Symlink:
+		return "symlink"
+	default:
+		return "unknown"
+	}
+}
+
+// Garbage is a file or directory which can be removed
+type Garbage struct {
+	Kind Kind
+	Path string
+	// Target is where a symlink points to
+	Target string
+	// Size is the total size of files in the path
+	Size int64
+}
+
+// Remove removes the garbage
+func (g Garbage) Remove() error {
+	if g.Kind == Symlink {
+		return os.Remove(g.Path)
+	}
+	return os.RemoveAll(g.Path)
+}
+
+// Dirs are directories to look for garbage
+type Dirs struct {
+	// Data is the data directory of afx where packages are installed
+	Data string
+	// Bin is the directory where commands are linked
+	Bin string
+	// GHExtension is the directory where gh CLI installs extensions.
+	// Only backups and aliases (rename-to) of extensions are collected from it,
+	// as it may have extensions installed by gh CLI directly
+	GHExtension string
+
+	// Keep are paths in the data directory which are not packages (e.g. cache)
+	Keep []string
+}
+
+type finder struct {
+	dirs       Dirs
+	referenced map[string]bool
+	ancestors  map[string]bool
+	keep       map[string]bool
+}
+
+// Find finds garbage in directories. A path is regarded as referenced if
+// it's a home, one of paths or a generation of given resources, so that
+// resources should be those of packages in config and in state
+func Find(dirs Dirs, resources []state.Resource) ([]Garbage, error) {
+	f := finder{
+		dirs:       dirs,
+		referenced: map[string]bool{},
+		ancestors:  map[string]bool{},
+		keep:       map[string]bool{},
+	}
+	for _, resource := range resources {
+		paths := append([]string{resource.Home}, resource.Paths...)
+		for _, gen := range resource.Generations {
+			paths = append(paths, gen.Dir)
+		}
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			path = filepath.Clean(path)
+			f.referenced[path] = true
+			for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+				f.ancestors[dir] = true
+			}
+		}
+	}
+	for _, path := range dirs.Keep {
+		f.keep[filepath.Clean(path)] = true
+	}
+	for _, dir := range []*string{&f.dirs.Data, &f.dirs.Bin, &f.dirs.GHExtension} {
+		if *dir != "" {
+			*dir = filepath.Clean(*dir)
+		}
+	}
+
+	var garbage []Garbage
+	for _, find := range []func() ([]Garbage, error){f.data, f.bin, f.ghExtension} {
+		g, err := find()
+		if err != nil {
+			return nil, err
+		}
+		garbage = append(garbage, g...)
+	}
+	sort.Slice(garbage, func(i, j int) bool {
+		return garbage[i].Path < garbage[j].Path
+	})
+	return garbage, nil
+}
+
+// data finds what is not referenced in the data directory.
+// Files directly under the directory are afx's own (e.g. state.json),
+// so only directories are looked into
+func (f finder) data() ([]Garbage, error) {
+	var garbage []Garbage
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := readDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			switch {
+			case f.keep[path] || f.referenced[path]:
+				continue
+			case dir == f.dirs.Data && !entry.IsDir():
+				continue
+			case f.ancestors[path]:
+				if entry.IsDir() {
+					if err := walk(path); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			kind := Unreferenced
+			if entry.IsDir() && strings.HasSuffix(entry.Name(), ".bak") {
+				kind = Backup
+			}
+			garbage = append(garbage, Garbage{Kind: kind, Path: path, Size: size(path)})
+		}
+		return nil
+	}
+	if f.dirs.Data == "" {
+		return nil, nil
+	}
+	return garbage, walk(f.dirs.Data)
+}
+
+// bin finds symlinks to the data directory or gh extensions which
+// are not created by any packages
+func (f finder) bin() ([]Garbage, error) {
+	entries, err := readDir(f.dirs.Bin)
+	if err != nil {
+		return nil, err
+	}
+	var garbage []Garbage
+	for _, entry := range entries {
+		path := filepath.Join(f.dirs.Bin, entry.Name())
+		if entry.Type()&fs.ModeSymlink == 0 || f.referenced[path] {
+			continue
+		}
+		target, err := readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		if !within(target, f.dirs.Data) && !within(target, f.dirs.GHExtension) {
+			// may be created by users
+			continue
+		}
+		garbage = append(garbage, Garbage{Kind: Symlink, Path: path, Target: target})
+	}
+	return garbage, nil
+}
+
+// ghExtension finds backups of extensions and aliases of extensions
+// whose rename-to was changed
+func (f finder) ghExtension() ([]Garbage, error) {
+	entries, err := readDir(f.dirs.GHExtension)
+	if err != nil {
+		return nil, err
+	}
+	var garbage []Garbage
+	for _, entry := range entries {
+		path := filepath.Join(f.dirs.GHExtension, entry.Name())
+		if f.referenced[path] {
+			continue
+		}
+		switch {
+		case entry.IsDir() && strings.HasPrefix(entry.Name(), "gh-") && strings.HasSuffix(entry.Name(), ".bak"):
+			garbage = append(garbage, Garbage{Kind: Backup, Path: path, Size: size(path)})
+		case entry.Type()&fs.ModeSymlink != 0:
+			target, err := readlink(path)
+			if err != nil {
+				return nil, err
+			}
+			if f.referenced[target] {
+				garbage = append(garbage, Garbage{Kind: Symlink, Path: path, Target: target})
+			}
+		}
+	}
+	return garbage, nil
+}
+
+// readDir is the same as os.ReadDir except a directory which doesn't exist has no entries
+func readDir(dir string) ([]fs.DirEntry, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+// readlink returns an absolute path where a symlink points to
+func readlink(path string) (string, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return filepath.Clean(target), nil
+}
+
+// within returns true if path is under root
+func within(path, root string) bool {
+	if root == "" {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// size returns the total size of files in a given path
+func size(path string) int64 {
+	var total int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,153 @@
+package gc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/state"
+)
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	dirs := Dirs{
+		Data:        filepath.Join(root, "data"),
+		Bin:         filepath.Join(root, "bin"),
+		GHExtension: filepath.Join(root, "extensions"),
+		Keep:        []string{filepath.Join(root, "data", "cache")},
+	}
+	data := func(elem ...string) string {
+		return filepath.Join(append([]string{dirs.Data}, elem...)...)
+	}
+	mkdir := func(path string) {
+		t.Helper()
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path string) {
+		t.Helper()
+		mkdir(filepath.Dir(path))
+		if err := os.WriteFile(path, []byte("content"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink := func(target, path string) {
+		t.Helper()
+		mkdir(filepath.Dir(path))
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// afx's own files
+	write(data("state.json"))
+	write(data("cache", "blobs", "sha256", "abc"))
+
+	// installed packages
+	write(data("github.com", "junegunn", "fzf", "bin", "fzf"))
+	write(data("github.com", "junegunn", "fzf.vim", "plugin", "fzf.vim"))
+	write(data("example.com", "dl", "tool"))
+	mkdir(filepath.Join(dirs.GHExtension, "gh-dash"))
+	symlink(data("github.com", "junegunn", "fzf", "bin", "fzf"), filepath.Join(dirs.Bin, "fzf"))
+	symlink(data("example.com", "dl", "tool"), filepath.Join(dirs.Bin, "tool"))
+	symlink(filepath.Join(dirs.GHExtension, "gh-dash"), filepath.Join(dirs.GHExtension, "gh-d"))
+	write(data("generations", "github.com", "junegunn", "fzf", "0.44.0", "bin", "fzf"))
+
+	// garbage
+	write(data("github.com", "junegunn", "fzf.bak", "bin", "fzf"))
+	write(data("github.com", "babarot", "enhancd", "init.sh"))
+	write(data("example.com", "old", "tool"))
+	write(data("generations", "example.com", "old", "v1", "tool"))
+	symlink(data("github.com", "junegunn", "fzf", "bin", "fzf"), filepath.Join(dirs.Bin, "fzf-old"))
+	symlink(data("github.com", "babarot", "gomi", "gomi"), filepath.Join(dirs.Bin, "gomi"))
+	mkdir(filepath.Join(dirs.GHExtension, "gh-dash.bak"))
+	symlink(filepath.Join(dirs.GHExtension, "gh-dash"), filepath.Join(dirs.GHExtension, "gh-dashboard"))
+
+	// not managed by afx
+	symlink("/usr/bin/env", filepath.Join(dirs.Bin, "env"))
+	symlink(filepath.Join(root, "nonexistent"), filepath.Join(dirs.Bin, "dangling"))
+	mkdir(filepath.Join(dirs.GHExtension, "gh-installed-by-gh"))
+
+	resources := []state.Resource{
+		{
+			Name: "junegunn/fzf",
+			Home: data("github.com", "junegunn", "fzf"),
+			Paths: []string{
+				data("github.com", "junegunn", "fzf"),
+				data("github.com", "junegunn", "fzf", "bin", "fzf"),
+				filepath.Join(dirs.Bin, "fzf"),
+			},
+			Generations: []state.Generation{
+				{Name: "0.44.0", Dir: data("generations", "github.com", "junegunn", "fzf", "0.44.0")},
+			},
+		},
+		{
+			Name:  "junegunn/fzf.vim",
+			Home:  data("github.com", "junegunn", "fzf.vim"),
+			Paths: []string{data("github.com", "junegunn", "fzf.vim")},
+		},
+		{
+			Name: "tool",
+			Home: data("example.com", "dl"),
+			Paths: []string{
+				data("example.com", "dl"),
+				filepath.Join(dirs.Bin, "tool"),
+			},
+		},
+		{
+			Name: "dlvhdr/gh-dash",
+			Home: filepath.Join(dirs.GHExtension, "gh-dash"),
+			Paths: []string{
+				filepath.Join(dirs.GHExtension, "gh-dash"),
+				filepath.Join(dirs.GHExtension, "gh-d"),
+			},
+		},
+	}
+
+	got, err := Find(dirs, resources)
+	if err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	want := []Garbage{
+		{Kind: Symlink, Path: filepath.Join(dirs.Bin, "fzf-old"), Target: data("github.com", "junegunn", "fzf", "bin", "fzf")},
+		{Kind: Symlink, Path: filepath.Join(dirs.Bin, "gomi"), Target: data("github.com", "babarot", "gomi", "gomi")},
+		{Kind: Unreferenced, Path: data("example.com", "old"), Size: 7},
+		{Kind: Unreferenced, Path: data("generations", "example.com"), Size: 7},
+		{Kind: Unreferenced, Path: data("github.com", "babarot"), Size: 7},
+		{Kind: Backup, Path: data("github.com", "junegunn", "fzf.bak"), Size: 7},
+		{Kind: Backup, Path: filepath.Join(dirs.GHExtension, "gh-dash.bak")},
+		{Kind: Symlink, Path: filepath.Join(dirs.GHExtension, "gh-dashboard"), Target: filepath.Join(dirs.GHExtension, "gh-dash")},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Find() mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, g := range got {
+		if err := g.Remove(); err != nil {
+			t.Fatalf("Remove() error: %v", err)
+		}
+	}
+	got, err = Find(dirs, resources)
+	if err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	if len(got) > 0 {
+		t.Errorf("Find() after removed = %v, want nothing", got)
+	}
+	for _, path := range []string{
+		data("state.json"),
+		data("cache", "blobs", "sha256", "abc"),
+		data("github.com", "junegunn", "fzf", "bin", "fzf"),
+		data("generations", "github.com", "junegunn", "fzf", "0.44.0", "bin", "fzf"),
+		filepath.Join(dirs.Bin, "env"),
+		filepath.Join(dirs.Bin, "dangling"),
+		filepath.Join(dirs.GHExtension, "gh-installed-by-gh"),
+	} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%s should be kept: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package generation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/babarot/afx/internal/state"
+)
+
+// DefaultKeep is the default number of generations kept for each package
+const DefaultKeep = 3
+
+var unsafe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Dir returns a directory where a generation of a resource is kept.
+// IDs and names are sanitized to be used as paths (e.g. URLs of HTTP packages)
+func Dir(root string, id state.ID, name string) string {
+	if _, after, ok := strings.Cut(id, "://"); ok {
+		id = after
+	}
+	elems := []string{root}
+	for _, elem := range append(strings.Split(id, "/"), name) {
+		elem = unsafe.ReplaceAllString(elem, "_")
+		switch elem {
+		case "":
+			continue
+		case ".", "..":
+			elem = strings.Repeat("_", len(elem))
+		}
+		elems = append(elems, elem)
+	}
+	return filepath.Join(elems...)
+}
+
+// Name returns a name of a generation. It's the version if given,
+// otherwise the short commit, otherwise when it's replaced
+func Name(version, commit string, now time.Time) string {
+	switch {
+	case version != "":
+		return version
+	case commit != "":
+		return commit[:min(len(commit), 7)]
+	default:
+		return now.Format("20060102150405")
+	}
+}
+
+// Links returns symlinks in given paths which point to files in home,
+// that is links created for a package installed in home
+func Links(paths []string, home string) []state.Link {
+	var links []state.Link
+	for _, path := range paths {
+		fi, err := os.Lstat(path)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if rel, err := filepath.Rel(home, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		links = append(links, state.Link{From: target, To: path})
+	}
+	return links
+}
+
+// Find returns the index of a generation which has a given name or version.
+// An empty name means the newest generation
+func Find(gens []state.Generation, name string) (int, error) {
+	if len(gens) == 0 {
+		return -1, errors.New("no generations to roll back to")
+	}
+	if name == "" {
+		return 0, nil
+	}
+	i := slices.IndexFunc(gens, func(g state.Generation) bool {
+		return g.Name == name || (g.Version != "" && g.Version == name)
+	})
+	if i < 0 {
+		var names []string
+		for _, g := range gens {
+			names = append(names, g.Name)
+		}
+		return -1, fmt.Errorf("%s: no such generation (available: %s)", name, strings.Join(names, ", "))
+	}
+	return i, nil
+}
+
+// Keep returns generations to be kept and the others to be dropped.
+// The newest n generations are kept
+func Keep(gens []state.Generation, n int) ([]state.Generation, []state.Generation) {
+	if n < 0 {
+		n = 0
+	}
+	if len(gens) <= n {
+		return gens, nil
+	}
+	return gens[:n], gens[n:]
+}
+
+// Swap replaces home with a generation in from, and moves the current home to to.
+// home is restored if the generation cannot be moved
+func Swap(home, from, to string) error {
+	if _, err := os.Stat(from); err != nil {
+		return fmt.Errorf("generation is not found: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(to); err != nil {
+		return err
+	}
+	if err := os.Rename(home, to); err != nil {
+		return err
+	}
+	if err := os.Rename(from, home); err != nil {
+		if restoreErr := os.Rename(to, home); restoreErr != nil {
+			err = errors.Join(err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Relink switches symlinks from current ones to target ones. Each symlink is
+// replaced atomically, so a command is never missing while switching.
+// Current symlinks not in target ones are removed
+func Relink(current, target []state.Link) error {
+	var errs []error
+	for _, link := range target {
+		tmp := link.To + ".afx-tmp"
+		_ = os.Remove(tmp)
+		if err := os.MkdirAll(filepath.Dir(link.To), 0755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Symlink(link.From, tmp); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Rename(tmp, link.To); err != nil {
+			_ = os.Remove(tmp)
+			errs = append(errs, err)
+		}
+	}
+	for _, link := range current {
+		if slices.ContainsFunc(target, func(l state.Link) bool { return l.To == link.To }) {
+			continue
+		}
+		fi, err := os.Lstat(link.To)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if err := os.Remove(link.To); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
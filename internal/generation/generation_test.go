@@ -0,0 +1,182 @@
+package generation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/state"
+)
+
+func TestDir(t *testing.T) {
+	tests := map[string]struct {
+		id   string
+		name string
+		want string
+	}{
+		"GitHub release": {
+			id:   "github.com/release/junegunn/fzf",
+			name: "v0.44.0",
+			want: "/gen/github.com/release/junegunn/fzf/v0.44.0",
+		},
+		"HTTP": {
+			id:   "https://example.com/dl/tool_{{ .OS }}.tar.gz?v=1",
+			name: "20261017012300",
+			want: "/gen/example.com/dl/tool____.OS___.tar.gz_v_1/20261017012300",
+		},
+		"parent directory": {
+			id:   "example.com/../../etc",
+			name: "..",
+			want: "/gen/example.com/__/__/etc/__",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Dir("/gen", tt.id, tt.name); got != filepath.FromSlash(tt.want) {
+				t.Errorf("Dir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestName(t *testing.T) {
+	now := time.Date(2026, 10, 17, 1, 23, 0, 0, time.UTC)
+	tests := map[string]struct {
+		version string
+		commit  string
+		want    string
+	}{
+		"version": {version: "v1.2.0", commit: "0123456789abcdef", want: "v1.2.0"},
+		"commit":  {commit: "0123456789abcdef", want: "0123456"},
+		"time":    {want: "20261017012300"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Name(tt.version, tt.commit, now); got != tt.want {
+				t.Errorf("Name() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	gens := []state.Generation{
+		{Name: "v2.0.0", Version: "v2.0.0"},
+		{Name: "20261017012300", Version: "v1.0.0"},
+	}
+	tests := map[string]struct {
+		gens    []state.Generation
+		name    string
+		want    int
+		wantErr bool
+	}{
+		"newest":     {gens: gens, want: 0},
+		"by name":    {gens: gens, name: "20261017012300", want: 1},
+		"by version": {gens: gens, name: "v1.0.0", want: 1},
+		"not found":  {gens: gens, name: "v3.0.0", want: -1, wantErr: true},
+		"nothing":    {want: -1, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Find(tt.gens, tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Find() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeep(t *testing.T) {
+	gens := []state.Generation{{Name: "3"}, {Name: "2"}, {Name: "1"}}
+	kept, dropped := Keep(gens, 2)
+	if diff := cmp.Diff(gens[:2], kept); diff != "" {
+		t.Errorf("Keep() kept mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(gens[2:], dropped); diff != "" {
+		t.Errorf("Keep() dropped mismatch (-want +got):\n%s", diff)
+	}
+	if kept, dropped := Keep(gens, 5); len(kept) != 3 || len(dropped) != 0 {
+		t.Errorf("Keep() = %v, %v, want all kept", kept, dropped)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "data", "github.com", "owner", "tool")
+	gen := filepath.Join(root, "data", "generations", "github.com", "owner", "tool", "v1")
+	current := filepath.Join(root, "data", "generations", "github.com", "owner", "tool", "v2")
+	bin := filepath.Join(root, "bin")
+	for path, content := range map[string]string{
+		filepath.Join(home, "tool-v2", "tool"): "v2",
+		filepath.Join(gen, "tool-v1", "tool"):  "v1",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"tool":   filepath.Join(home, "tool-v2", "tool"),
+		"tool2":  filepath.Join(home, "tool-v2", "tool"),
+		"others": "/usr/bin/env",
+	} {
+		if err := os.Symlink(target, filepath.Join(bin, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := []string{home, filepath.Join(bin, "others"), filepath.Join(bin, "tool"), filepath.Join(bin, "tool2")}
+	links := Links(paths, home)
+	want := []state.Link{
+		{From: filepath.Join(home, "tool-v2", "tool"), To: filepath.Join(bin, "tool")},
+		{From: filepath.Join(home, "tool-v2", "tool"), To: filepath.Join(bin, "tool2")},
+	}
+	if diff := cmp.Diff(want, links); diff != "" {
+		t.Fatalf("Links() mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := Swap(home, gen, current); err != nil {
+		t.Fatalf("Swap() error: %v", err)
+	}
+	target := []state.Link{{From: filepath.Join(home, "tool-v1", "tool"), To: filepath.Join(bin, "tool")}}
+	if err := Relink(links, target); err != nil {
+		t.Fatalf("Relink() error: %v", err)
+	}
+
+	if b, err := os.ReadFile(filepath.Join(bin, "tool")); err != nil || string(b) != "v1" {
+		t.Errorf("tool = %q (%v), want v1", b, err)
+	}
+	if _, err := os.Lstat(filepath.Join(bin, "tool2")); !os.IsNotExist(err) {
+		t.Errorf("tool2 should be removed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(bin, "others")); err != nil {
+		t.Errorf("others should be kept: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(current, "tool-v2", "tool")); err != nil || string(b) != "v2" {
+		t.Errorf("current installation should be kept as generation: %q (%v)", b, err)
+	}
+	if _, err := os.Stat(gen); !os.IsNotExist(err) {
+		t.Errorf("generation should be moved to home: %v", err)
+	}
+
+	if err := Swap(home, filepath.Join(root, "nonexistent"), gen); err == nil {
+		t.Error("Swap() should fail if the generation doesn't exist")
+	}
+	if _, err := os.Stat(filepath.Join(home, "tool-v1", "tool")); err != nil {
+		t.Errorf("home should be left as it is: %v", err)
+	}
+}
@@ -66,6 +66,35 @@ func (r *GitRunner) RunInDir(ctx context.Context, dir string, args ...string) ([
 	return r.Run(ctx, fullArgs...)
 }
 
+// Head returns the commit SHA which HEAD points to in the given repository.
+func (r *GitRunner) Head(ctx context.Context, dir string) (string, error) {
+	out, err := r.RunInDir(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Checkout checks out the given commit in the given repository.
+// The commit is fetched from origin first if it does not exist locally
+// (e.g. shallow clone).
+func (r *GitRunner) Checkout(ctx context.Context, dir, commit string) error {
+	if _, err := r.RunInDir(ctx, dir, "cat-file", "-e", commit+"^{commit}"); err != nil {
+		if _, err := r.RunInDir(ctx, dir, "fetch", "origin", commit, "--no-tags"); err != nil {
+			return err
+		}
+	}
+	_, err := r.RunInDir(ctx, dir, "checkout", "--force", commit)
+	return err
+}
+
+// Bundle writes all refs and HEAD of the given repository into a bundle file,
+// which can be cloned without network access.
+func (r *GitRunner) Bundle(ctx context.Context, dir, file string) error {
+	_, err := r.RunInDir(ctx, dir, "bundle", "create", file, "HEAD", "--all")
+	return err
+}
+
 // ExitError is returned when the git command exits with a non-zero status.
 type ExitError struct {
 	Cmd      string
@@ -80,6 +109,39 @@ func (e *ExitError) Error() string {
 	return fmt.Sprintf("git: exited with code %d", e.ExitCode)
 }
 
+// Transient returns true if git failed because of the network,
+// so that it may succeed by retrying.
+func (e *ExitError) Transient() bool {
+	return IsNetworkError(e)
+}
+
+// IsNetworkError returns true if the error looks like a network failure
+// (e.g. unresolved host, timeout, connection reset or a 5xx response).
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	s := strings.ToLower(err.Error())
+	for _, msg := range []string{
+		"could not resolve host",
+		"connection timed out",
+		"operation timed out",
+		"connection reset",
+		"connection refused",
+		"failed to connect",
+		"the remote end hung up unexpectedly",
+		"early eof",
+		"rpc failed",
+		"returned error: 429",
+		"returned error: 5",
+	} {
+		if strings.Contains(s, msg) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsAuthError returns true if the error looks like an authentication failure.
 func IsAuthError(err error) bool {
 	if err == nil {
@@ -49,3 +49,39 @@ func TestIsAuthError(t *testing.T) {
 		})
 	}
 }
+
+func TestIsNetworkError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil error": {
+			err:  nil,
+			want: false,
+		},
+		"unresolved host": {
+			err:  &ExitError{Stderr: "fatal: unable to access 'https://github.com/o/r/': Could not resolve host: github.com", ExitCode: 128},
+			want: true,
+		},
+		"hung up": {
+			err:  &ExitError{Stderr: "fatal: the remote end hung up unexpectedly", ExitCode: 128},
+			want: true,
+		},
+		"server error": {
+			err:  &ExitError{Stderr: "fatal: unable to access 'https://github.com/o/r/': The requested URL returned error: 502", ExitCode: 128},
+			want: true,
+		},
+		"not found": {
+			err:  &ExitError{Stderr: "fatal: repository 'https://github.com/o/r/' not found", ExitCode: 128},
+			want: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsNetworkError(tt.err); got != tt.want {
+				t.Errorf("IsNetworkError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
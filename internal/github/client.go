@@ -1,12 +1,12 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
-	"os"
-
-	"errors"
+	"net/url"
+	"strings"
 )
 
 // ClientOption represents an argument to NewClient
@@ -27,6 +27,48 @@ func NewClient(opts ...ClientOption) *Client {
 	return client
 }
 
+// NewDownloadClient initializes an http.Client which sends the token for a given host
+// with requests to it, e.g. to download release assets and checksum files attached
+// to them. The token is never sent to other hosts, including ones redirected to
+func NewDownloadClient(host string, opts ...ClientOption) *http.Client {
+	client := NewHTTPClient(opts...)
+	client.Transport = &tokenTransport{tr: client.Transport, host: host}
+	return client
+}
+
+type tokenTransport struct {
+	tr   http.RoundTripper
+	host string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := Token(t.host)
+	if token == "" || req.Header.Get("Authorization") != "" || !t.matches(req.URL) {
+		return t.tr.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+	return t.tr.RoundTrip(req)
+}
+
+// matches returns true if u is on the host or its API host
+func (t *tokenTransport) matches(u *url.URL) bool {
+	host := t.host
+	if host == "" {
+		host = DefaultHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	for _, base := range []string{host, APIURL(t.host)} {
+		b, err := url.Parse(base)
+		if err == nil && strings.EqualFold(b.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
 // ReplaceTripper substitutes the underlying RoundTripper with a custom one
 func ReplaceTripper(tr http.RoundTripper) ClientOption {
 	return func(http.RoundTripper) http.RoundTripper {
@@ -37,17 +79,32 @@ func ReplaceTripper(tr http.RoundTripper) ClientOption {
 // Client facilitates making HTTP requests to the GitHub API
 type Client struct {
 	http *http.Client
+	host string
+}
+
+// ForHost returns a client which authenticates requests with the token for a given host.
+// See Token for how the token is chosen.
+func (c Client) ForHost(host string) *Client {
+	c.host = host
+	return &c
 }
 
+// REST sends a request to API and decodes the response into data.
+// Unsuccessful responses are returned as typed errors such as RateLimitError
 func (c Client) REST(method string, url string, body io.Reader, data any) error {
-	req, err := http.NewRequest(method, url, body)
+	return c.RESTContext(context.Background(), method, url, body, data)
+}
+
+// RESTContext is like REST but with a context
+func (c Client) RESTContext(ctx context.Context, method string, url string, body io.Reader, data any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return err
 	}
 
 	// to avoid hitting rate limit
 	// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
-	token := os.Getenv("GITHUB_TOKEN")
+	token := Token(c.host)
 	if token != "" {
 		// currently optional
 		req.Header.Set("Authorization", "token "+token)
@@ -60,13 +117,8 @@ func (c Client) REST(method string, url string, body io.Reader, data any) error
 	}
 	defer resp.Body.Close()
 
-	success := resp.StatusCode >= 200 && resp.StatusCode < 300
-	if !success {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		return errors.New(string(body))
+	if err := checkResponse(resp, token != ""); err != nil {
+		return err
 	}
 
 	if resp.StatusCode == http.StatusNoContent {
@@ -0,0 +1,144 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+// RateLimitError is returned when the rate limit of API is exceeded
+type RateLimitError struct {
+	Limit     int
+	Remaining int
+	// Reset is when the rate limit is reset. It's zero if unknown
+	Reset   time.Time
+	Message string
+	// Authenticated tells if the request was sent with a token
+	Authenticated bool
+}
+
+func (e *RateLimitError) Error() string {
+	msg := "API rate limit exceeded"
+	if e.Limit > 0 {
+		msg += fmt.Sprintf(" (%d requests per hour)", e.Limit)
+	}
+	if !e.Reset.IsZero() {
+		msg += fmt.Sprintf(", resets at %s (in %s)",
+			e.Reset.Local().Format("15:04:05"), time.Until(e.Reset).Round(time.Second))
+	}
+	if !e.Authenticated {
+		msg += ". Set GITHUB_TOKEN to raise the limit"
+	}
+	return msg
+}
+
+// NotFoundError is returned when a resource (e.g. a repository or a release) is not found
+type NotFoundError struct {
+	URL     string
+	Message string
+	// Authenticated tells if the request was sent with a token
+	Authenticated bool
+}
+
+func (e *NotFoundError) Error() string {
+	msg := fmt.Sprintf("%s: not found", e.URL)
+	if !e.Authenticated {
+		msg += " (private repositories need GITHUB_TOKEN)"
+	}
+	return msg
+}
+
+// AuthError is returned when a token is rejected, or it doesn't have permissions
+type AuthError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("authentication failed: %d %s", e.StatusCode, e.Message)
+}
+
+// Is makes AuthError of 401 match ErrUnauthorized
+func (e *AuthError) Is(target error) bool {
+	return target == ErrUnauthorized && e.StatusCode == http.StatusUnauthorized
+}
+
+// APIError is returned for other unsuccessful responses
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// Transient returns true for 5xx responses, which may succeed by retrying
+func (e *APIError) Transient() bool {
+	return transient.Status(e.StatusCode)
+}
+
+// checkResponse returns a typed error if the response is not successful
+func checkResponse(resp *http.Response, authenticated bool) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	message := strings.TrimSpace(string(body))
+	var data struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &data) == nil && data.Message != "" {
+		message = data.Message
+	}
+
+	if limit, ok := rateLimited(resp); ok {
+		limit.Message = message
+		limit.Authenticated = authenticated
+		return limit
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &AuthError{StatusCode: resp.StatusCode, Message: message}
+	case http.StatusNotFound:
+		return &NotFoundError{URL: resp.Request.URL.String(), Message: message, Authenticated: authenticated}
+	}
+	return &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Message: message}
+}
+
+// rateLimited returns RateLimitError if the response tells the rate limit is exceeded.
+// Both the primary rate limit and secondary rate limits (with Retry-After) are checked
+// https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+func rateLimited(resp *http.Response) (*RateLimitError, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil, false
+	}
+	limit := &RateLimitError{}
+	limit.Limit, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	limit.Remaining, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	switch {
+	case resp.Header.Get("Retry-After") != "":
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		limit.Reset = time.Now().Add(time.Duration(seconds) * time.Second)
+	case resp.Header.Get("X-RateLimit-Remaining") == "0":
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			limit.Reset = time.Unix(reset, 0)
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+	default:
+		// forbidden for other reasons, e.g. lack of permissions
+		return nil, false
+	}
+	return limit, true
+}
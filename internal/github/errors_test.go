@@ -0,0 +1,154 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+func TestClient_REST_errors(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+
+	tests := map[string]struct {
+		status int
+		header map[string]string
+		body   string
+		check  func(t *testing.T, err error)
+	}{
+		"rate limit exceeded": {
+			status: http.StatusForbidden,
+			header: map[string]string{
+				"X-RateLimit-Limit":     "60",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(reset, 10),
+			},
+			body: `{"message":"API rate limit exceeded for 127.0.0.1."}`,
+			check: func(t *testing.T, err error) {
+				var limit *RateLimitError
+				if !errors.As(err, &limit) {
+					t.Fatalf("error = %v, want RateLimitError", err)
+				}
+				if limit.Limit != 60 || limit.Remaining != 0 {
+					t.Errorf("limit = %d/%d, want 0/60", limit.Remaining, limit.Limit)
+				}
+				if limit.Reset.Unix() != reset {
+					t.Errorf("reset = %v, want %v", limit.Reset.Unix(), reset)
+				}
+				if limit.Message != "API rate limit exceeded for 127.0.0.1." {
+					t.Errorf("message = %q", limit.Message)
+				}
+			},
+		},
+		"secondary rate limit": {
+			status: http.StatusTooManyRequests,
+			header: map[string]string{"Retry-After": "30"},
+			check: func(t *testing.T, err error) {
+				var limit *RateLimitError
+				if !errors.As(err, &limit) {
+					t.Fatalf("error = %v, want RateLimitError", err)
+				}
+				if wait := time.Until(limit.Reset); wait < 25*time.Second || wait > 30*time.Second {
+					t.Errorf("reset in %v, want 30s", wait)
+				}
+			},
+		},
+		"bad credentials": {
+			status: http.StatusUnauthorized,
+			body:   `{"message":"Bad credentials"}`,
+			check: func(t *testing.T, err error) {
+				var auth *AuthError
+				if !errors.As(err, &auth) {
+					t.Fatalf("error = %v, want AuthError", err)
+				}
+				if !errors.Is(err, ErrUnauthorized) {
+					t.Errorf("error = %v, want ErrUnauthorized", err)
+				}
+			},
+		},
+		"forbidden": {
+			status: http.StatusForbidden,
+			header: map[string]string{"X-RateLimit-Remaining": "59"},
+			body:   `{"message":"Resource not accessible by integration"}`,
+			check: func(t *testing.T, err error) {
+				var auth *AuthError
+				if !errors.As(err, &auth) {
+					t.Fatalf("error = %v, want AuthError", err)
+				}
+				if errors.Is(err, ErrUnauthorized) {
+					t.Errorf("error = %v, want not ErrUnauthorized", err)
+				}
+			},
+		},
+		"not found": {
+			status: http.StatusNotFound,
+			body:   `{"message":"Not Found"}`,
+			check: func(t *testing.T, err error) {
+				var notFound *NotFoundError
+				if !errors.As(err, &notFound) {
+					t.Fatalf("error = %v, want NotFoundError", err)
+				}
+			},
+		},
+		"server error": {
+			status: http.StatusBadGateway,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("error = %v, want APIError", err)
+				}
+				if !transient.Is(err) {
+					t.Errorf("error = %v, want transient", err)
+				}
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewClient(ReplaceTripper(server.Client().Transport))
+			err := client.REST(http.MethodGet, server.URL+"/repos/owner/repo/releases/latest", nil, nil)
+			if err == nil {
+				t.Fatal("REST() error = nil")
+			}
+			tt.check(t, err)
+		})
+	}
+}
+
+func TestRateLimitError_Error(t *testing.T) {
+	tests := map[string]struct {
+		err  RateLimitError
+		want string
+	}{
+		"unauthenticated": {
+			err:  RateLimitError{Limit: 60},
+			want: "API rate limit exceeded (60 requests per hour). Set GITHUB_TOKEN to raise the limit",
+		},
+		"authenticated": {
+			err:  RateLimitError{Limit: 5000, Authenticated: true},
+			want: "API rate limit exceeded (5000 requests per hour)",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,15 +9,17 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
-	"runtime"
 	"strings"
+	"time"
 
 	"github.com/inconshreveable/go-update"
 	"github.com/mholt/archives"
 	"github.com/schollz/progressbar/v3"
 
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/checksum"
 	"github.com/babarot/afx/internal/logging"
+	"github.com/babarot/afx/internal/signature"
 )
 
 // Release represents a GitHub release and its client
@@ -28,10 +30,25 @@ type Release struct {
 	Assets Assets
 
 	client    *Client
+	download  *http.Client
 	workdir   string
 	verbose   bool
 	overwrite bool
 	filter    func(Assets) *Asset
+	matcher   Matcher
+	checksum  Checksum
+	verifier  signature.Verifier
+	signature string
+	cache     *cache.Cache
+}
+
+// Checksum represents the expected checksum of an asset to be downloaded.
+// Either Digest or FromAsset is used.
+type Checksum struct {
+	// Digest is SHA-256 digest of the asset
+	Digest string
+	// FromAsset is a name of the checksum file attached in the release
+	FromAsset string
 }
 
 // Asset represents GitHub release's asset.
@@ -39,13 +56,21 @@ type Release struct {
 type Asset struct {
 	Name string
 	URL  string
+
+	// SHA256 is a hex-encoded digest of the asset.
+	// It's set after the asset has been downloaded, or set beforehand
+	// if it's known (e.g. from lock file) to use the cached one.
+	SHA256 string
 }
 
 // ReleaseResponse is a response of github release structure
 // TODO: This may be better to become same one strucure as above
 type ReleaseResponse struct {
-	TagName string           `json:"tag_name"`
-	Assets  []AssetsResponse `json:"assets"`
+	TagName     string           `json:"tag_name"`
+	Draft       bool             `json:"draft"`
+	Prerelease  bool             `json:"prerelease"`
+	PublishedAt time.Time        `json:"published_at"`
+	Assets      []AssetsResponse `json:"assets"`
 }
 
 type AssetsResponse struct {
@@ -114,25 +139,50 @@ func WithFilter(filter func(Assets) *Asset) Option {
 	}
 }
 
-func NewRelease(ctx context.Context, owner, repo, tag string, opts ...Option) (*Release, error) {
+// WithMatcher sets rules to pick an asset when no filter is given
+func WithMatcher(matcher Matcher) Option {
+	return func(r *Release) {
+		r.matcher = matcher
+	}
+}
+
+func WithChecksum(checksum Checksum) Option {
+	return func(r *Release) {
+		r.checksum = checksum
+	}
+}
+
+// WithVerifier verifies the downloaded asset with the signature attached in the release.
+// If name is empty, it's looked up by the asset name with the verifier's extension.
+func WithVerifier(verifier signature.Verifier, name string) Option {
+	return func(r *Release) {
+		r.verifier = verifier
+		r.signature = name
+	}
+}
+
+// WithCache downloads assets through the cache
+func WithCache(c *cache.Cache) Option {
+	return func(r *Release) {
+		r.cache = c
+	}
+}
+
+// NewRelease gets a release from the releases API of a given host.
+// host is github.com or a host of GitHub Enterprise Server (empty means github.com).
+func NewRelease(ctx context.Context, host, owner, repo, tag string, opts ...Option) (*Release, error) {
 	if owner == "" || repo == "" {
 		return nil, errors.New("owner and repo are required")
 	}
 
-	releaseURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
-	switch tag {
-	case "latest", "":
-		releaseURL += "/latest"
-	default:
-		releaseURL += fmt.Sprintf("/tags/%s", tag)
-	}
+	releaseURL := releaseURL(host, owner, repo, tag)
 	log.Printf("[DEBUG] getting asset data from %s", releaseURL)
 
 	var resp ReleaseResponse
-	client := NewClient(
+	client := NewClient(clientOptions(ctx,
 		ReplaceTripper(logging.NewTransport("GitHub", http.DefaultTransport)),
-	)
-	err := client.REST(http.MethodGet, releaseURL, nil, &resp)
+	)...).ForHost(host)
+	err := client.RESTContext(ctx, http.MethodGet, releaseURL, nil, &resp)
 	if err != nil {
 		return nil, err
 	}
@@ -145,16 +195,27 @@ func NewRelease(ctx context.Context, owner, repo, tag string, opts ...Option) (*
 		})
 	}
 
-	tmp, err := os.MkdirTemp("", repo)
+	release, err := NewReleaseWithAssets(repo, resp.TagName, assets, opts...)
+	if err != nil {
+		return nil, err
+	}
+	release.client = client
+	release.download = NewDownloadClient(host)
+	return release, nil
+}
+
+// NewReleaseWithAssets returns a release whose assets are already known,
+// e.g. fetched from releases API of other hosting services than GitHub.
+func NewReleaseWithAssets(name, tag string, assets Assets, opts ...Option) (*Release, error) {
+	tmp, err := os.MkdirTemp("", name)
 	if err != nil {
 		return nil, err
 	}
 
 	release := &Release{
-		Name:    repo,
-		Tag:     resp.TagName,
+		Name:    name,
+		Tag:     tag,
 		Assets:  assets,
-		client:  client,
 		workdir: tmp,
 		verbose: false,
 		filter:  nil,
@@ -168,66 +229,47 @@ func NewRelease(ctx context.Context, owner, repo, tag string, opts ...Option) (*
 }
 
 func (r *Release) filterAssets() (Asset, error) {
+	selection, err := r.Select()
+	if err != nil {
+		return Asset{}, err
+	}
+	return selection.Asset, nil
+}
+
+// Select explains which asset is picked from the release and why.
+// Assets are picked by the filter if given, otherwise by the matcher.
+func (r *Release) Select() (Selection, error) {
 	log.Printf("[DEBUG] assets: %#v\n", getAssetKeys(r.Assets))
 
 	if len(r.Assets) == 0 {
-		return Asset{}, errors.New("no assets")
+		return Selection{}, errors.New("no assets")
 	}
 
 	if r.filter != nil {
 		log.Printf("[DEBUG] asset: filterfunc: started running")
 		asset := r.filter(r.Assets)
-		if asset != nil {
-			log.Printf("[DEBUG] asset: filterfunc: matched in assets")
-			return *asset, nil
+		if asset == nil {
+			log.Printf("[DEBUG] asset: filterfunc: not matched in assets")
+			return Selection{}, errors.New("could not find assets with given name")
+		}
+		log.Printf("[DEBUG] asset: filterfunc: matched in assets")
+		selection := Selection{Asset: *asset}
+		for _, a := range r.Assets {
+			candidate := Candidate{Asset: a, Reason: "not matched with asset filename"}
+			if a.Name == asset.Name {
+				candidate.Picked, candidate.Reason = true, "picked: matched with asset filename"
+			}
+			selection.Candidates = append(selection.Candidates, candidate)
 		}
-		log.Printf("[DEBUG] asset: filterfunc: not matched in assets")
-		return Asset{}, errors.New("could not find assets with given name")
+		return selection, nil
 	}
 
 	log.Printf("[DEBUG] asset: %s: using default assets filter", r.Name)
-	assets := *r.Assets.
-		filter(func(asset Asset) bool {
-			expr := `.*\.sbom`
-			// filter out
-			return !regexp.MustCompile(expr).MatchString(asset.Name)
-		}).
-		filter(func(asset Asset) bool {
-			expr := ".*(sha256sum|checksum).*"
-			// filter out
-			return !regexp.MustCompile(expr).MatchString(asset.Name)
-		}).
-		filter(func(asset Asset) bool {
-			expr := ""
-			switch runtime.GOOS {
-			case "darwin":
-				expr += ".*(apple|darwin|Darwin|osx|mac|macos|macOS).*"
-			case "linux":
-				expr += ".*(linux|hoe).*"
-			}
-			return regexp.MustCompile(expr).MatchString(asset.Name)
-		}).
-		filter(func(asset Asset) bool {
-			expr := ""
-			switch runtime.GOARCH {
-			case "amd64":
-				expr += ".*(amd64|x86.64).*"
-			case "386":
-				expr += ".*(386|86).*"
-			}
-			return regexp.MustCompile(expr).MatchString(asset.Name)
-		})
-
-	switch len(assets) {
-	case 0:
-		return Asset{}, errors.New("asset not found after filtered")
-	case 1:
-		return assets[0], nil
-	default:
-		log.Printf("[WARN] %d assets found: %#v", len(assets), getAssetKeys(assets))
-		log.Printf("[WARN] first one %q will be used", assets[0].Name)
-		return assets[0], nil
+	selection, err := r.matcher.Select(r.Assets)
+	for _, c := range selection.Candidates {
+		log.Printf("[DEBUG] asset: %s: %s", c.Asset.Name, c.Reason)
 	}
+	return selection, err
 }
 
 // Download downloads GitHub Release from given page
@@ -235,6 +277,9 @@ func (r *Release) Download(ctx context.Context) (Asset, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// keep all assets because checksum files are filtered out
+	all := append(Assets(nil), r.Assets...)
+
 	asset, err := r.filterAssets()
 	if err != nil {
 		log.Printf("[ERROR] %s: could not find assets available on your system", r.Name)
@@ -243,18 +288,6 @@ func (r *Release) Download(ctx context.Context) (Asset, error) {
 
 	log.Printf("[DEBUG] asset: %#v", asset)
 
-	req, err := http.NewRequest(http.MethodGet, asset.URL, nil)
-	if err != nil {
-		return asset, err
-	}
-
-	httpClient := http.DefaultClient
-	resp, err := httpClient.Do(req.WithContext(ctx))
-	if err != nil {
-		return asset, err
-	}
-	defer resp.Body.Close()
-
 	_ = os.MkdirAll(r.workdir, os.ModePerm)
 	archive := filepath.Join(r.workdir, asset.Name)
 
@@ -264,18 +297,107 @@ func (r *Release) Download(ctx context.Context) (Asset, error) {
 	}
 	defer file.Close()
 
-	var w io.Writer
+	opts := []cache.FetchOption{cache.WithHTTPClient(r.httpClient())}
+	digest := asset.SHA256
+	if digest == "" {
+		// the cached asset can be used if its digest is given in config
+		digest, _ = checksum.Normalize(r.checksum.Digest)
+	}
+	if digest != "" {
+		opts = append(opts, cache.WithDigest(digest))
+	}
 	if r.verbose {
-		w = io.MultiWriter(file, progressbar.DefaultBytes(
-			resp.ContentLength,
-			"Downloading",
-		))
-	} else {
-		w = file
+		opts = append(opts, cache.WithProgress(func(size int64) io.Writer {
+			return progressbar.DefaultBytes(size, "Downloading")
+		}))
+	}
+
+	entry, err := r.cache.Fetch(ctx, asset.URL, file, opts...)
+	if err != nil {
+		return asset, fmt.Errorf("%s: %w", asset.Name, err)
 	}
 
-	_, err = io.Copy(w, resp.Body)
-	return asset, err
+	asset.SHA256 = entry.SHA256
+	log.Printf("[DEBUG] asset: %s: sha256: %s", asset.Name, asset.SHA256)
+
+	if err := r.verifyChecksum(ctx, all, asset); err != nil {
+		file.Close()
+		os.Remove(archive)
+		return asset, err
+	}
+	if err := r.verifySignature(ctx, all, asset, archive); err != nil {
+		file.Close()
+		os.Remove(archive)
+		return asset, err
+	}
+	return asset, nil
+}
+
+// httpClient returns a client to download assets and files attached to the release.
+// It sends the token of the host only for releases got from GitHub
+func (r *Release) httpClient() *http.Client {
+	if r.download == nil {
+		return http.DefaultClient
+	}
+	return r.download
+}
+
+// verifySignature verifies the downloaded asset with its signature.
+// It does nothing if no verifier is given.
+func (r *Release) verifySignature(ctx context.Context, assets Assets, asset Asset, archive string) error {
+	if r.verifier == nil {
+		return nil
+	}
+	name := r.signature
+	if name == "" {
+		name = asset.Name + r.verifier.Extension()
+	}
+	var found *Asset
+	for _, a := range assets {
+		if a.Name == name {
+			found = &a
+			break
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("%s: signature file not found in release assets", name)
+	}
+	sig, err := signature.Fetch(ctx, r.httpClient(), found.URL)
+	if err != nil {
+		return fmt.Errorf("%s: failed to get signature: %w", name, err)
+	}
+	if err := signature.VerifyFile(r.verifier, archive, sig); err != nil {
+		return fmt.Errorf("%s: %w", asset.Name, err)
+	}
+	log.Printf("[DEBUG] asset: %s: signature verified with %s", asset.Name, name)
+	return nil
+}
+
+// verifyChecksum verifies the downloaded asset with the expected checksum.
+// It does nothing if no checksum is given.
+func (r *Release) verifyChecksum(ctx context.Context, assets Assets, asset Asset) error {
+	want := r.checksum.Digest
+	if want == "" && r.checksum.FromAsset != "" {
+		var found *Asset
+		for _, a := range assets {
+			if a.Name == r.checksum.FromAsset {
+				found = &a
+				break
+			}
+		}
+		if found == nil {
+			return fmt.Errorf("%s: checksum file not found in release assets", r.checksum.FromAsset)
+		}
+		digest, err := checksum.Fetch(ctx, r.httpClient(), found.URL, asset.Name)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get checksum: %w", r.checksum.FromAsset, err)
+		}
+		want = digest
+	}
+	if want == "" {
+		return nil
+	}
+	return checksum.Verify(asset.Name, want, asset.SHA256)
 }
 
 // Unarchive extracts downloaded asset
@@ -400,16 +522,9 @@ func (r *Release) Install(to string) error {
 	})
 }
 
-func HasRelease(httpClient *http.Client, owner, repo, tag string) (bool, error) {
+func HasRelease(httpClient *http.Client, host, owner, repo, tag string) (bool, error) {
 	// https://github.com/cli/cli/blob/9596fd5368cdbd30d08555266890a2312e22eba9/pkg/cmd/extension/http.go#L110
-	releaseURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
-	switch tag {
-	case "latest", "":
-		releaseURL += "/latest"
-	default:
-		releaseURL += fmt.Sprintf("/tags/%s", tag)
-	}
-	req, err := http.NewRequest("GET", releaseURL, nil)
+	req, err := http.NewRequest("GET", releaseURL(host, owner, repo, tag), nil)
 	if err != nil {
 		return false, err
 	}
@@ -421,3 +536,52 @@ func HasRelease(httpClient *http.Client, owner, repo, tag string) (bool, error)
 	defer resp.Body.Close()
 	return resp.StatusCode < 299, nil
 }
+
+// maxReleasePages is a limit of pages to list releases (100 releases per page)
+const maxReleasePages = 10
+
+// ListReleases returns published releases (not drafts) in order of the API (newest first).
+// Releases are paged through up to 1000.
+func ListReleases(ctx context.Context, host, owner, repo string) ([]ReleaseResponse, error) {
+	if owner == "" || repo == "" {
+		return nil, errors.New("owner and repo are required")
+	}
+
+	client := NewClient(clientOptions(ctx,
+		ReplaceTripper(logging.NewTransport("GitHub", http.DefaultTransport)),
+	)...).ForHost(host)
+
+	var published []ReleaseResponse
+	for page := 1; page <= maxReleasePages; page++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var releases []ReleaseResponse
+		url := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100&page=%d", APIURL(host), owner, repo, page)
+		if err := client.RESTContext(ctx, http.MethodGet, url, nil, &releases); err != nil {
+			return nil, err
+		}
+		for _, release := range releases {
+			if release.Draft {
+				continue
+			}
+			published = append(published, release)
+		}
+		if len(releases) < 100 {
+			break
+		}
+	}
+	log.Printf("[DEBUG] %s/%s: found %d releases", owner, repo, len(published))
+	return published, nil
+}
+
+// releaseURL returns an API URL to get a release of a given tag
+func releaseURL(host, owner, repo, tag string) string {
+	u := fmt.Sprintf("%s/repos/%s/%s/releases", APIURL(host), owner, repo)
+	switch tag {
+	case "latest", "":
+		return u + "/latest"
+	default:
+		return u + fmt.Sprintf("/tags/%s", tag)
+	}
+}
@@ -1,9 +1,16 @@
 package github
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -195,3 +202,194 @@ func TestNewClient(t *testing.T) {
 		t.Error("NewClient().http is nil")
 	}
 }
+
+func TestRelease_Download_checksum(t *testing.T) {
+	// sha256 of "foo\n"
+	const digest = "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tool":
+			_, _ = io.WriteString(w, "foo\n")
+		case "/checksums.txt":
+			_, _ = io.WriteString(w, digest+"  tool\n")
+		case "/bad-checksums.txt":
+			_, _ = io.WriteString(w, strings.Repeat("0", 64)+"  tool\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		checksum Checksum
+		wantErr  bool
+	}{
+		"no checksum": {
+			checksum: Checksum{},
+		},
+		"inline digest": {
+			checksum: Checksum{Digest: "sha256:" + digest},
+		},
+		"inline digest mismatch": {
+			checksum: Checksum{Digest: strings.Repeat("0", 64)},
+			wantErr:  true,
+		},
+		"from asset": {
+			checksum: Checksum{FromAsset: "checksums.txt"},
+		},
+		"from asset mismatch": {
+			checksum: Checksum{FromAsset: "bad-checksums.txt"},
+			wantErr:  true,
+		},
+		"from asset not found": {
+			checksum: Checksum{FromAsset: "SHA256SUMS"},
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &Release{
+				Name: "tool",
+				Assets: Assets{
+					{Name: "tool", URL: server.URL + "/tool"},
+					{Name: "checksums.txt", URL: server.URL + "/checksums.txt"},
+					{Name: "bad-checksums.txt", URL: server.URL + "/bad-checksums.txt"},
+				},
+				workdir: t.TempDir(),
+				filter: func(assets Assets) *Asset {
+					return &assets[0]
+				},
+				checksum: tt.checksum,
+			}
+			asset, err := r.Download(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if asset.SHA256 != digest {
+				t.Errorf("Download() sha256 = %q, want %q", asset.SHA256, digest)
+			}
+			_, statErr := os.Stat(filepath.Join(r.workdir, "tool"))
+			if tt.wantErr && statErr == nil {
+				t.Error("Download() should remove the asset on checksum mismatch")
+			}
+		})
+	}
+}
+
+// stubVerifier accepts only a signature which equals to "ok"
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(artifact, signature []byte) error {
+	if string(signature) != "ok" {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func (stubVerifier) Extension() string { return ".sig" }
+
+func TestRelease_Download_signature(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tool":
+			_, _ = io.WriteString(w, "foo\n")
+		case "/tool.sig":
+			_, _ = io.WriteString(w, "ok")
+		case "/bad.sig":
+			_, _ = io.WriteString(w, "ng")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		signature string
+		wantErr   bool
+	}{
+		"default signature name": {
+			signature: "",
+		},
+		"explicit signature name": {
+			signature: "tool.sig",
+		},
+		"invalid signature": {
+			signature: "bad.sig",
+			wantErr:   true,
+		},
+		"signature not found": {
+			signature: "tool.minisig",
+			wantErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &Release{
+				Name: "tool",
+				Assets: Assets{
+					{Name: "tool", URL: server.URL + "/tool"},
+					{Name: "tool.sig", URL: server.URL + "/tool.sig"},
+					{Name: "bad.sig", URL: server.URL + "/bad.sig"},
+				},
+				workdir: t.TempDir(),
+				filter: func(assets Assets) *Asset {
+					return &assets[0]
+				},
+			}
+			WithVerifier(stubVerifier{}, tt.signature)(r)
+			_, err := r.Download(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, statErr := os.Stat(filepath.Join(r.workdir, "tool"))
+			if tt.wantErr && statErr == nil {
+				t.Error("Download() should remove the asset on invalid signature")
+			}
+		})
+	}
+}
+
+func TestListReleases(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/owner/tool/releases" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+
+		var releases []ReleaseResponse
+		switch page {
+		case "1":
+			for i := 100; i > 0; i-- {
+				releases = append(releases, ReleaseResponse{TagName: fmt.Sprintf("v1.%d.0", i)})
+			}
+		case "2":
+			releases = []ReleaseResponse{
+				{TagName: "v0.2.0", Draft: true},
+				{TagName: "v0.1.0"},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(releases)
+	}))
+	defer server.Close()
+
+	releases, err := ListReleases(context.Background(), server.URL, "owner", "tool")
+	if err != nil {
+		t.Fatalf("ListReleases() error: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"1", "2"}, pages); diff != "" {
+		t.Errorf("pages mismatch (-want +got):\n%s", diff)
+	}
+	if len(releases) != 101 {
+		t.Fatalf("ListReleases() returned %d releases, want 101", len(releases))
+	}
+	if first, last := releases[0].TagName, releases[100].TagName; first != "v1.100.0" || last != "v0.1.0" {
+		t.Errorf("ListReleases() = [%s ... %s], want [v1.100.0 ... v0.1.0]", first, last)
+	}
+}
@@ -0,0 +1,64 @@
+package github
+
+import (
+	"os"
+	"strings"
+)
+
+// DefaultHost is a host of GitHub.com
+const DefaultHost = "github.com"
+
+// APIURL returns a base URL of REST API for a given host.
+// Hosts other than github.com are regarded as GitHub Enterprise Server,
+// whose API is served under /api/v3. host can have a scheme (e.g. http://ghe.local)
+func APIURL(host string) string {
+	switch host {
+	case "", DefaultHost:
+		return "https://api.github.com"
+	}
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	return strings.TrimSuffix(host, "/") + "/api/v3"
+}
+
+// Getenv looks up environment variables of tokens. It can be replaced to take
+// tokens which are not set in the environment, e.g. kept in the secret store
+var Getenv = os.Getenv
+
+// Token returns a token to access a given host.
+//
+// GITHUB_TOKEN_<HOST> (e.g. GITHUB_TOKEN_GHE_EXAMPLE_COM for ghe.example.com)
+// is preferred if set. Otherwise GITHUB_TOKEN is used for github.com and
+// GH_ENTERPRISE_TOKEN for other hosts, so that a token for github.com is never
+// sent to other hosts.
+func Token(host string) string {
+	if host == "" {
+		host = DefaultHost
+	}
+	if token := Getenv(tokenEnv(host)); token != "" {
+		return token
+	}
+	if host == DefaultHost {
+		return Getenv("GITHUB_TOKEN")
+	}
+	return Getenv("GH_ENTERPRISE_TOKEN")
+}
+
+// tokenEnv returns a name of environment variable for a host-specific token
+func tokenEnv(host string) string {
+	if _, after, ok := strings.Cut(host, "://"); ok {
+		host = after
+	}
+	host = strings.TrimSuffix(host, "/")
+	return "GITHUB_TOKEN_" + strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, host)
+}
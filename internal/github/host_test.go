@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAPIURL(t *testing.T) {
+	tests := map[string]struct {
+		host string
+		want string
+	}{
+		"empty": {
+			host: "",
+			want: "https://api.github.com",
+		},
+		"github.com": {
+			host: "github.com",
+			want: "https://api.github.com",
+		},
+		"enterprise": {
+			host: "ghe.example.com",
+			want: "https://ghe.example.com/api/v3",
+		},
+		"with scheme": {
+			host: "http://localhost:8080/",
+			want: "http://localhost:8080/api/v3",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := APIURL(tt.host); got != tt.want {
+				t.Errorf("APIURL(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	tests := map[string]struct {
+		host string
+		env  map[string]string
+		want string
+	}{
+		"github.com": {
+			host: "github.com",
+			env:  map[string]string{"GITHUB_TOKEN": "public"},
+			want: "public",
+		},
+		"github.com by default": {
+			host: "",
+			env:  map[string]string{"GITHUB_TOKEN": "public"},
+			want: "public",
+		},
+		"host specific token is preferred": {
+			host: "github.com",
+			env: map[string]string{
+				"GITHUB_TOKEN":            "public",
+				"GITHUB_TOKEN_GITHUB_COM": "specific",
+			},
+			want: "specific",
+		},
+		"enterprise": {
+			host: "ghe.example.com",
+			env: map[string]string{
+				"GITHUB_TOKEN":                 "public",
+				"GITHUB_TOKEN_GHE_EXAMPLE_COM": "enterprise",
+			},
+			want: "enterprise",
+		},
+		"enterprise with scheme and port": {
+			host: "http://ghe.local:8080",
+			env:  map[string]string{"GITHUB_TOKEN_GHE_LOCAL_8080": "enterprise"},
+			want: "enterprise",
+		},
+		"enterprise falls back to GH_ENTERPRISE_TOKEN": {
+			host: "ghe.example.com",
+			env: map[string]string{
+				"GITHUB_TOKEN":        "public",
+				"GH_ENTERPRISE_TOKEN": "enterprise",
+			},
+			want: "enterprise",
+		},
+		"github.com token is not sent to enterprise": {
+			host: "ghe.example.com",
+			env:  map[string]string{"GITHUB_TOKEN": "public"},
+			want: "",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"GITHUB_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_TOKEN_GITHUB_COM", "GITHUB_TOKEN_GHE_EXAMPLE_COM", "GITHUB_TOKEN_GHE_LOCAL_8080"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := Token(tt.host); got != tt.want {
+				t.Errorf("Token(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRelease_enterprise(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		_ = json.NewEncoder(w).Encode(ReleaseResponse{
+			TagName: "v1.2.0",
+			Assets: []AssetsResponse{
+				{Name: "tool_linux_amd64.tar.gz", BrowserDownloadURL: "http://ghe.local/tool_linux_amd64.tar.gz"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("GITHUB_TOKEN", "public")
+	t.Setenv(tokenEnv(server.URL), "enterprise")
+
+	release, err := NewRelease(context.Background(), server.URL, "owner", "tool", "v1.2.0")
+	if err != nil {
+		t.Fatalf("NewRelease() error: %v", err)
+	}
+
+	if want := "/api/v3/repos/owner/tool/releases/tags/v1.2.0"; gotPath != want {
+		t.Errorf("request path = %q, want %q", gotPath, want)
+	}
+	if want := "token enterprise"; gotAuth != want {
+		t.Errorf("Authorization header = %q, want %q", gotAuth, want)
+	}
+	if release.Tag != "v1.2.0" {
+		t.Errorf("Tag = %q, want %q", release.Tag, "v1.2.0")
+	}
+	want := Assets{{Name: "tool_linux_amd64.tar.gz", URL: "http://ghe.local/tool_linux_amd64.tar.gz"}}
+	if diff := cmp.Diff(want, release.Assets); diff != "" {
+		t.Errorf("Assets mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,320 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Matcher picks a release asset suitable for the platform by declarative rules.
+// The zero value picks an asset for the running platform with the default aliases.
+type Matcher struct {
+	// Include is a list of regexps which an asset name must match all of
+	Include []string
+	// Exclude is a list of regexps to drop assets whose name matches any of them
+	Exclude []string
+	// Prefer is an ordered list of regexps. When several assets remain,
+	// the one matching earlier ones wins (e.g. "musl", then `\.tar\.gz$`)
+	Prefer []string
+	// Aliases adds names of OS or arch to find in asset names
+	// (e.g. {"arm64": ["aarch64"], "darwin": ["universal"]})
+	Aliases map[string][]string
+
+	// GOOS and GOARCH are the target platform (default: runtime.GOOS and runtime.GOARCH)
+	GOOS   string
+	GOARCH string
+}
+
+// Candidate is an asset examined by Matcher and why it's picked or not
+type Candidate struct {
+	Asset  Asset
+	Picked bool
+	Reason string
+}
+
+// Selection is a result of picking an asset
+type Selection struct {
+	Asset      Asset
+	Candidates []Candidate
+}
+
+// default names of OS and arch found in asset names.
+// The order matters: e.g. "x86" in "x86_64" must be detected as amd64, not 386
+var (
+	osOrder   = []string{"darwin", "linux", "windows", "freebsd", "openbsd", "netbsd"}
+	osAliases = map[string][]string{
+		"darwin":  {"darwin", "apple", "macos", "osx", "mac"},
+		"linux":   {"linux"},
+		"windows": {"windows", "win64", "win32", "win"},
+		"freebsd": {"freebsd"},
+		"openbsd": {"openbsd"},
+		"netbsd":  {"netbsd"},
+	}
+	archOrder   = []string{"amd64", "arm64", "arm", "386"}
+	archAliases = map[string][]string{
+		"amd64": {"amd64", "x86_64", "x86-64", "x64", "64bit", "64-bit"},
+		"arm64": {"arm64", "aarch64", "armv8"},
+		"arm":   {"armv7", "armv6", "armhf", "arm"},
+		"386":   {"386", "i386", "i686", "x86", "32bit", "32-bit"},
+	}
+)
+
+// non-installable files attached to releases
+var ignored = []struct {
+	re     *regexp.Regexp
+	reason string
+}{
+	{regexp.MustCompile(`\.sbom`), "SBOM"},
+	{regexp.MustCompile(`(sha256sum|checksum|\.sha(256|512)$)`), "checksum file"},
+	{regexp.MustCompile(`\.(sig|minisig|asc|pem|bundle)$`), "signature"},
+}
+
+// platform is a table of names of OS or arch
+type platform struct {
+	order   []string
+	aliases map[string][]*regexp.Regexp
+}
+
+func newPlatform(order []string, aliases map[string][]string) platform {
+	p := platform{aliases: map[string][]*regexp.Regexp{}}
+	for _, key := range order {
+		p.add(key, aliases[key]...)
+	}
+	return p
+}
+
+func (p *platform) add(key string, names ...string) {
+	if !slices.Contains(p.order, key) {
+		p.order = append(p.order, key)
+	}
+	for _, name := range names {
+		// match as a word, e.g. "arm" should not match "arm64"
+		expr := `(?i)(^|[^a-z0-9])` + regexp.QuoteMeta(name) + `($|[^a-z0-9])`
+		p.aliases[key] = append(p.aliases[key], regexp.MustCompile(expr))
+	}
+}
+
+// detect returns the key found in a given name and the matched text
+func (p platform) detect(name string) (string, string) {
+	for _, key := range p.order {
+		for _, re := range p.aliases[key] {
+			if m := re.FindStringSubmatch(name); m != nil {
+				return key, strings.Trim(m[0], "-_.")
+			}
+		}
+	}
+	return "", ""
+}
+
+// DetectPlatform returns OS and arch found in an asset name with the default aliases,
+// and how they're written in the name (e.g. "x86_64" for amd64)
+func DetectPlatform(name string) (goos, osText, goarch, archText string) {
+	goos, osText = newPlatform(osOrder, osAliases).detect(name)
+	goarch, archText = newPlatform(archOrder, archAliases).detect(name)
+	return goos, osText, goarch, archText
+}
+
+// compile compiles regexps in a given list
+func compile(field string, exprs []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: invalid regexp: %w", field, expr, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Validate returns an error if the rules are invalid
+func (m Matcher) Validate() error {
+	var errs []error
+	for field, exprs := range map[string][]string{
+		"include": m.Include, "exclude": m.Exclude, "prefer": m.Prefer,
+	} {
+		if _, err := compile(field, exprs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Select picks an asset by the rules and explains why each asset is picked or not.
+//
+// Assets are examined in this order: non-installable files (checksums,
+// signatures and SBOMs), exclude, include, OS and arch. OS and arch are
+// detected from asset names by aliases, and assets without them are used only
+// if no asset is for the platform. Then the most preferred one is picked.
+func (m Matcher) Select(assets Assets) (Selection, error) {
+	include, err := compile("include", m.Include)
+	if err != nil {
+		return Selection{}, err
+	}
+	exclude, err := compile("exclude", m.Exclude)
+	if err != nil {
+		return Selection{}, err
+	}
+	prefer, err := compile("prefer", m.Prefer)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	goos, goarch := m.GOOS, m.GOARCH
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	oses, arches := newPlatform(osOrder, osAliases), newPlatform(archOrder, archAliases)
+	keys := make([]string, 0, len(m.Aliases))
+	for key := range m.Aliases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := osAliases[key]; ok || key == goos {
+			oses.add(key, m.Aliases[key]...)
+		} else {
+			arches.add(key, m.Aliases[key]...)
+		}
+	}
+
+	candidates := make([]Candidate, len(assets))
+	for i, asset := range assets {
+		candidates[i] = Candidate{Asset: asset}
+	}
+	alive := func() []int {
+		var idx []int
+		for i, c := range candidates {
+			if c.Reason == "" {
+				idx = append(idx, i)
+			}
+		}
+		return idx
+	}
+	drop := func(reason func(Asset) string) {
+		for _, i := range alive() {
+			candidates[i].Reason = reason(candidates[i].Asset)
+		}
+	}
+
+	drop(func(asset Asset) string {
+		for _, ig := range ignored {
+			if ig.re.MatchString(asset.Name) {
+				return ig.reason
+			}
+		}
+		return ""
+	})
+	drop(func(asset Asset) string {
+		for _, re := range exclude {
+			if re.MatchString(asset.Name) {
+				return fmt.Sprintf("excluded by %q", re)
+			}
+		}
+		return ""
+	})
+	drop(func(asset Asset) string {
+		for _, re := range include {
+			if !re.MatchString(asset.Name) {
+				return fmt.Sprintf("not included by %q", re)
+			}
+		}
+		return ""
+	})
+
+	// notes explains why the picked asset is for the platform
+	notes := map[int][]string{}
+	for _, target := range []struct {
+		kind  string
+		key   string
+		table platform
+	}{
+		{"OS", goos, oses},
+		{"arch", goarch, arches},
+	} {
+		idx := alive()
+		if len(idx) < 2 {
+			// no more need to filter
+			break
+		}
+		detected := map[int]string{}
+		found := false
+		for _, i := range idx {
+			key, text := target.table.detect(candidates[i].Asset.Name)
+			detected[i] = key
+			if key == target.key {
+				found = true
+				notes[i] = append(notes[i], fmt.Sprintf("%s %s (%q)", target.kind, key, text))
+			}
+		}
+		for _, i := range idx {
+			switch key := detected[i]; {
+			case key == target.key:
+			case key != "":
+				candidates[i].Reason = fmt.Sprintf("for %s %s", target.kind, key)
+			case found:
+				candidates[i].Reason = fmt.Sprintf("no %s in name", target.kind)
+			}
+		}
+	}
+
+	idx := alive()
+	if len(idx) == 0 {
+		return Selection{Candidates: candidates}, errors.New("asset not found after filtered")
+	}
+
+	// rank by preferences: matching earlier preferences wins
+	rank := func(asset Asset) []bool {
+		r := make([]bool, len(prefer))
+		for i, re := range prefer {
+			r[i] = re.MatchString(asset.Name)
+		}
+		return r
+	}
+	better := func(a, b []bool) int {
+		for i := range a {
+			if a[i] != b[i] {
+				if a[i] {
+					return -1
+				}
+				return 1
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return better(rank(candidates[idx[i]].Asset), rank(candidates[idx[j]].Asset)) < 0
+	})
+
+	picked := idx[0]
+	best := rank(candidates[picked].Asset)
+	for i, re := range prefer {
+		if best[i] {
+			notes[picked] = append(notes[picked], fmt.Sprintf("preferred by %q", re))
+		}
+	}
+	for _, i := range idx[1:] {
+		if better(best, rank(candidates[i].Asset)) < 0 {
+			candidates[i].Reason = fmt.Sprintf("less preferred than %s", candidates[picked].Asset.Name)
+			continue
+		}
+		log.Printf("[WARN] %s: equally matched with %s, first one is used", candidates[i].Asset.Name, candidates[picked].Asset.Name)
+		candidates[i].Reason = fmt.Sprintf("equally matched, but %s comes first", candidates[picked].Asset.Name)
+	}
+
+	candidates[picked].Picked = true
+	candidates[picked].Reason = "picked"
+	if len(notes[picked]) > 0 {
+		candidates[picked].Reason += ": " + strings.Join(notes[picked], ", ")
+	}
+
+	return Selection{Asset: candidates[picked].Asset, Candidates: candidates}, nil
+}
@@ -0,0 +1,182 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func assetsOf(names ...string) Assets {
+	var assets Assets
+	for _, name := range names {
+		assets = append(assets, Asset{Name: name})
+	}
+	return assets
+}
+
+func TestMatcher_Select(t *testing.T) {
+	ripgrep := assetsOf(
+		"ripgrep-14.1.0-aarch64-apple-darwin.tar.gz",
+		"ripgrep-14.1.0-aarch64-unknown-linux-gnu.tar.gz",
+		"ripgrep-14.1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+		"ripgrep-14.1.0-x86_64-apple-darwin.tar.gz",
+		"ripgrep-14.1.0-x86_64-pc-windows-msvc.zip",
+		"ripgrep-14.1.0-x86_64-unknown-linux-musl.tar.gz",
+		"ripgrep-14.1.0-x86_64-unknown-linux-musl.zip",
+		"ripgrep-14.1.0-i686-unknown-linux-gnu.tar.gz",
+		"ripgrep_14.1.0-1_amd64.deb",
+	)
+
+	tests := map[string]struct {
+		matcher Matcher
+		assets  Assets
+		want    string
+		wantErr bool
+	}{
+		"linux arm64": {
+			matcher: Matcher{GOOS: "linux", GOARCH: "arm64"},
+			assets:  ripgrep,
+			want:    "ripgrep-14.1.0-aarch64-unknown-linux-gnu.tar.gz",
+		},
+		"darwin arm64": {
+			matcher: Matcher{GOOS: "darwin", GOARCH: "arm64"},
+			assets:  ripgrep,
+			want:    "ripgrep-14.1.0-aarch64-apple-darwin.tar.gz",
+		},
+		"linux 386 is not confused with x86_64": {
+			matcher: Matcher{GOOS: "linux", GOARCH: "386"},
+			assets:  ripgrep,
+			want:    "ripgrep-14.1.0-i686-unknown-linux-gnu.tar.gz",
+		},
+		"prefer tar.gz over zip": {
+			matcher: Matcher{
+				GOOS: "linux", GOARCH: "amd64",
+				Exclude: []string{`\.deb$`},
+				Prefer:  []string{`musl`, `\.tar\.gz$`, `\.zip$`},
+			},
+			assets: ripgrep,
+			want:   "ripgrep-14.1.0-x86_64-unknown-linux-musl.tar.gz",
+		},
+		"prefer musl over gnu": {
+			matcher: Matcher{
+				GOOS: "linux", GOARCH: "amd64",
+				Prefer: []string{`musl`},
+			},
+			assets: assetsOf("tool-x86_64-unknown-linux-gnu.tar.gz", "tool-x86_64-unknown-linux-musl.tar.gz"),
+			want:   "tool-x86_64-unknown-linux-musl.tar.gz",
+		},
+		"include": {
+			matcher: Matcher{
+				GOOS: "linux", GOARCH: "amd64",
+				Include: []string{`\.zip$`},
+			},
+			assets: ripgrep,
+			want:   "ripgrep-14.1.0-x86_64-unknown-linux-musl.zip",
+		},
+		"aliases": {
+			matcher: Matcher{
+				GOOS: "darwin", GOARCH: "arm64",
+				Aliases: map[string][]string{"arm64": {"universal"}},
+			},
+			assets: assetsOf("tool-linux-amd64.tar.gz", "tool-darwin-universal.tar.gz"),
+			want:   "tool-darwin-universal.tar.gz",
+		},
+		"no arch in name": {
+			matcher: Matcher{GOOS: "darwin", GOARCH: "arm64"},
+			assets:  assetsOf("tool-linux-amd64.tar.gz", "tool-macos.tar.gz"),
+			want:    "tool-macos.tar.gz",
+		},
+		"only one asset": {
+			matcher: Matcher{GOOS: "linux", GOARCH: "arm64"},
+			assets:  assetsOf("tool"),
+			want:    "tool",
+		},
+		"not found": {
+			matcher: Matcher{GOOS: "linux", GOARCH: "arm64"},
+			assets:  assetsOf("tool-darwin-arm64", "tool-windows-arm64.exe"),
+			wantErr: true,
+		},
+		"invalid regexp": {
+			matcher: Matcher{Include: []string{"("}},
+			assets:  ripgrep,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tt.matcher.Select(tt.assets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Asset.Name != tt.want {
+				t.Errorf("Select() = %q, want %q", got.Asset.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_Select_reasons(t *testing.T) {
+	m := Matcher{
+		GOOS: "linux", GOARCH: "arm64",
+		Exclude: []string{`\.deb$`},
+		Prefer:  []string{`musl`},
+	}
+	assets := assetsOf(
+		"tool-aarch64-linux-gnu.tar.gz",
+		"tool-aarch64-linux-musl.tar.gz",
+		"tool-x86_64-linux-musl.tar.gz",
+		"tool-aarch64-darwin.tar.gz",
+		"tool_arm64.deb",
+		"checksums.txt",
+	)
+
+	got, err := m.Select(assets)
+	if err != nil {
+		t.Fatalf("Select() error: %v", err)
+	}
+
+	want := map[string]string{
+		"tool-aarch64-linux-gnu.tar.gz":  "less preferred than tool-aarch64-linux-musl.tar.gz",
+		"tool-aarch64-linux-musl.tar.gz": `picked: OS linux ("linux"), arch arm64 ("aarch64"), preferred by "musl"`,
+		"tool-x86_64-linux-musl.tar.gz":  "for arch amd64",
+		"tool-aarch64-darwin.tar.gz":     "for OS darwin",
+		"tool_arm64.deb":                 `excluded by "\\.deb$"`,
+		"checksums.txt":                  "checksum file",
+	}
+	reasons := map[string]string{}
+	for _, c := range got.Candidates {
+		reasons[c.Asset.Name] = c.Reason
+	}
+	if diff := cmp.Diff(want, reasons); diff != "" {
+		t.Errorf("reasons mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMatcher_Validate(t *testing.T) {
+	if err := (Matcher{Prefer: []string{`musl`, `\.tar\.gz$`}}).Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	if err := (Matcher{Exclude: []string{`[`}}).Validate(); err == nil {
+		t.Error("Validate() expected error for invalid regexp")
+	}
+}
+
+func TestDetectPlatform(t *testing.T) {
+	tests := map[string][4]string{
+		"bat-v0.24.0-x86_64-apple-darwin.tar.gz": {"darwin", "darwin", "amd64", "x86_64"},
+		"fzf-0.44.1-linux_arm64.tar.gz":          {"linux", "linux", "arm64", "arm64"},
+		"gh_2.40.0_macOS_amd64.zip":              {"darwin", "macOS", "amd64", "amd64"},
+		"jq-linux-i386":                          {"linux", "linux", "386", "i386"},
+		"tool.tar.gz":                            {"", "", "", ""},
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			goos, osText, goarch, archText := DetectPlatform(name)
+			if diff := cmp.Diff(want, [4]string{goos, osText, goarch, archText}); diff != "" {
+				t.Errorf("DetectPlatform() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrUnauthorized matches AuthError returned when a token is rejected by a host
+	ErrUnauthorized = errors.New("bad credentials")
+
+	// ErrRateLimitDisabled is returned when rate limiting is not enabled on
+	// GitHub Enterprise Server
+	ErrRateLimitDisabled = errors.New("rate limiting is not enabled")
+)
+
+// RateLimit represents the rate limit of the core REST API
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"-"`
+}
+
+// GetRateLimit gets the rate limit of a given host with the token for it.
+// Getting the rate limit doesn't count against the rate limit
+func (c Client) GetRateLimit(ctx context.Context) (RateLimit, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, APIURL(c.host)+"/rate_limit", nil)
+	if err != nil {
+		return RateLimit{}, err
+	}
+	token := Token(c.host)
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return RateLimit{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return RateLimit{}, ErrRateLimitDisabled
+	}
+	if err := checkResponse(resp, token != ""); err != nil {
+		return RateLimit{}, fmt.Errorf("failed to get rate limit: %w", err)
+	}
+
+	var data struct {
+		Resources struct {
+			Core struct {
+				RateLimit
+				Reset int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return RateLimit{}, err
+	}
+	core := data.Resources.Core
+	core.RateLimit.Reset = time.Unix(core.Reset, 0)
+	return core.RateLimit, nil
+}
@@ -0,0 +1,199 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultRateLimitWait is how long to wait for the rate limit to be reset by default
+const DefaultRateLimitWait = time.Minute
+
+// WithRateLimitWait waits for the rate limit to be reset and sends a request
+// again if it's exceeded, as long as it's reset within max.
+// Otherwise the response is returned as it is, which REST fails with RateLimitError
+func WithRateLimitWait(max time.Duration) ClientOption {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		return &rateLimitTransport{tr: tr, max: max}
+	}
+}
+
+type rateLimitTransport struct {
+	tr  http.RoundTripper
+	max time.Duration
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.tr.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	limit, ok := rateLimited(resp)
+	// requests with body cannot be sent again
+	if !ok || req.Body != nil || limit.Reset.IsZero() {
+		return resp, nil
+	}
+	wait := time.Until(limit.Reset)
+	if wait > t.max {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	log.Printf("[INFO] %s: rate limit exceeded, waiting %s for reset", req.URL.Host, wait.Round(time.Second))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return t.tr.RoundTrip(req)
+}
+
+// WithETagCache caches successful responses of GET requests in dir, and
+// revalidates them with ETag. Responses not modified (304) don't count
+// against the rate limit, so checking unchanged releases costs no quota
+func WithETagCache(dir string) ClientOption {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		return &etagTransport{tr: tr, dir: dir}
+	}
+}
+
+type etagTransport struct {
+	tr  http.RoundTripper
+	dir string
+}
+
+// cachedResponse is a response stored by etagTransport
+type cachedResponse struct {
+	URL      string      `json:"url"`
+	ETag     string      `json:"etag"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.tr.RoundTrip(req)
+	}
+
+	path := t.path(req)
+	cached, found := t.read(path)
+	if found {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.tr.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && found:
+		resp.Body.Close()
+		log.Printf("[DEBUG] %s: not modified, using cached response", req.URL)
+		header := cached.Header.Clone()
+		// rate limit in the latest response
+		for key, values := range resp.Header {
+			header[key] = values
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "":
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		t.write(path, cachedResponse{
+			URL:      req.URL.String(),
+			ETag:     resp.Header.Get("ETag"),
+			Header:   resp.Header,
+			Body:     body,
+			StoredAt: time.Now(),
+		})
+	}
+	return resp, nil
+}
+
+// path returns where a response to req is cached. Responses are cached
+// per token since they may differ (e.g. private repositories)
+func (t *etagTransport) path(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String() + "\x00" + req.Header.Get("Authorization")))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (t *etagTransport) read(path string) (cachedResponse, bool) {
+	var cached cachedResponse
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cached, false
+	}
+	if err := json.Unmarshal(b, &cached); err != nil || cached.ETag == "" {
+		return cached, false
+	}
+	return cached, true
+}
+
+func (t *etagTransport) write(path string, cached cachedResponse) {
+	b, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		log.Printf("[ERROR] failed to cache response: %v", err)
+		return
+	}
+	// written to a temporary file first not to be read half-written by other requests
+	tmp, err := os.CreateTemp(t.dir, ".tmp-*")
+	if err != nil {
+		log.Printf("[ERROR] failed to cache response: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		log.Printf("[ERROR] failed to cache response: %v", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("[ERROR] failed to cache response: %v", err)
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a new context carrying options of clients which
+// are created by functions given the context (e.g. NewRelease)
+func NewContext(ctx context.Context, opts ...ClientOption) context.Context {
+	return context.WithValue(ctx, contextKey{}, opts)
+}
+
+// clientOptions returns options carried by ctx after base ones
+func clientOptions(ctx context.Context, base ...ClientOption) []ClientOption {
+	opts, _ := ctx.Value(contextKey{}).([]ClientOption)
+	return append(base, opts...)
+}
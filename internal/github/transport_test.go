@@ -0,0 +1,121 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRateLimitWait(t *testing.T) {
+	tests := map[string]struct {
+		retryAfter string
+		max        time.Duration
+		wantCalls  int32
+		wantErr    bool
+	}{
+		"reset within max": {
+			retryAfter: "1",
+			max:        time.Minute,
+			wantCalls:  2,
+		},
+		"reset after max": {
+			retryAfter: "3600",
+			max:        time.Minute,
+			wantCalls:  1,
+			wantErr:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				_, _ = w.Write([]byte(`{"tag_name":"v1.0.0"}`))
+			}))
+			defer server.Close()
+
+			client := NewClient(ReplaceTripper(server.Client().Transport), WithRateLimitWait(tt.max))
+			var data map[string]string
+			err := client.REST(http.MethodGet, server.URL+"/repos/owner/repo/releases/latest", nil, &data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("REST() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var limit *RateLimitError
+			if tt.wantErr && !errors.As(err, &limit) {
+				t.Errorf("REST() error = %v, want RateLimitError", err)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRateLimitWait_canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	client := NewClient(ReplaceTripper(server.Client().Transport), WithRateLimitWait(time.Minute))
+	err := client.RESTContext(ctx, http.MethodGet, server.URL+"/rate_limited", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RESTContext() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWithETagCache(t *testing.T) {
+	const etag = `"abc123"`
+	var calls, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(60-calls.Load())))
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(`{"tag_name":"v1.0.0"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := NewClient(ReplaceTripper(server.Client().Transport), WithETagCache(dir))
+
+	for i := range 2 {
+		var data map[string]string
+		if err := client.REST(http.MethodGet, server.URL+"/repos/owner/repo/releases/latest", nil, &data); err != nil {
+			t.Fatalf("REST() #%d error: %v", i, err)
+		}
+		if data["tag_name"] != "v1.0.0" {
+			t.Errorf("REST() #%d tag_name = %q, want v1.0.0", i, data["tag_name"])
+		}
+	}
+
+	if got := notModified.Load(); got != 1 {
+		t.Errorf("not modified responses = %d, want 1", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("cached responses = %d, want 1", len(entries))
+	}
+}
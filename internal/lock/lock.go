@@ -0,0 +1,232 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Lock represents afx.lock, which pins what was actually installed for each
+// package (commit SHA, release tag, asset and digest) so that running
+// `afx install` on another machine or another day gets the same binaries.
+type Lock struct {
+	Packages map[string]Package `json:"packages"`
+
+	path    string
+	locked  bool
+	changed bool
+	mu      sync.Mutex
+}
+
+// Package is a lock entry of a single package.
+// Only the fields meaningful for the package type are set.
+type Package struct {
+	Type   string `json:"type"`
+	Commit string `json:"commit,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Asset  *Asset `json:"asset,omitempty"`
+	URL    string `json:"url,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Asset represents a release asset resolved at installation.
+type Asset struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// DriftError is returned in locked mode when a package resolves to
+// something different from what is recorded in the lock file.
+type DriftError struct {
+	Name   string
+	Field  string
+	Locked string
+	Got    string
+}
+
+func (e *DriftError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: not found in lock file, run 'afx install' without --locked to record it", e.Name)
+	}
+	return fmt.Sprintf("%s: %s drifted from lock file (locked: %q, got: %q)",
+		e.Name, e.Field, e.Locked, e.Got)
+}
+
+// Open reads the lock file placed at the given path.
+// It returns an empty lock if the file does not exist yet.
+func Open(path string) (*Lock, error) {
+	l := &Lock{
+		Packages: map[string]Package{},
+		path:     path,
+	}
+
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return l, nil
+	case err != nil:
+		return l, err
+	}
+
+	if err := json.Unmarshal(content, l); err != nil {
+		return l, fmt.Errorf("%s: failed to parse lock file: %w", path, err)
+	}
+	if l.Packages == nil {
+		l.Packages = map[string]Package{}
+	}
+	return l, nil
+}
+
+// SetLocked switches the lock into locked mode. In locked mode, Record
+// never updates the lock file but returns DriftError instead.
+func (l *Lock) SetLocked(locked bool) {
+	if l == nil {
+		return
+	}
+	l.locked = locked
+}
+
+// Locked returns true if the lock is in locked mode.
+func (l *Lock) Locked() bool {
+	if l == nil {
+		return false
+	}
+	return l.locked
+}
+
+// Get returns the lock entry of the given package.
+func (l *Lock) Get(name string) (Package, bool) {
+	if l == nil {
+		return Package{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pkg, ok := l.Packages[name]
+	return pkg, ok
+}
+
+// Record records what the given package was resolved to.
+// In locked mode, it verifies them against the lock file instead.
+func (l *Lock) Record(name string, got Package) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locked {
+		locked, ok := l.Packages[name]
+		if !ok {
+			return &DriftError{Name: name}
+		}
+		return verify(name, locked, got)
+	}
+
+	log.Printf("[DEBUG] %s: recorded to lock file: %#v", name, got)
+	l.Packages[name] = got
+	l.changed = true
+	return nil
+}
+
+// Seed records the entry of a package which is not in the lock file yet, e.g.
+// installed before the lock file was introduced. It's recorded even in locked mode
+func (l *Lock) Seed(name string, pkg Package) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.Packages[name]; ok {
+		return
+	}
+	log.Printf("[DEBUG] %s: seeded to lock file: %#v", name, pkg)
+	l.Packages[name] = pkg
+	l.changed = true
+}
+
+// Delete deletes the lock entry of the given package.
+func (l *Lock) Delete(name string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.Packages[name]; !ok {
+		return
+	}
+	delete(l.Packages, name)
+	l.changed = true
+}
+
+// Save writes the lock file if some entries were changed.
+func (l *Lock) Save() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.changed {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.path, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	l.changed = false
+	return nil
+}
+
+func verify(name string, locked, got Package) error {
+	check := func(field, want, have string) error {
+		if want == "" || want == have {
+			return nil
+		}
+		return &DriftError{Name: name, Field: field, Locked: want, Got: have}
+	}
+
+	var gotAsset Asset
+	if got.Asset != nil {
+		gotAsset = *got.Asset
+	}
+	var lockedAsset Asset
+	if locked.Asset != nil {
+		lockedAsset = *locked.Asset
+	}
+
+	return errors.Join(
+		check("commit", locked.Commit, got.Commit),
+		check("tag", locked.Tag, got.Tag),
+		check("asset name", lockedAsset.Name, gotAsset.Name),
+		check("asset sha256", lockedAsset.SHA256, gotAsset.SHA256),
+		check("url", locked.URL, got.URL),
+		check("sha256", locked.SHA256, got.SHA256),
+	)
+}
+
+type contextKey struct{}
+
+// NewContext returns a new context carrying the given lock.
+func NewContext(ctx context.Context, l *Lock) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the lock stored in ctx, or nil if there is none.
+// All methods of Lock can be safely called with nil.
+func FromContext(ctx context.Context) *Lock {
+	l, _ := ctx.Value(contextKey{}).(*Lock)
+	return l
+}
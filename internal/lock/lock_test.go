@@ -0,0 +1,185 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+func TestOpen_notExist(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "afx.lock"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if len(l.Packages) != 0 {
+		t.Errorf("Open() packages = %d, want 0", len(l.Packages))
+	}
+}
+
+func TestRecord_Save_roundtrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "afx.lock")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs := map[string]Package{
+		"babarot/enhancd": {Type: "GitHub", Commit: "0123456789abcdef"},
+		"junegunn/fzf": {
+			Type: "GitHub Release",
+			Tag:  "v0.40.0",
+			Asset: &Asset{
+				Name:   "fzf-0.40.0-linux_amd64.tar.gz",
+				URL:    "https://example.com/fzf.tar.gz",
+				SHA256: "abcdef",
+			},
+		},
+		"diff-so-fancy": {Type: "HTTP", URL: "https://example.com/d", SHA256: "012345"},
+	}
+	for name, pkg := range pkgs {
+		if err := l.Record(name, pkg); err != nil {
+			t.Fatalf("Record() error: %v", err)
+		}
+	}
+	if err := l.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	got, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(pkgs, got.Packages); diff != "" {
+		t.Errorf("Open() packages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRecord_locked(t *testing.T) {
+	l := &Lock{
+		Packages: map[string]Package{
+			"commit": {Type: "GitHub", Commit: "aaa"},
+			"release": {
+				Type:  "GitHub Release",
+				Tag:   "v1.0.0",
+				Asset: &Asset{Name: "tool.tar.gz", SHA256: "111"},
+			},
+		},
+	}
+	l.SetLocked(true)
+
+	tests := map[string]struct {
+		name  string
+		got   Package
+		field string
+		drift bool
+	}{
+		"same commit": {
+			name: "commit",
+			got:  Package{Type: "GitHub", Commit: "aaa"},
+		},
+		"different commit": {
+			name:  "commit",
+			got:   Package{Type: "GitHub", Commit: "bbb"},
+			field: "commit",
+			drift: true,
+		},
+		"different digest": {
+			name:  "release",
+			got:   Package{Tag: "v1.0.0", Asset: &Asset{Name: "tool.tar.gz", SHA256: "222"}},
+			field: "asset sha256",
+			drift: true,
+		},
+		"different tag": {
+			name:  "release",
+			got:   Package{Tag: "v1.1.0", Asset: &Asset{Name: "tool.tar.gz", SHA256: "111"}},
+			field: "tag",
+			drift: true,
+		},
+		"not in lock": {
+			name:  "unknown",
+			got:   Package{Commit: "aaa"},
+			drift: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := l.Record(tt.name, tt.got)
+			var drift *DriftError
+			if got := errors.As(err, &drift); got != tt.drift {
+				t.Fatalf("Record() error = %v, want drift %v", err, tt.drift)
+			}
+			if tt.drift && drift.Field != tt.field {
+				t.Errorf("DriftError.Field = %q, want %q", drift.Field, tt.field)
+			}
+		})
+	}
+
+	if l.changed {
+		t.Error("Record() should not change lock in locked mode")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	l := &Lock{Packages: map[string]Package{"a": {Type: "GitHub"}}}
+	l.Delete("a")
+	if _, ok := l.Get("a"); ok {
+		t.Error("Delete() did not delete the entry")
+	}
+	if !l.changed {
+		t.Error("Delete() should mark lock as changed")
+	}
+}
+
+func TestSeed(t *testing.T) {
+	l := &Lock{Packages: map[string]Package{"a": {Type: "GitHub", Commit: "aaa"}}}
+	l.SetLocked(true)
+	l.Seed("a", Package{Type: "GitHub", Commit: "bbb"})
+	l.Seed("b", Package{Type: "GitHub Release", Tag: "v1.0.0"})
+
+	want := map[string]Package{
+		"a": {Type: "GitHub", Commit: "aaa"},
+		"b": {Type: "GitHub Release", Tag: "v1.0.0"},
+	}
+	if diff := cmp.Diff(want, l.Packages); diff != "" {
+		t.Errorf("Seed() mismatch (-want +got):\n%s", diff)
+	}
+	if err := l.Record("b", Package{Type: "GitHub Release", Tag: "v1.0.0"}); err != nil {
+		t.Errorf("Record() of seeded package error: %v", err)
+	}
+}
+
+func TestNil(t *testing.T) {
+	var l *Lock
+	if l.Locked() {
+		t.Error("nil Lock should not be locked")
+	}
+	if _, ok := l.Get("a"); ok {
+		t.Error("nil Lock should not have entries")
+	}
+	if err := l.Record("a", Package{}); err != nil {
+		t.Errorf("nil Lock Record() error: %v", err)
+	}
+	if err := l.Save(); err != nil {
+		t.Errorf("nil Lock Save() error: %v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext() should return nil without lock")
+	}
+	l := &Lock{}
+	if FromContext(NewContext(context.Background(), l)) != l {
+		t.Error("FromContext() did not return the stored lock")
+	}
+}
@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/babarot/afx/internal/checksum"
+)
+
+// Checksum represents the expected SHA-256 checksum of a downloaded file.
+// It can be written as an inline digest:
+//
+//	checksum: sha256:0123abcd...
+//
+// or as a checksum file published along with it:
+//
+//	checksum:
+//	  from-asset: checksums.txt
+type Checksum struct {
+	Value     string `yaml:"value,omitempty"`
+	FromAsset string `yaml:"from-asset,omitempty"`
+}
+
+func (c *Checksum) UnmarshalYAML(b []byte) error {
+	var value string
+	if err := yaml.Unmarshal(b, &value); err == nil {
+		c.Value = value
+		return c.validate()
+	}
+
+	type alias Checksum
+	var tmp alias
+	if err := yaml.Unmarshal(b, &tmp); err != nil {
+		return fmt.Errorf("failed to unmarshal checksum: %w", err)
+	}
+	*c = Checksum(tmp)
+	return c.validate()
+}
+
+func (c Checksum) MarshalYAML() (any, error) {
+	if c.FromAsset == "" {
+		return c.Value, nil
+	}
+	type alias Checksum
+	return alias(c), nil
+}
+
+func (c Checksum) validate() error {
+	switch {
+	case c.Value == "" && c.FromAsset == "":
+		return errors.New("checksum: either digest or from-asset is required")
+	case c.Value != "" && c.FromAsset != "":
+		return errors.New("checksum: digest and from-asset cannot be used together")
+	case c.Value != "":
+		_, err := checksum.Normalize(c.Value)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChecksum_UnmarshalYAML(t *testing.T) {
+	const digest = "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+
+	tests := map[string]struct {
+		yaml    string
+		want    *Checksum
+		wantErr bool
+	}{
+		"inline digest": {
+			yaml: `checksum: sha256:` + digest,
+			want: &Checksum{Value: "sha256:" + digest},
+		},
+		"from asset": {
+			yaml: "checksum:\n          from-asset: checksums.txt",
+			want: &Checksum{FromAsset: "checksums.txt"},
+		},
+		"invalid digest": {
+			yaml:    `checksum: sha256:abcd`,
+			wantErr: true,
+		},
+		"empty": {
+			yaml:    "checksum:\n          from-asset: \"\"",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := `http:
+  - name: test-pkg
+    url: https://example.com/tool.tar.gz
+    ` + tt.yaml + `
+`
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Read(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, cfg.HTTP[0].Checksum); diff != "" {
+				t.Errorf("Checksum mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -63,6 +63,19 @@ func (l *Link) UnmarshalYAML(b []byte) error {
 	return nil
 }
 
+// Dest returns a path of the symlink to be created.
+// It's placed in AFX_COMMAND_PATH unless "to" is an absolute path
+func (l Link) Dest() string {
+	dest := l.To
+	if l.To == "" {
+		dest = filepath.Base(l.From)
+	}
+	if !filepath.IsAbs(l.To) {
+		dest = filepath.Join(os.Getenv("AFX_COMMAND_PATH"), dest)
+	}
+	return dest
+}
+
 func (c Command) GetLink(pkg Package) ([]Link, error) {
 	var links []Link
 
@@ -73,22 +86,11 @@ func (c Command) GetLink(pkg Package) ([]Link, error) {
 		)
 	}
 
-	getTo := func(link *Link) string {
-		dest := link.To
-		if link.To == "" {
-			dest = filepath.Base(link.From)
-		}
-		if !filepath.IsAbs(link.To) {
-			dest = filepath.Join(os.Getenv("AFX_COMMAND_PATH"), dest)
-		}
-		return dest
-	}
-
 	for _, link := range c.Link {
 		if link.From == "." {
 			links = append(links, Link{
 				From: pkg.GetHome(),
-				To:   getTo(link),
+				To:   link.Dest(),
 			})
 			continue
 		}
@@ -116,7 +118,7 @@ func (c Command) GetLink(pkg Package) ([]Link, error) {
 		}
 		links = append(links, Link{
 			From: src,
-			To:   getTo(link),
+			To:   link.Dest(),
 		})
 	}
 
@@ -4,20 +4,32 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/goccy/go-yaml"
 
+	"github.com/babarot/afx/internal/cache"
 	"github.com/babarot/afx/internal/dependency"
+	"github.com/babarot/afx/internal/env"
+	"github.com/babarot/afx/internal/generation"
+	"github.com/babarot/afx/internal/github"
+	pathutil "github.com/babarot/afx/internal/helpers/path"
+	"github.com/babarot/afx/internal/state"
 )
 
 // Config represents a parsed YAML configuration file containing package definitions.
 type Config struct {
 	GitHub []*GitHub `yaml:"github,omitempty"`
+	GitLab []*GitLab `yaml:"gitlab,omitempty"`
+	Gitea  []*Gitea  `yaml:"gitea,omitempty"`
+	Git    []*Git    `yaml:"git,omitempty"`
 	Gist   []*Gist   `yaml:"gist,omitempty"`
 	Local  []*Local  `yaml:"local,omitempty"`
 	HTTP   []*HTTP   `yaml:"http,omitempty"`
@@ -30,6 +42,135 @@ type Main struct {
 	Shell     string            `yaml:"shell"`
 	FilterCmd string            `yaml:"filter_command"`
 	Env       map[string]string `yaml:"env"`
+
+	// GitHubHost is a default host of GitHub packages,
+	// e.g. a host of GitHub Enterprise Server
+	GitHubHost string `yaml:"github_host"`
+
+	// CacheSize is a size limit of the download cache (e.g. 500MB, 2GB)
+	CacheSize string `yaml:"cache_size"`
+
+	// Generations is the number of previous installations kept for each
+	// package to roll back to. Zero disables keeping them
+	Generations *int `yaml:"generations" validate:"omitempty,min=0"`
+
+	// LockTimeout is how long to wait for the state lock held by
+	// another afx process (e.g. 1m). Zero fails immediately
+	LockTimeout string `yaml:"lock_timeout"`
+
+	// TokenCommand prints GitHub token (e.g. "gh auth token").
+	// It's run instead of prompting when GITHUB_TOKEN is not set
+	TokenCommand string `yaml:"token_command"`
+
+	// RateLimitWait is how long to wait for the rate limit of GitHub API
+	// to be reset if it's exceeded (e.g. 5m). Zero fails immediately
+	RateLimitWait string `yaml:"rate_limit_wait"`
+
+	// Concurrency is the number of packages run at the same time.
+	// runner.DefaultConcurrency is used if zero
+	Concurrency int `yaml:"concurrency" validate:"min=0"`
+
+	// Secrets configures where secrets (e.g. GITHUB_TOKEN) are stored.
+	// They are kept only in memory by default
+	Secrets *Secrets `yaml:"secrets"`
+}
+
+// Secrets represents a secret store backend
+type Secrets struct {
+	Backend string `yaml:"backend" validate:"omitempty,oneof=memory secret-service pass age"`
+
+	// Prefix is a directory in the password store of pass (default: afx)
+	Prefix string `yaml:"prefix"`
+
+	// Recipient is a public key of age to encrypt secrets with
+	Recipient string `yaml:"recipient"`
+	// Identity is a path to a private key file of age to decrypt secrets with
+	Identity string `yaml:"identity"`
+}
+
+// KeepGenerations returns the number of generations kept for each package
+func (m Main) KeepGenerations() int {
+	if m.Generations == nil {
+		return generation.DefaultKeep
+	}
+	return *m.Generations
+}
+
+// CacheMaxSize returns a size limit of the download cache
+func (m Main) CacheMaxSize() (int64, error) {
+	if m.CacheSize == "" {
+		return cache.DefaultMaxSize, nil
+	}
+	size, err := cache.ParseSize(m.CacheSize)
+	if err != nil {
+		return 0, fmt.Errorf("cache_size: %w", err)
+	}
+	return size, nil
+}
+
+// StateLockTimeout returns how long to wait for the state lock
+func (m Main) StateLockTimeout() (time.Duration, error) {
+	if m.LockTimeout == "" {
+		return state.DefaultLockTimeout, nil
+	}
+	d, err := time.ParseDuration(m.LockTimeout)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("lock_timeout: invalid duration: %q", m.LockTimeout)
+	}
+	return d, nil
+}
+
+// GitHubRateLimitWait returns how long to wait for the rate limit of GitHub API
+func (m Main) GitHubRateLimitWait() (time.Duration, error) {
+	if m.RateLimitWait == "" {
+		return github.DefaultRateLimitWait, nil
+	}
+	d, err := time.ParseDuration(m.RateLimitWait)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("rate_limit_wait: invalid duration: %q", m.RateLimitWait)
+	}
+	return d, nil
+}
+
+// SecretStore returns a store of secrets configured by secrets.backend.
+// A persistent store available on the system is used if not configured
+func (m Main) SecretStore() (env.SecretStore, error) {
+	if m.Secrets == nil || m.Secrets.Backend == "" {
+		store := env.DetectSecretStore()
+		if !store.Persistent() {
+			log.Printf("[WARN] secrets: no persistent secret store is found, secrets are kept only in memory")
+		}
+		return store, nil
+	}
+	switch m.Secrets.Backend {
+	case env.BackendMemory:
+		return env.NewMemoryStore(), nil
+	case env.BackendSecretService:
+		return env.NewSecretServiceStore(), nil
+	case env.BackendPass:
+		return env.NewPassStore(m.Secrets.Prefix), nil
+	case env.BackendAge:
+		store, err := env.NewAgeStore(
+			filepath.Join(DataDir(), "secrets.age"),
+			m.Secrets.Recipient,
+			pathutil.ExpandTilda(m.Secrets.Identity),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: %w", err)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("secrets: unknown backend: %q", m.Secrets.Backend)
+	}
+}
+
+// SetDefaults fills package fields which are not specified with the defaults in Main
+func (m Main) SetDefaults(pkgs []Package) {
+	for _, pkg := range pkgs {
+		if g, ok := pkg.(*GitHub); ok && g.Host == "" {
+			g.Host = m.GitHubHost
+		}
+	}
 }
 
 // DefaultMain is default settings of Main
@@ -44,20 +185,28 @@ var DefaultMain Main = Main{
 func Read(path string) (Config, error) {
 	log.Printf("[INFO] Reading config %s...", path)
 
-	var cfg Config
-
 	f, err := os.Open(path)
 	if err != nil {
-		return cfg, err
+		return Config{}, err
 	}
 	defer f.Close()
 
+	return Decode(f)
+}
+
+// Decode decodes and validates yaml config read from r
+func Decode(r io.Reader) (Config, error) {
+	var cfg Config
+
 	validate := validator.New()
 	if err := validate.RegisterValidation("startswith-gh-if-not-empty", ValidateGHExtension); err != nil {
 		return cfg, err
 	}
+	if err := validate.RegisterValidation("regexp", ValidateRegexp); err != nil {
+		return cfg, err
+	}
 	d := yaml.NewDecoder(
-		bufio.NewReader(f),
+		bufio.NewReader(r),
 		yaml.DisallowUnknownField(),
 		yaml.DisallowDuplicateKey(),
 		yaml.Validator(validate),
@@ -66,7 +215,7 @@ func Read(path string) (Config, error) {
 		return cfg, err
 	}
 
-	return cfg, err
+	return cfg, nil
 }
 
 func parse(cfg Config) []Package {
@@ -75,6 +224,15 @@ func parse(cfg Config) []Package {
 	for _, pkg := range cfg.GitHub {
 		pkgs = append(pkgs, pkg)
 	}
+	for _, pkg := range cfg.GitLab {
+		pkgs = append(pkgs, pkg)
+	}
+	for _, pkg := range cfg.Gitea {
+		pkgs = append(pkgs, pkg)
+	}
+	for _, pkg := range cfg.Git {
+		pkgs = append(pkgs, pkg)
+	}
 	for _, pkg := range cfg.Gist {
 		pkgs = append(pkgs, pkg)
 	}
@@ -228,6 +386,12 @@ func Validate(pkgs []Package) error {
 	return nil
 }
 
+// ValidateRegexp validates a field is a valid regular expression
+func ValidateRegexp(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
+
 func (c Config) Get(args ...string) Config {
 	var part Config
 	for _, arg := range args {
@@ -236,6 +400,21 @@ func (c Config) Get(args ...string) Config {
 				part.GitHub = append(part.GitHub, github)
 			}
 		}
+		for _, gitlab := range c.GitLab {
+			if gitlab.Name == arg {
+				part.GitLab = append(part.GitLab, gitlab)
+			}
+		}
+		for _, gitea := range c.Gitea {
+			if gitea.Name == arg {
+				part.Gitea = append(part.Gitea, gitea)
+			}
+		}
+		for _, git := range c.Git {
+			if git.Name == arg {
+				part.Git = append(part.Git, git)
+			}
+		}
 		for _, gist := range c.Gist {
 			if gist.Name == arg {
 				part.Gist = append(part.Gist, gist)
@@ -263,6 +442,21 @@ func (c Config) Contains(args ...string) Config {
 				part.GitHub = append(part.GitHub, github)
 			}
 		}
+		for _, gitlab := range c.GitLab {
+			if strings.Contains(gitlab.Name, arg) {
+				part.GitLab = append(part.GitLab, gitlab)
+			}
+		}
+		for _, gitea := range c.Gitea {
+			if strings.Contains(gitea.Name, arg) {
+				part.Gitea = append(part.Gitea, gitea)
+			}
+		}
+		for _, git := range c.Git {
+			if strings.Contains(git.Name, arg) {
+				part.Git = append(part.Git, git)
+			}
+		}
 		for _, gist := range c.Gist {
 			if strings.Contains(gist.Name, arg) {
 				part.Gist = append(part.Gist, gist)
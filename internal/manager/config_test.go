@@ -8,6 +8,10 @@ import (
 	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/runner"
 )
 
 func init() {
@@ -291,15 +295,19 @@ func TestSort_order(t *testing.T) {
 func TestConfig_Get_allTypes(t *testing.T) {
 	cfg := Config{
 		GitHub: []*GitHub{{Name: "x", Owner: "o", Repo: "r"}},
+		GitLab: []*GitLab{{Name: "x", Owner: "o", Repo: "r"}},
+		Gitea:  []*Gitea{{Name: "x", Host: "codeberg.org", Owner: "o", Repo: "r"}},
+		Git:    []*Git{{Name: "x", URL: "https://git.example.com/o/r.git"}},
 		Gist:   []*Gist{{Name: "x", Owner: "o", ID: "id"}},
 		Local:  []*Local{{Name: "x", Directory: "/tmp"}},
 		HTTP:   []*HTTP{{Name: "x", URL: "https://example.com"}},
 	}
 
 	got := cfg.Get("x")
-	total := len(got.GitHub) + len(got.Gist) + len(got.Local) + len(got.HTTP)
-	if total != 4 {
-		t.Errorf("Get('x') returned %d, want 4 (one per type)", total)
+	total := len(got.GitHub) + len(got.GitLab) + len(got.Gitea) + len(got.Git) +
+		len(got.Gist) + len(got.Local) + len(got.HTTP)
+	if total != 7 {
+		t.Errorf("Get('x') returned %d, want 7 (one per type)", total)
 	}
 }
 
@@ -334,6 +342,14 @@ func TestHasGitHubReleaseBlock(t *testing.T) {
 			pkgs: []Package{&Local{Name: "a", Directory: "/tmp"}},
 			want: false,
 		},
+		"github enterprise release": {
+			pkgs: []Package{&GitHub{Name: "a", Host: "ghe.example.com", Owner: "o", Repo: "r", Release: &GitHubRelease{}}},
+			want: false,
+		},
+		"gitlab release": {
+			pkgs: []Package{&GitLab{Name: "a", Owner: "o", Repo: "r", Release: &GitHubRelease{}}},
+			want: false,
+		},
 		"empty": {
 			pkgs: []Package{},
 			want: false,
@@ -418,3 +434,185 @@ func TestCreateDirIfNotExist(t *testing.T) {
 
 // Verify GitHubRelease type exists (needed for HasGitHubReleaseBlock)
 var _ = &GitHubRelease{}
+
+func TestMain_SetDefaults(t *testing.T) {
+	tests := map[string]struct {
+		main Main
+		pkg  *GitHub
+		want string
+	}{
+		"default host": {
+			main: Main{GitHubHost: "ghe.example.com"},
+			pkg:  &GitHub{Name: "a", Owner: "o", Repo: "r"},
+			want: "ghe.example.com",
+		},
+		"host in package is preferred": {
+			main: Main{GitHubHost: "ghe.example.com"},
+			pkg:  &GitHub{Name: "a", Host: "github.com", Owner: "o", Repo: "r"},
+			want: "github.com",
+		},
+		"no default": {
+			main: Main{},
+			pkg:  &GitHub{Name: "a", Owner: "o", Repo: "r"},
+			want: "",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.main.SetDefaults([]Package{tt.pkg, &Local{Name: "b", Directory: "/tmp"}})
+			if tt.pkg.Host != tt.want {
+				t.Errorf("Host = %q, want %q", tt.pkg.Host, tt.want)
+			}
+		})
+	}
+}
+
+func TestMain_GitHubRateLimitWait(t *testing.T) {
+	tests := map[string]struct {
+		wait    string
+		want    time.Duration
+		wantErr bool
+	}{
+		"default": {
+			want: github.DefaultRateLimitWait,
+		},
+		"duration": {
+			wait: "5m",
+			want: 5 * time.Minute,
+		},
+		"fail immediately": {
+			wait: "0s",
+			want: 0,
+		},
+		"invalid": {
+			wait:    "soon",
+			wantErr: true,
+		},
+		"negative": {
+			wait:    "-1m",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Main{RateLimitWait: tt.wait}.GitHubRateLimitWait()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GitHubRateLimitWait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GitHubRateLimitWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMain_SecretStore(t *testing.T) {
+	// no persistent stores are found by default
+	t.Setenv("PATH", t.TempDir())
+	tests := map[string]struct {
+		secrets    *Secrets
+		persistent bool
+		wantErr    bool
+	}{
+		"default": {
+			secrets:    nil,
+			persistent: false,
+		},
+		"memory": {
+			secrets:    &Secrets{Backend: "memory"},
+			persistent: false,
+		},
+		"pass": {
+			secrets:    &Secrets{Backend: "pass"},
+			persistent: true,
+		},
+		"age": {
+			secrets:    &Secrets{Backend: "age", Recipient: "age1xxx", Identity: "~/.config/age/key.txt"},
+			persistent: true,
+		},
+		"age without identity": {
+			secrets: &Secrets{Backend: "age", Recipient: "age1xxx"},
+			wantErr: true,
+		},
+		"unknown": {
+			secrets: &Secrets{Backend: "keychain"},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store, err := Main{Secrets: tt.secrets}.SecretStore()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SecretStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := store.Persistent(); got != tt.persistent {
+				t.Errorf("Persistent() = %v, want %v", got, tt.persistent)
+			}
+		})
+	}
+}
+
+func TestDecode_timeoutAndRetry(t *testing.T) {
+	tests := map[string]struct {
+		yaml        string
+		wantTimeout time.Duration
+		wantRetry   runner.Retry
+		wantErr     bool
+	}{
+		"timeout and retry": {
+			yaml: `
+http:
+  - name: tool
+    url: https://example.com/tool.tar.gz
+    timeout: 5m
+    retry:
+      attempts: 3
+      backoff: 2s
+`,
+			wantTimeout: 5 * time.Minute,
+			wantRetry:   runner.Retry{Attempts: 3, Backoff: 2 * time.Second},
+		},
+		"not specified": {
+			yaml: `
+http:
+  - name: tool
+    url: https://example.com/tool.tar.gz
+`,
+		},
+		"zero attempts": {
+			yaml: `
+http:
+  - name: tool
+    url: https://example.com/tool.tar.gz
+    retry:
+      attempts: 0
+`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Decode(strings.NewReader(tt.yaml))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			pkg := cfg.HTTP[0]
+			if got := pkg.GetTimeout(); got != tt.wantTimeout {
+				t.Errorf("GetTimeout() = %v, want %v", got, tt.wantTimeout)
+			}
+			if got := pkg.GetRetry(); got != tt.wantRetry {
+				t.Errorf("GetRetry() = %+v, want %+v", got, tt.wantRetry)
+			}
+		})
+	}
+}
@@ -28,11 +28,15 @@ func (ext GHExtension) GetTag() string {
 	return "latest"
 }
 
+// GHExtensionDir returns the directory where gh CLI installs extensions.
+func GHExtensionDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".local", "share", "gh", "extensions")
+}
+
 // GetHome returns the extension directory based on the canonical name (not rename-to).
 // This path is managed by gh CLI.
 func (ext GHExtension) GetHome() string {
-	base := filepath.Join(os.Getenv("HOME"), ".local", "share", "gh", "extensions")
-	return filepath.Join(base, ext.Name)
+	return filepath.Join(GHExtensionDir(), ext.Name)
 }
 
 // GetAliasHome returns the symlink path when rename-to is specified.
@@ -41,8 +45,7 @@ func (ext GHExtension) GetAliasHome() string {
 	if ext.RenameTo == "" {
 		return ""
 	}
-	base := filepath.Join(os.Getenv("HOME"), ".local", "share", "gh", "extensions")
-	return filepath.Join(base, ext.RenameTo)
+	return filepath.Join(GHExtensionDir(), ext.RenameTo)
 }
 
 // Install installs the gh extension via the gh CLI.
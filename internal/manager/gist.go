@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/babarot/afx/internal/git"
 	"github.com/babarot/afx/internal/runner"
@@ -25,6 +26,10 @@ type Gist struct {
 	Command *Command `yaml:"command"`
 
 	DependsOn []string `yaml:"depends-on"`
+
+	// Timeout cancels installation taking longer than it (e.g. 5m)
+	Timeout time.Duration `yaml:"timeout" validate:"min=0"`
+	Retry   *Retry        `yaml:"retry"`
 }
 
 // Init is
@@ -43,6 +48,11 @@ func (c Gist) Init() error {
 	return errors.Join(errs...)
 }
 
+// CloneURL returns a URL of the gist repository
+func (c Gist) CloneURL() string {
+	return fmt.Sprintf("https://gist.github.com/%s/%s", c.Owner, c.ID)
+}
+
 // Install is
 func (c Gist) Install(ctx context.Context, status chan<- runner.Status) error {
 	ctx, cancel := context.WithCancel(ctx)
@@ -63,8 +73,7 @@ func (c Gist) Install(ctx context.Context, status chan<- runner.Status) error {
 	}
 
 	gitCmd := git.NewRunner()
-	url := fmt.Sprintf("https://gist.github.com/%s/%s", c.Owner, c.ID)
-	if _, err := gitCmd.Run(ctx, "clone", "--no-tags", url, c.GetHome()); err != nil {
+	if err := gitClone(ctx, gitCmd, c.CloneURL(), c.GetHome(), "clone", "--no-tags"); err != nil {
 		status <- runner.Status{Name: c.GetName(), Done: true, Err: true}
 		if git.IsAuthError(err) {
 			return fmt.Errorf("%s: authentication failed. Please set GITHUB_TOKEN or configure git credentials: %w", c.Name, err)
@@ -72,6 +81,11 @@ func (c Gist) Install(ctx context.Context, status chan<- runner.Status) error {
 		return fmt.Errorf("%s: failed to clone gist repo: %w", c.Name, err)
 	}
 
+	if err := lockCommit(ctx, gitCmd, c.GetName(), "Gist", c.GetHome()); err != nil {
+		status <- runner.Status{Name: c.GetName(), Done: true, Err: true}
+		return err
+	}
+
 	var errs []error
 	if c.HasPluginBlock() {
 		if err := c.Plugin.Install(c); err != nil {
@@ -181,6 +195,16 @@ func (c Gist) GetDependsOn() []string {
 	return c.DependsOn
 }
 
+// GetTimeout returns how long installation can take
+func (c Gist) GetTimeout() time.Duration {
+	return c.Timeout
+}
+
+// GetRetry returns a policy to retry installation
+func (c Gist) GetRetry() runner.Retry {
+	return c.Retry.policy()
+}
+
 func (c Gist) GetResource() state.Resource {
 	return getResource(c)
 }
@@ -0,0 +1,215 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/babarot/afx/internal/runner"
+	"github.com/babarot/afx/internal/state"
+)
+
+// Git represents a repository hosted on any git server.
+// Releases are not supported since there is no common API for them.
+type Git struct {
+	Name string `yaml:"name" validate:"required"`
+
+	// URL is a clone URL (e.g. https://git.example.com/owner/repo.git, git@git.example.com:owner/repo.git)
+	URL         string        `yaml:"url" validate:"required"`
+	Description string        `yaml:"description"`
+	Branch      string        `yaml:"branch"`
+	Option      *GitHubOption `yaml:"with"`
+
+	Plugin  *Plugin  `yaml:"plugin"`
+	Command *Command `yaml:"command"`
+
+	DependsOn []string `yaml:"depends-on"`
+
+	// Timeout cancels installation taking longer than it (e.g. 5m)
+	Timeout time.Duration `yaml:"timeout" validate:"min=0"`
+	Retry   *Retry        `yaml:"retry"`
+}
+
+// Init runs initialization step related to git packages
+func (c Git) Init() error {
+	var errs []error
+	if c.HasPluginBlock() {
+		if err := c.Plugin.Init(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.HasCommandBlock() {
+		if err := c.Command.Init(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloneURL returns a URL of the repository
+func (c Git) CloneURL() string {
+	return c.URL
+}
+
+// Clone runs git clone or fetches+checkouts if already cloned.
+func (c Git) Clone(ctx context.Context) error {
+	var opt GitHubOption
+	if c.Option != nil {
+		opt = *c.Option
+	}
+	return repository{
+		name:   c.GetName(),
+		ty:     "Git",
+		url:    c.URL,
+		home:   c.GetHome(),
+		branch: c.Branch,
+		depth:  opt.Depth,
+	}.clone(ctx)
+}
+
+// Install installs from git repository with git clone command
+func (c Git) Install(ctx context.Context, status chan<- runner.Status) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		log.Println("[DEBUG] canceled")
+		return nil
+	default:
+		// Go installing step!
+	}
+
+	if err := c.Clone(ctx); err != nil {
+		err = fmt.Errorf("%s: failed to clone repo: %w", c.Name, err)
+		status <- runner.Status{Name: c.GetName(), Done: true, Err: true}
+		return err
+	}
+
+	var errs []error
+	if c.HasPluginBlock() {
+		if err := c.Plugin.Install(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.HasCommandBlock() {
+		if err := c.Command.Install(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	status <- runner.Status{Name: c.GetName(), Done: true, Err: errors.Join(errs...) != nil}
+	return errors.Join(errs...)
+}
+
+// Installed returns true the git package is already installed
+func (c Git) Installed() bool {
+	var list []bool
+
+	if c.HasPluginBlock() {
+		list = append(list, c.Plugin.Installed(c))
+	}
+
+	if c.HasCommandBlock() {
+		list = append(list, c.Command.Installed(c))
+	}
+
+	if !c.HasPluginBlock() && !c.HasCommandBlock() {
+		_, err := os.Stat(c.GetHome())
+		list = append(list, err == nil)
+	}
+
+	return allTrue(list)
+}
+
+func (c Git) Uninstall(ctx context.Context) error {
+	var errs []error
+
+	del := func(f string) {
+		err := os.RemoveAll(f)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		log.Printf("[INFO] Delete %s\n", f)
+	}
+
+	if c.HasCommandBlock() {
+		links, _ := c.Command.GetLink(c)
+		for _, link := range links {
+			del(link.From)
+			del(link.To)
+		}
+	}
+
+	del(c.GetHome())
+	return errors.Join(errs...)
+}
+
+func (c Git) Check(ctx context.Context, status chan<- runner.Status) error {
+	status <- runner.Status{Name: c.GetName(), Done: true, Err: false, Message: "(git)", NoColor: true}
+	return nil
+}
+
+func (c Git) HasPluginBlock() bool {
+	return c.Plugin != nil
+}
+
+func (c Git) HasCommandBlock() bool {
+	return c.Command != nil
+}
+
+func (c Git) HasReleaseBlock() bool {
+	return false
+}
+
+func (c Git) GetPluginBlock() Plugin {
+	if c.HasPluginBlock() {
+		return *c.Plugin
+	}
+	return Plugin{}
+}
+
+func (c Git) GetCommandBlock() Command {
+	if c.HasCommandBlock() {
+		return *c.Command
+	}
+	return Command{}
+}
+
+// GetName returns a name
+func (c Git) GetName() string {
+	return c.Name
+}
+
+// GetHome returns a path
+func (c Git) GetHome() string {
+	host, path, err := parseCloneURL(c.URL)
+	if err != nil {
+		log.Printf("[ERROR] %s: %v", c.Name, err)
+		return filepath.Join(DataDir(), "git", c.Name)
+	}
+	return filepath.Join(DataDir(), host, filepath.FromSlash(path))
+}
+
+func (c Git) GetDependsOn() []string {
+	return c.DependsOn
+}
+
+// GetTimeout returns how long installation can take
+func (c Git) GetTimeout() time.Duration {
+	return c.Timeout
+}
+
+// GetRetry returns a policy to retry installation
+func (c Git) GetRetry() runner.Retry {
+	return c.Retry.policy()
+}
+
+func (c Git) GetResource() state.Resource {
+	return getResource(c)
+}
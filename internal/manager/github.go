@@ -4,14 +4,19 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/babarot/afx/internal/gh"
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/runner"
 )
 
 // GitHub represents GitHub repository
 type GitHub struct {
 	Name string `yaml:"name" validate:"required"`
 
+	// Host is a host of GitHub Enterprise Server (default: github.com)
+	Host        string `yaml:"host"`
 	Owner       string `yaml:"owner"       validate:"required"`
 	Repo        string `yaml:"repo"        validate:"required"`
 	Description string `yaml:"description"`
@@ -27,6 +32,10 @@ type GitHub struct {
 
 	DependsOn []string `yaml:"depends-on"`
 
+	// Timeout cancels installation taking longer than it (e.g. 5m)
+	Timeout time.Duration `yaml:"timeout" validate:"min=0"`
+	Retry   *Retry        `yaml:"retry"`
+
 	GHRunner gh.Runner `yaml:"-"`
 }
 
@@ -49,12 +58,41 @@ type GitHubRelease struct {
 	Name string `yaml:"name" validate:"required"`
 	Tag  string `yaml:"tag"`
 
-	Asset GitHubReleaseAsset `yaml:"asset"`
+	// Channel follows the newest release in stable, prerelease or nightly
+	// channel instead of a fixed tag
+	Channel string `yaml:"channel" validate:"omitempty,oneof=stable prerelease nightly,excluded_with=Tag"`
+	// TagPattern is a glob to filter release tags in the channel (e.g. "nightly-*")
+	TagPattern string `yaml:"tag-pattern" validate:"excluded_without=Channel"`
+
+	Asset  GitHubReleaseAsset `yaml:"asset"`
+	Verify *Verify            `yaml:"verify"`
+
+	// resolved is a tag resolved from a semver constraint given in Tag or Channel
+	resolved string
+	// published is a published time of the resolved release
+	published time.Time
 }
 
 type GitHubReleaseAsset struct {
 	Filename     string            `yaml:"filename"`
 	Replacements map[string]string `yaml:"replacements"`
+	Checksum     *Checksum         `yaml:"checksum"`
+
+	// Rules to pick an asset when filename is not given.
+	// Include, Exclude and Prefer are regexps matched with asset names.
+	Include []string            `yaml:"include" validate:"dive,regexp"`
+	Exclude []string            `yaml:"exclude" validate:"dive,regexp"`
+	Prefer  []string            `yaml:"prefer"  validate:"dive,regexp"`
+	Aliases map[string][]string `yaml:"aliases"`
+}
+
+// host returns a base URL and a host name of GitHub
+func (c GitHub) host() (string, string) {
+	host := c.Host
+	if host == "" {
+		host = github.DefaultHost
+	}
+	return parseHost(host)
 }
 
 // Init runs initialization step related to GitHub packages
@@ -136,9 +174,20 @@ func (c GitHub) GetHome() string {
 	if c.IsGHExtension() {
 		return c.As.GHExtension.GetHome()
 	}
-	return filepath.Join(DataDir(), "github.com", c.Owner, c.Repo)
+	_, host := c.host()
+	return filepath.Join(DataDir(), host, c.Owner, c.Repo)
 }
 
 func (c GitHub) GetDependsOn() []string {
 	return c.DependsOn
 }
+
+// GetTimeout returns how long installation can take
+func (c GitHub) GetTimeout() time.Duration {
+	return c.Timeout
+}
+
+// GetRetry returns a policy to retry installation
+func (c GitHub) GetRetry() runner.Retry {
+	return c.Retry.policy()
+}
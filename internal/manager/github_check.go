@@ -35,7 +35,14 @@ func (c GitHub) Check(ctx context.Context, status chan<- runner.Status) error {
 		if err != nil {
 			err = fmt.Errorf("%s: failed to check release version: %w", c.Name, err)
 		}
-		status <- runner.Status{Name: c.GetName(), Done: true, Err: err != nil, Message: report.message}
+		status <- runner.Status{
+			Name:       c.GetName(),
+			Done:       true,
+			Err:        err != nil,
+			Message:    report.message,
+			OldVersion: report.current,
+			NewVersion: report.latest,
+		}
 		return err
 	}
 
@@ -45,16 +52,35 @@ func (c GitHub) Check(ctx context.Context, status chan<- runner.Status) error {
 
 type report struct {
 	message string
+	// current and latest are versions compared, set if the latest one is found
+	current string
+	latest  string
 }
 
 func (c GitHub) checkUpdates(ctx context.Context) (report, error) {
+	if c.Release.isResolvable() {
+		return checkResolvable(ctx, *c.Release, c.listReleases)
+	}
+	return checkUpdates(ctx, c.Release.Tag, func(ctx context.Context) (string, error) {
+		release, err := github.NewRelease(
+			ctx, c.Host, c.Owner, c.Repo, "latest",
+			github.WithWorkdir(c.GetHome()),
+		)
+		if err != nil {
+			return "", err
+		}
+		return release.Tag, nil
+	})
+}
+
+// checkUpdates compares a given tag with the latest one
+func checkUpdates(ctx context.Context, tag string, latest func(context.Context) (string, error)) (report, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	red := color.New(color.FgRed).SprintfFunc()
 	yellow := color.New(color.FgYellow).SprintfFunc()
 
-	tag := c.Release.Tag
 	switch tag {
 	case "latest", "stable", "nightly":
 		return report{message: tag}, nil
@@ -62,10 +88,7 @@ func (c GitHub) checkUpdates(ctx context.Context) (report, error) {
 		return report{message: "(tag not set)"}, nil
 	}
 
-	release, err := github.NewRelease(
-		ctx, c.Owner, c.Repo, "latest",
-		github.WithWorkdir(c.GetHome()),
-	)
+	latestTag, err := latest(ctx)
 	if err != nil {
 		return report{
 			message: fmt.Sprintf("%s %s", red("error!"), err),
@@ -74,12 +97,12 @@ func (c GitHub) checkUpdates(ctx context.Context) (report, error) {
 
 	current, err := semver.NewVersion(tag)
 	if err != nil {
-		return report{}, nil
+		return report{current: tag, latest: latestTag}, nil
 	}
 
-	next, err := semver.NewVersion(release.Tag)
+	next, err := semver.NewVersion(latestTag)
 	if err != nil {
-		return report{}, nil
+		return report{current: tag, latest: latestTag}, nil
 	}
 
 	switch current.Compare(next) {
@@ -87,10 +110,53 @@ func (c GitHub) checkUpdates(ctx context.Context) (report, error) {
 		return report{
 			message: fmt.Sprintf("%s v%s -> v%s",
 				yellow("new!"), current, next),
+			current: tag,
+			latest:  latestTag,
 		}, nil
 	case 0:
-		return report{message: "up-to-date"}, nil
+		return report{message: "up-to-date", current: tag, latest: latestTag}, nil
 	default:
 		return report{}, errors.New("invalid version comparison")
 	}
 }
+
+// checkResolvable reports the release which a semver constraint or a channel is resolved to.
+// For a constraint, the highest tag overall is also reported.
+func checkResolvable(ctx context.Context, spec GitHubRelease, list releaseLister) (report, error) {
+	red := color.New(color.FgRed).SprintfFunc()
+	yellow := color.New(color.FgYellow).SprintfFunc()
+
+	releases, err := list(ctx)
+	if err != nil {
+		return report{
+			message: fmt.Sprintf("%s %s", red("error!"), err),
+		}, err
+	}
+
+	if spec.Channel != "" {
+		release, err := selectRelease(releases, spec.Channel, spec.TagPattern)
+		if err != nil {
+			return report{
+				message: fmt.Sprintf("%s %s", red("error!"), err),
+			}, err
+		}
+		message := fmt.Sprintf("%s -> %s", spec.Channel, release.TagName)
+		if spec.Channel == channelNightly && !release.PublishedAt.IsZero() {
+			message += fmt.Sprintf(" (published %s)", release.PublishedAt.Format("2006-01-02"))
+		}
+		return report{message: message, latest: release.TagName}, nil
+	}
+
+	matched, newest, err := matchTags(releaseTags(releases), spec.Tag)
+	if err != nil {
+		return report{
+			message: fmt.Sprintf("%s %s", red("error!"), err),
+		}, err
+	}
+
+	message := fmt.Sprintf("%s -> %s", spec.Tag, matched)
+	if newest != "" && newest != matched {
+		message += fmt.Sprintf(" (%s %s)", yellow("latest:"), newest)
+	}
+	return report{message: message, latest: matched}, nil
+}
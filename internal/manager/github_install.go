@@ -6,64 +6,61 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 
-	"github.com/babarot/afx/internal/data"
 	"github.com/babarot/afx/internal/git"
 	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/lock"
 	"github.com/babarot/afx/internal/runner"
 	"github.com/babarot/afx/internal/state"
-	"github.com/babarot/afx/internal/templates"
 )
 
 // Clone runs git clone or fetches+checkouts if already cloned.
 func (c GitHub) Clone(ctx context.Context) error {
-	gitCmd := git.NewRunner()
-
 	var opt GitHubOption
 	if c.Option != nil {
 		opt = *c.Option
 	}
 
-	url := fmt.Sprintf("https://github.com/%s/%s", c.Owner, c.Repo)
+	return repository{
+		name:     c.GetName(),
+		ty:       "GitHub",
+		url:      c.cloneURL(),
+		home:     c.GetHome(),
+		branch:   c.Branch,
+		depth:    opt.Depth,
+		authHint: "Please set GITHUB_TOKEN or configure git credentials for private repositories",
+	}.clone(ctx)
+}
 
-	_, err := os.Stat(c.GetHome())
-	switch {
-	case os.IsNotExist(err):
-		args := []string{"clone", "--no-tags"}
-		if opt.Depth > 0 {
-			args = append(args, "--depth", strconv.Itoa(opt.Depth))
-		}
-		if c.Branch != "" {
-			args = append(args, "--branch", c.Branch)
-		}
-		args = append(args, url, c.GetHome())
+func (c GitHub) cloneURL() string {
+	base, _ := c.host()
+	return fmt.Sprintf("%s/%s/%s", base, c.Owner, c.Repo)
+}
 
-		if _, err := gitCmd.Run(ctx, args...); err != nil {
-			if git.IsAuthError(err) {
-				return fmt.Errorf("%s: authentication failed. Please set GITHUB_TOKEN or configure git credentials for private repositories: %w", c.GetName(), err)
-			}
-			return fmt.Errorf("%s: failed to clone repository: %w", c.GetName(), err)
-		}
-	default:
-		// Already cloned: fetch + checkout if branch is specified
-		if c.Branch != "" {
-			fetchArgs := []string{"fetch", "origin", c.Branch, "--no-tags", "--force"}
-			if opt.Depth > 0 {
-				fetchArgs = append(fetchArgs, "--depth", strconv.Itoa(opt.Depth))
-			}
-
-			if _, err := gitCmd.RunInDir(ctx, c.GetHome(), fetchArgs...); err != nil {
-				return fmt.Errorf("%s: failed to fetch repository: %w", c.GetName(), err)
-			}
-
-			if _, err := gitCmd.RunInDir(ctx, c.GetHome(), "checkout", "--force", c.Branch); err != nil {
-				return fmt.Errorf("%s: failed to checkout: %w", c.GetName(), err)
-			}
+// CloneURL returns a URL of the repository if it's installed by git clone
+func (c GitHub) CloneURL() string {
+	if c.Release != nil || c.IsGHExtension() {
+		return ""
+	}
+	return c.cloneURL()
+}
+
+// lockCommit checks out the locked commit in locked mode
+// and records the commit which HEAD points to into the lock file.
+func lockCommit(ctx context.Context, gitCmd *git.GitRunner, name, ty, home string) error {
+	lk := lock.FromContext(ctx)
+	if locked, ok := lk.Get(name); lk.Locked() && ok && locked.Commit != "" {
+		log.Printf("[DEBUG] %s: checkout locked commit %s", name, locked.Commit)
+		if err := gitCmd.Checkout(ctx, home, locked.Commit); err != nil {
+			return fmt.Errorf("%s: failed to checkout locked commit: %w", name, err)
 		}
 	}
 
-	return nil
+	head, err := gitCmd.Head(ctx, home)
+	if err != nil {
+		return fmt.Errorf("%s: failed to get HEAD commit: %w", name, err)
+	}
+	return lk.Record(name, lock.Package{Type: ty, Commit: head})
 }
 
 // Install installs from GitHub repository with git clone command
@@ -135,79 +132,39 @@ func (c GitHub) Install(ctx context.Context, status chan<- runner.Status) error
 
 // InstallFromRelease runs install from GitHub release, from not repository
 func (c GitHub) InstallFromRelease(ctx context.Context) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	owner, repo, tag := c.Owner, c.Repo, c.GetReleaseTag()
-	log.Printf("[DEBUG] install from release: %s/%s (%s)", owner, repo, tag)
-
-	release, err := github.NewRelease(
-		ctx, owner, repo, tag,
-		github.WithWorkdir(c.GetHome()),
-		github.WithFilter(func(filename string) github.FilterFunc {
-			if filename == "" {
-				// cancel filtering
-				return nil
-			}
-			return func(assets github.Assets) *github.Asset {
-				for _, asset := range assets {
-					if asset.Name == filename {
-						return &asset
-					}
-				}
-				return nil
-			}
-		}(c.templateFilename())),
-	)
-	if err != nil {
-		return err
-	}
-
-	asset, err := release.Download(ctx)
-	if err != nil {
-		return fmt.Errorf("%s: failed to download: %w", release.Name, err)
-	}
-
-	if err := release.Unarchive(asset); err != nil {
-		return fmt.Errorf("%s: failed to unarchive: %w", release.Name, err)
-	}
-
-	return nil
+	log.Printf("[DEBUG] install from release: %s/%s (%s)", c.Owner, c.Repo, c.GetReleaseTag())
+	return installFromRelease(ctx, c, "GitHub Release", c.Release,
+		func(ctx context.Context, tag string, opts ...github.Option) (*github.Release, error) {
+			return github.NewRelease(ctx, c.Host, c.Owner, c.Repo, tag, opts...)
+		}, c.listReleases)
 }
 
-func (c GitHub) templateFilename() string {
-	release := c.Release
-	if release == nil {
-		return ""
+// SelectAsset explains which release asset would be installed and why
+func (c GitHub) SelectAsset(ctx context.Context) (github.Selection, error) {
+	if c.Release == nil {
+		return github.Selection{}, fmt.Errorf("%s: release is not specified", c.Name)
 	}
+	return selectAsset(ctx, c, c.Release,
+		func(ctx context.Context, tag string, opts ...github.Option) (*github.Release, error) {
+			return github.NewRelease(ctx, c.Host, c.Owner, c.Repo, tag, opts...)
+		}, c.listReleases)
+}
 
-	filename := release.Asset.Filename
-	replacements := release.Asset.Replacements
+// listReleases lists releases of the repository
+func (c GitHub) listReleases(ctx context.Context) ([]github.ReleaseResponse, error) {
+	return github.ListReleases(ctx, c.Host, c.Owner, c.Repo)
+}
 
-	if filename == "" {
-		// no filename specified
-		return ""
+// Resolve resolves a semver constraint given as release tag or a channel to a release tag,
+// so that the resolved tag is regarded as the version of the package
+func (c GitHub) Resolve(ctx context.Context) error {
+	if c.Release == nil || !c.Release.isResolvable() {
+		return nil
 	}
-
-	log.Printf("[DEBUG] asset: templating filename from %q", filename)
-
-	data := data.New(
-		data.WithPackage(c),
-		data.WithRelease(data.Release{
-			Name: release.Name,
-			Tag:  release.Tag,
-		}),
-	)
-
-	filename, err := templates.New(data).
-		Replace(replacements).
-		Apply(filename)
-	if err != nil {
-		log.Printf("[WARN] asset: failed to template filename: %q", filename)
+	if err := c.Release.resolve(ctx, c.listReleases); err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
 	}
-
-	log.Printf("[DEBUG] asset: templated filename: -> %q", filename)
-	return filename
+	return nil
 }
 
 func (c GitHub) Uninstall(ctx context.Context) error {
@@ -0,0 +1,279 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/babarot/afx/internal/forge"
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/runner"
+	"github.com/babarot/afx/internal/state"
+)
+
+// GitLab represents a project hosted on GitLab.com or self-hosted GitLab
+type GitLab struct {
+	Name string `yaml:"name" validate:"required"`
+
+	// Host is a host of GitLab instance (default: gitlab.com)
+	Host string `yaml:"host"`
+	// Owner is a namespace of the project which may include subgroups
+	Owner       string        `yaml:"owner" validate:"required"`
+	Repo        string        `yaml:"repo" validate:"required"`
+	Description string        `yaml:"description"`
+	Branch      string        `yaml:"branch"`
+	Option      *GitHubOption `yaml:"with"`
+
+	Release *GitHubRelease `yaml:"release"`
+
+	Plugin  *Plugin  `yaml:"plugin"`
+	Command *Command `yaml:"command" validate:"required_with=Release"`
+
+	DependsOn []string `yaml:"depends-on"`
+
+	// Timeout cancels installation taking longer than it (e.g. 5m)
+	Timeout time.Duration `yaml:"timeout" validate:"min=0"`
+	Retry   *Retry        `yaml:"retry"`
+}
+
+func (c GitLab) host() (string, string) {
+	host := c.Host
+	if host == "" {
+		host = "gitlab.com"
+	}
+	return parseHost(host)
+}
+
+// Init runs initialization step related to GitLab packages
+func (c GitLab) Init() error {
+	var errs []error
+	if c.HasPluginBlock() {
+		if err := c.Plugin.Init(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.HasCommandBlock() {
+		if err := c.Command.Init(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c GitLab) cloneURL() string {
+	base, _ := c.host()
+	return fmt.Sprintf("%s/%s/%s.git", base, c.Owner, c.Repo)
+}
+
+// CloneURL returns a URL of the repository if it's installed by git clone
+func (c GitLab) CloneURL() string {
+	if c.Release != nil {
+		return ""
+	}
+	return c.cloneURL()
+}
+
+// Clone runs git clone or fetches+checkouts if already cloned.
+func (c GitLab) Clone(ctx context.Context) error {
+	var opt GitHubOption
+	if c.Option != nil {
+		opt = *c.Option
+	}
+	return repository{
+		name:   c.GetName(),
+		ty:     "GitLab",
+		url:    c.cloneURL(),
+		home:   c.GetHome(),
+		branch: c.Branch,
+		depth:  opt.Depth,
+	}.clone(ctx)
+}
+
+func (c GitLab) fetchRelease(ctx context.Context, tag string, opts ...github.Option) (*github.Release, error) {
+	base, _ := c.host()
+	release, err := forge.GitLabRelease(ctx, base, c.Owner+"/"+c.Repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	return github.NewReleaseWithAssets(c.Repo, release.Tag, release.Assets, opts...)
+}
+
+// SelectAsset explains which release asset would be installed and why
+func (c GitLab) SelectAsset(ctx context.Context) (github.Selection, error) {
+	if c.Release == nil {
+		return github.Selection{}, fmt.Errorf("%s: release is not specified", c.Name)
+	}
+	return selectAsset(ctx, c, c.Release, c.fetchRelease, nil)
+}
+
+// Install installs from GitLab project with git clone command or from its release
+func (c GitLab) Install(ctx context.Context, status chan<- runner.Status) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		log.Println("[DEBUG] canceled")
+		return nil
+	default:
+		// Go installing step!
+	}
+
+	switch {
+	case c.Release == nil:
+		if err := c.Clone(ctx); err != nil {
+			err = fmt.Errorf("%s: failed to clone repo: %w", c.Name, err)
+			status <- runner.Status{Name: c.GetName(), Done: true, Err: true}
+			return err
+		}
+	default:
+		log.Printf("[DEBUG] install from release: %s/%s (%s)", c.Owner, c.Repo, c.Release.Tag)
+		if err := installFromRelease(ctx, c, "GitLab Release", c.Release, c.fetchRelease, nil); err != nil {
+			err = fmt.Errorf("%s: failed to get from release: %w", c.Name, err)
+			status <- runner.Status{Name: c.GetName(), Done: true, Err: true}
+			return err
+		}
+	}
+
+	var errs []error
+	if c.HasPluginBlock() {
+		if err := c.Plugin.Install(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.HasCommandBlock() {
+		if err := c.Command.Install(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	status <- runner.Status{Name: c.GetName(), Done: true, Err: errors.Join(errs...) != nil}
+	return errors.Join(errs...)
+}
+
+// Installed returns true the GitLab package is already installed
+func (c GitLab) Installed() bool {
+	var list []bool
+
+	if c.HasPluginBlock() {
+		list = append(list, c.Plugin.Installed(c))
+	}
+
+	if c.HasCommandBlock() {
+		list = append(list, c.Command.Installed(c))
+	}
+
+	if !c.HasPluginBlock() && !c.HasCommandBlock() {
+		_, err := os.Stat(c.GetHome())
+		list = append(list, err == nil)
+	}
+
+	return allTrue(list)
+}
+
+func (c GitLab) Uninstall(ctx context.Context) error {
+	var errs []error
+
+	del := func(f string) {
+		err := os.RemoveAll(f)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		log.Printf("[INFO] Delete %s\n", f)
+	}
+
+	if c.HasCommandBlock() {
+		links, _ := c.Command.GetLink(c)
+		for _, link := range links {
+			del(link.From)
+			del(link.To)
+		}
+	}
+
+	del(c.GetHome())
+	return errors.Join(errs...)
+}
+
+func (c GitLab) Check(ctx context.Context, status chan<- runner.Status) error {
+	if c.Release == nil {
+		status <- runner.Status{Name: c.GetName(), Done: true, Err: false, Message: "(gitlab)", NoColor: true}
+		return nil
+	}
+	report, err := checkUpdates(ctx, c.Release.Tag, func(ctx context.Context) (string, error) {
+		base, _ := c.host()
+		release, err := forge.GitLabRelease(ctx, base, c.Owner+"/"+c.Repo, "latest")
+		return release.Tag, err
+	})
+	if err != nil {
+		err = fmt.Errorf("%s: failed to check release version: %w", c.Name, err)
+	}
+	status <- runner.Status{
+		Name:       c.GetName(),
+		Done:       true,
+		Err:        err != nil,
+		Message:    report.message,
+		OldVersion: report.current,
+		NewVersion: report.latest,
+	}
+	return err
+}
+
+func (c GitLab) HasPluginBlock() bool {
+	return c.Plugin != nil
+}
+
+func (c GitLab) HasCommandBlock() bool {
+	return c.Command != nil
+}
+
+func (c GitLab) HasReleaseBlock() bool {
+	return c.Release != nil
+}
+
+func (c GitLab) GetPluginBlock() Plugin {
+	if c.HasPluginBlock() {
+		return *c.Plugin
+	}
+	return Plugin{}
+}
+
+func (c GitLab) GetCommandBlock() Command {
+	if c.HasCommandBlock() {
+		return *c.Command
+	}
+	return Command{}
+}
+
+// GetName returns a name
+func (c GitLab) GetName() string {
+	return c.Name
+}
+
+// GetHome returns a path
+func (c GitLab) GetHome() string {
+	_, host := c.host()
+	return filepath.Join(DataDir(), host, c.Owner, c.Repo)
+}
+
+func (c GitLab) GetDependsOn() []string {
+	return c.DependsOn
+}
+
+// GetTimeout returns how long installation can take
+func (c GitLab) GetTimeout() time.Duration {
+	return c.Timeout
+}
+
+// GetRetry returns a policy to retry installation
+func (c GitLab) GetRetry() runner.Retry {
+	return c.Retry.policy()
+}
+
+func (c GitLab) GetResource() state.Resource {
+	return getResource(c)
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/babarot/afx/internal/lock"
+	"github.com/babarot/afx/internal/runner"
+)
+
+func TestGitLab_Install_release(t *testing.T) {
+	t.Setenv("AFX_DATA_DIR", t.TempDir())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Ftool/releases/v1.2.0":
+			fmt.Fprintf(w, `{"tag_name":"v1.2.0","assets":{"links":[
+				{"name":"tool_linux","url":"%[1]s/downloads/tool_linux"},
+				{"name":"tool_darwin","url":"%[1]s/downloads/tool_darwin"}]}}`, server.URL)
+		case "/downloads/tool_linux":
+			_, _ = io.WriteString(w, "#!/bin/sh\necho tool\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	pkg := GitLab{
+		Name:  "group/tool",
+		Host:  server.URL,
+		Owner: "group",
+		Repo:  "tool",
+		Release: &GitHubRelease{
+			Name:  "tool",
+			Tag:   "v1.2.0",
+			Asset: GitHubReleaseAsset{Filename: "tool_linux"},
+		},
+	}
+
+	lk, err := lock.Open(filepath.Join(t.TempDir(), "afx.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := lock.NewContext(context.Background(), lk)
+
+	status := make(chan runner.Status, 1)
+	if err := pkg.Install(ctx, status); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if s := <-status; s.Err {
+		t.Errorf("Install() status = %#v", s)
+	}
+
+	if _, err := os.Stat(filepath.Join(pkg.GetHome(), "tool")); err != nil {
+		t.Errorf("release asset is not installed: %v", err)
+	}
+	locked, ok := lk.Get(pkg.Name)
+	if !ok || locked.Type != "GitLab Release" || locked.Tag != "v1.2.0" || locked.Asset.Name != "tool_linux" {
+		t.Errorf("lock = %#v, want GitLab Release v1.2.0 tool_linux", locked)
+	}
+}
@@ -11,11 +11,16 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mholt/archives"
 
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/checksum"
 	"github.com/babarot/afx/internal/data"
+	"github.com/babarot/afx/internal/lock"
 	"github.com/babarot/afx/internal/runner"
+	"github.com/babarot/afx/internal/signature"
 	"github.com/babarot/afx/internal/state"
 	"github.com/babarot/afx/internal/templates"
 )
@@ -32,6 +37,13 @@ type HTTP struct {
 
 	DependsOn []string  `yaml:"depends-on"`
 	Templates Templates `yaml:"templates"`
+
+	// Timeout cancels installation taking longer than it (e.g. 5m)
+	Timeout time.Duration `yaml:"timeout" validate:"min=0"`
+	Retry   *Retry        `yaml:"retry"`
+
+	Checksum *Checksum `yaml:"checksum"`
+	Verify   *Verify   `yaml:"verify"`
 }
 
 type Templates struct {
@@ -56,22 +68,6 @@ func (c HTTP) Init() error {
 
 func (c HTTP) call(ctx context.Context) error {
 	log.Printf("[TRACE] Get %s\n", c.URL)
-	req, err := http.NewRequest(http.MethodGet, c.URL, nil)
-	if err != nil {
-		return err
-	}
-
-	client := new(http.Client)
-	resp, err := client.Do(req.WithContext(ctx))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	log.Printf("[DEBUG] response code: %d", resp.StatusCode)
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("%s: %d %s", c.GetName(), resp.StatusCode, http.StatusText(resp.StatusCode))
-	}
 
 	_ = os.MkdirAll(c.GetHome(), os.ModePerm)
 	dest := filepath.Join(c.GetHome(), filepath.Base(c.URL))
@@ -82,9 +78,35 @@ func (c HTTP) call(ctx context.Context) error {
 		return err
 	}
 	defer file.Close()
+
 	// Limit download size to 1 GiB to prevent disk exhaustion
 	const maxDownloadSize = 1 << 30
-	_, err = io.Copy(file, io.LimitReader(resp.Body, maxDownloadSize))
+	opts := []cache.FetchOption{cache.WithLimit(maxDownloadSize)}
+	if digest := c.knownDigest(ctx); digest != "" {
+		opts = append(opts, cache.WithDigest(digest))
+	}
+	entry, err := cache.FromContext(ctx).Fetch(ctx, c.URL, file, opts...)
+	if err != nil {
+		return err
+	}
+
+	digest := entry.SHA256
+	if err := c.verifyChecksum(ctx, digest); err != nil {
+		file.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := c.verifySignature(ctx, dest); err != nil {
+		file.Close()
+		os.Remove(dest)
+		return err
+	}
+
+	err = lock.FromContext(ctx).Record(c.GetName(), lock.Package{
+		Type:   "HTTP",
+		URL:    c.URL,
+		SHA256: digest,
+	})
 	if err != nil {
 		return err
 	}
@@ -96,6 +118,88 @@ func (c HTTP) call(ctx context.Context) error {
 	return nil
 }
 
+// knownDigest returns a digest of the file given in checksum or pinned in locked mode.
+// The file with the digest is used from the cache without any requests.
+// Otherwise the URL may serve different contents over time, so the cached file is revalidated.
+func (c HTTP) knownDigest(ctx context.Context) string {
+	if c.Checksum != nil && c.Checksum.Value != "" {
+		if digest, err := checksum.Normalize(c.Checksum.Value); err == nil {
+			return digest
+		}
+	}
+	lk := lock.FromContext(ctx)
+	if locked, ok := lk.Get(c.GetName()); lk.Locked() && ok && locked.URL == c.URL {
+		return locked.SHA256
+	}
+	return ""
+}
+
+// verifyChecksum verifies the downloaded file with the checksum if given.
+// from-asset is resolved as a relative path from the URL.
+func (c HTTP) verifyChecksum(ctx context.Context, digest string) error {
+	if c.Checksum == nil {
+		return nil
+	}
+	if c.Checksum.FromAsset != "" && cache.FromContext(ctx).Offline() {
+		log.Printf("[DEBUG] http: %s: verified with lock file instead of %s offline", c.GetName(), c.Checksum.FromAsset)
+		return nil
+	}
+	filename := filepath.Base(c.URL)
+	want := c.Checksum.Value
+	if c.Checksum.FromAsset != "" {
+		base, err := url.Parse(c.URL)
+		if err != nil {
+			return err
+		}
+		ref, err := url.Parse(c.Checksum.FromAsset)
+		if err != nil {
+			return err
+		}
+		want, err = checksum.Fetch(ctx, http.DefaultClient, base.ResolveReference(ref).String(), filename)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get checksum: %w", c.Checksum.FromAsset, err)
+		}
+	}
+	return checksum.Verify(filename, want, digest)
+}
+
+// verifySignature verifies the downloaded file with its signature if given.
+// signature is resolved as a relative path from the URL.
+func (c HTTP) verifySignature(ctx context.Context, path string) error {
+	if c.Verify == nil {
+		return nil
+	}
+	if cache.FromContext(ctx).Offline() {
+		log.Printf("[DEBUG] http: %s: verified with lock file instead of signature offline", c.GetName())
+		return nil
+	}
+	verifier, err := c.Verify.Verifier()
+	if err != nil {
+		return fmt.Errorf("invalid verify config: %w", err)
+	}
+	name := c.Verify.Signature
+	if name == "" {
+		name = filepath.Base(c.URL) + verifier.Extension()
+	}
+	base, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	ref, err := url.Parse(name)
+	if err != nil {
+		return err
+	}
+	sig, err := signature.Fetch(ctx, http.DefaultClient, base.ResolveReference(ref).String())
+	if err != nil {
+		return fmt.Errorf("%s: failed to get signature: %w", name, err)
+	}
+	if err := signature.VerifyFile(verifier, path, sig); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	log.Printf("[DEBUG] http: %s: signature verified with %s", c.GetName(), name)
+	return nil
+}
+
 // Install is
 func (c HTTP) Install(ctx context.Context, status chan<- runner.Status) error {
 	select {
@@ -271,6 +375,16 @@ func (c HTTP) GetDependsOn() []string {
 	return c.DependsOn
 }
 
+// GetTimeout returns how long installation can take
+func (c HTTP) GetTimeout() time.Duration {
+	return c.Timeout
+}
+
+// GetRetry returns a policy to retry installation
+func (c HTTP) GetRetry() runner.Retry {
+	return c.Retry.policy()
+}
+
 func (c HTTP) GetResource() state.Resource {
 	return getResource(c)
 }
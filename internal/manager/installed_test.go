@@ -16,6 +16,16 @@ func TestGitHub_GetHome(t *testing.T) {
 	}
 }
 
+func TestGitHub_GetHome_enterprise(t *testing.T) {
+	t.Setenv("HOME", "/test/home")
+	g := GitHub{Host: "https://ghe.example.com", Owner: "owner", Repo: "repo"}
+	want := filepath.Join("/test/home", ".afx", "ghe.example.com", "owner", "repo")
+	got := g.GetHome()
+	if got != want {
+		t.Errorf("GetHome() = %q, want %q", got, want)
+	}
+}
+
 func TestGitHub_GetHome_GHExtension(t *testing.T) {
 	g := GitHub{
 		Owner: "owner",
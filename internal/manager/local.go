@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"os"
+	"time"
 
 	pathutil "github.com/babarot/afx/internal/helpers/path"
 	"github.com/babarot/afx/internal/runner"
@@ -21,6 +22,10 @@ type Local struct {
 	Command *Command `yaml:"command"`
 
 	DependsOn []string `yaml:"depends-on"`
+
+	// Timeout cancels installation taking longer than it (e.g. 5m)
+	Timeout time.Duration `yaml:"timeout" validate:"min=0"`
+	Retry   *Retry        `yaml:"retry"`
 }
 
 // Init is
@@ -98,6 +103,16 @@ func (c Local) GetDependsOn() []string {
 	return c.DependsOn
 }
 
+// GetTimeout returns how long installation can take
+func (c Local) GetTimeout() time.Duration {
+	return c.Timeout
+}
+
+// GetRetry returns a policy to retry installation
+func (c Local) GetRetry() runner.Retry {
+	return c.Retry.policy()
+}
+
 func (c Local) GetResource() state.Resource {
 	return getResource(c)
 }
@@ -2,9 +2,12 @@ package manager
 
 import (
 	"context"
+	"slices"
+	"time"
 
 	"github.com/mattn/go-shellwords"
 
+	"github.com/babarot/afx/internal/github"
 	"github.com/babarot/afx/internal/runner"
 	"github.com/babarot/afx/internal/state"
 )
@@ -35,6 +38,10 @@ type Handler interface {
 
 	GetDependsOn() []string
 	GetResource() state.Resource
+
+	// GetTimeout and GetRetry limit tasks of the package (see runner.Limited)
+	GetTimeout() time.Duration
+	GetRetry() runner.Retry
 }
 
 // Package is an interface related to package itself
@@ -44,16 +51,53 @@ type Package interface {
 	Installer
 }
 
-// HasGitHubReleaseBlock returns true if release block is included in one package at least
+// Resolver is implemented by packages whose version is resolved from remote,
+// e.g. a release tag given as semver constraint
+type Resolver interface {
+	Resolve(ctx context.Context) error
+}
+
+// AssetSelector is implemented by packages installed from release assets
+type AssetSelector interface {
+	// SelectAsset explains which release asset would be installed and why
+	SelectAsset(ctx context.Context) (github.Selection, error)
+}
+
+// Cloner is a package installed by cloning a git repository.
+// CloneURL returns empty if the package is installed in other ways (e.g. from releases)
+type Cloner interface {
+	CloneURL() string
+}
+
+// HasGitHubReleaseBlock returns true if release block is included in one GitHub package at least.
+// Packages on GitHub Enterprise Server are not counted because they don't use GITHUB_TOKEN.
 func HasGitHubReleaseBlock(pkgs []Package) bool {
 	for _, pkg := range pkgs {
-		if pkg.HasReleaseBlock() {
+		g, ok := pkg.(*GitHub)
+		if !ok || !pkg.HasReleaseBlock() {
+			continue
+		}
+		if _, host := g.host(); host == github.DefaultHost {
 			return true
 		}
 	}
 	return false
 }
 
+// GitHubHosts returns hosts of GitHub packages without duplicates.
+// github.com is always included as it's the default host
+func GitHubHosts(pkgs []Package) []string {
+	hosts := []string{github.DefaultHost}
+	for _, pkg := range pkgs {
+		g, ok := pkg.(*GitHub)
+		if !ok || g.Host == "" || slices.Contains(hosts, g.Host) {
+			continue
+		}
+		hosts = append(hosts, g.Host)
+	}
+	return hosts
+}
+
 // HasSudoInCommandBuildSteps returns true if sudo command is
 // included in one build step of given package at least
 func HasSudoInCommandBuildSteps(pkgs []Package) bool {
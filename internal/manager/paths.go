@@ -17,6 +17,27 @@ func DataDir() string {
 	return filepath.Join(os.Getenv("HOME"), ".afx")
 }
 
+// CacheDir returns the directory of the download cache.
+func CacheDir() string {
+	return filepath.Join(DataDir(), "cache")
+}
+
+// GitHubCacheDir returns the directory where responses of GitHub API are cached.
+// It's in the download cache directory so that they're cleared together.
+func GitHubCacheDir() string {
+	return filepath.Join(CacheDir(), "api")
+}
+
+// StateFile returns the path of state.json which records installed packages.
+func StateFile() string {
+	return filepath.Join(DataDir(), "state.json")
+}
+
+// GenerationsDir returns the directory where previous installations of packages are kept.
+func GenerationsDir() string {
+	return filepath.Join(DataDir(), "generations")
+}
+
 // ConfigDir returns the root directory for afx configuration files.
 // Priority: $AFX_CONFIG_DIR > $XDG_CONFIG_HOME/afx > ~/.config/afx
 func ConfigDir() string {
@@ -37,3 +58,12 @@ func BinDir() string {
 	}
 	return filepath.Join(os.Getenv("HOME"), "bin")
 }
+
+// LockFile returns the path of afx.lock which is placed next to config files.
+func LockFile() string {
+	root := ConfigDir()
+	if fi, err := os.Stat(root); err == nil && !fi.IsDir() {
+		root = filepath.Dir(root)
+	}
+	return filepath.Join(root, "afx.lock")
+}
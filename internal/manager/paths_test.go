@@ -1,6 +1,8 @@
 package manager
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -80,3 +82,29 @@ func TestBinDir(t *testing.T) {
 		}
 	})
 }
+
+func TestLockFile(t *testing.T) {
+	t.Run("config dir", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("AFX_CONFIG_DIR", dir)
+		got := LockFile()
+		want := filepath.Join(dir, "afx.lock")
+		if got != want {
+			t.Errorf("LockFile() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "afx.yaml")
+		if err := os.WriteFile(file, []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("AFX_CONFIG_DIR", file)
+		got := LockFile()
+		want := filepath.Join(dir, "afx.lock")
+		if got != want {
+			t.Errorf("LockFile() = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,444 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/data"
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/lock"
+	"github.com/babarot/afx/internal/templates"
+)
+
+// releaseFetcher gets a release with a given tag from the hosting service
+type releaseFetcher func(ctx context.Context, tag string, opts ...github.Option) (*github.Release, error)
+
+// releaseLister lists releases to resolve a semver constraint or a channel
+type releaseLister func(ctx context.Context) ([]github.ReleaseResponse, error)
+
+// Release channels to follow instead of a fixed tag
+const (
+	// channelStable follows the newest release which is not a pre-release
+	channelStable = "stable"
+	// channelPrerelease follows the newest release including pre-releases
+	channelPrerelease = "prerelease"
+	// channelNightly follows the newest release whose tag matches "nightly*",
+	// which is usually re-published with the same tag
+	channelNightly = "nightly"
+)
+
+// installFromRelease downloads the release asset, verifies it and unarchives it
+// into the package home. ty is a package type recorded in lock file.
+// list can be nil if the hosting service doesn't support semver constraint tags and channels.
+func installFromRelease(ctx context.Context, pkg Package, ty string, spec *GitHubRelease, fetch releaseFetcher, list releaseLister) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tag, filename, opts, err := releaseOptions(ctx, pkg, spec, list)
+	if err != nil {
+		return err
+	}
+
+	pinned, pin := pinnedAsset(ctx, pkg, spec, tag)
+	release, ok := cachedRelease(ctx, pkg, spec, tag, filename, pinned, pin, opts)
+	if !ok && cache.FromContext(ctx).Offline() {
+		return fmt.Errorf("%s: release asset is not available offline", pkg.GetName())
+	}
+	if !ok {
+		release, err = fetch(ctx, tag, opts...)
+		if err != nil {
+			return err
+		}
+		if pin {
+			// use the cached asset without any requests if it's not changed
+			for i, asset := range release.Assets {
+				if asset.URL == pinned.URL {
+					release.Assets[i].SHA256 = pinned.SHA256
+				}
+			}
+		}
+	}
+
+	asset, err := release.Download(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: failed to download: %w", release.Name, err)
+	}
+
+	err = lock.FromContext(ctx).Record(pkg.GetName(), lock.Package{
+		Type: ty,
+		Tag:  release.Tag,
+		Asset: &lock.Asset{
+			Name:   asset.Name,
+			URL:    asset.URL,
+			SHA256: asset.SHA256,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := release.Unarchive(asset); err != nil {
+		return fmt.Errorf("%s: failed to unarchive: %w", release.Name, err)
+	}
+
+	return nil
+}
+
+// selectAsset explains which asset of the release would be installed and why
+func selectAsset(ctx context.Context, pkg Package, spec *GitHubRelease, fetch releaseFetcher, list releaseLister) (github.Selection, error) {
+	release, err := prepareRelease(ctx, pkg, spec, fetch, list)
+	if err != nil {
+		return github.Selection{}, err
+	}
+	return release.Select()
+}
+
+// prepareRelease resolves the tag to be installed and gets the release
+// with options to pick, verify and unarchive the asset.
+func prepareRelease(ctx context.Context, pkg Package, spec *GitHubRelease, fetch releaseFetcher, list releaseLister) (*github.Release, error) {
+	tag, _, opts, err := releaseOptions(ctx, pkg, spec, list)
+	if err != nil {
+		return nil, err
+	}
+	return fetch(ctx, tag, opts...)
+}
+
+// releaseOptions resolves the tag and the asset filename to be installed
+// and returns options to pick, verify and unarchive the asset.
+func releaseOptions(ctx context.Context, pkg Package, spec *GitHubRelease, list releaseLister) (string, string, []github.Option, error) {
+	tag := spec.Tag
+
+	lk := lock.FromContext(ctx)
+	locked, ok := lk.Get(pkg.GetName())
+	if lk.Locked() && ok && locked.Tag != "" {
+		// pin moving tags such as "latest", constraints or channels to the locked one
+		if tag == "latest" || tag == "" || spec.isResolvable() {
+			tag = locked.Tag
+		}
+	}
+
+	if spec.isResolvable() {
+		if tag == spec.Tag {
+			// it may be already resolved by Resolve
+			if spec.resolved == "" {
+				if err := spec.resolve(ctx, list); err != nil {
+					return "", "", nil, fmt.Errorf("%s: %w", pkg.GetName(), err)
+				}
+			}
+			tag = spec.resolved
+		}
+		spec.resolved = tag
+	}
+
+	filename := spec.templateFilename(pkg)
+	if lk.Locked() && ok && filename == "" && locked.Asset != nil {
+		filename = locked.Asset.Name
+	}
+
+	verifier, err := spec.verifier(pkg)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	return tag, filename, []github.Option{
+		github.WithWorkdir(pkg.GetHome()),
+		github.WithFilter(func(filename string) github.FilterFunc {
+			if filename == "" {
+				// cancel filtering
+				return nil
+			}
+			return func(assets github.Assets) *github.Asset {
+				for _, asset := range assets {
+					if asset.Name == filename {
+						return &asset
+					}
+				}
+				return nil
+			}
+		}(filename)),
+		github.WithMatcher(spec.matcher()),
+		github.WithChecksum(spec.checksum(pkg)),
+		github.WithCache(cache.FromContext(ctx)),
+		verifier,
+	}, nil
+}
+
+// pinnedAsset returns the asset recorded in lock file if it's still valid for the tag.
+// Assets of nightly builds can be re-published with the same tag,
+// so they're pinned only in locked mode.
+func pinnedAsset(ctx context.Context, pkg Package, spec *GitHubRelease, tag string) (lock.Asset, bool) {
+	lk := lock.FromContext(ctx)
+	locked, ok := lk.Get(pkg.GetName())
+	if !ok || locked.Asset == nil || locked.Asset.SHA256 == "" || locked.Tag != tag {
+		return lock.Asset{}, false
+	}
+	if spec.Channel == channelNightly && !lk.Locked() {
+		return lock.Asset{}, false
+	}
+	return *locked.Asset, true
+}
+
+// cachedRelease returns the release consisting of the pinned asset if it's
+// cached, so that reinstalling doesn't need any requests to the hosting service.
+//
+// It's not used if the asset needs to be verified with other files in the release,
+// unless the cache is offline (e.g. installing from a bundle). Then the asset is
+// verified with the digest in lock file instead, which was verified when recorded.
+func cachedRelease(ctx context.Context, pkg Package, spec *GitHubRelease, tag, filename string, pinned lock.Asset, pin bool, opts []github.Option) (*github.Release, bool) {
+	c := cache.FromContext(ctx)
+	if !pin || !c.Has(pinned.SHA256) {
+		return nil, false
+	}
+	remote := spec.Asset.Checksum != nil && spec.Asset.Checksum.FromAsset != "" || spec.Verify != nil
+	if remote && !c.Offline() {
+		return nil, false
+	}
+	if remote {
+		opts = append(opts,
+			github.WithChecksum(github.Checksum{Digest: pinned.SHA256}),
+			github.WithVerifier(nil, ""),
+		)
+	}
+	if filename != "" && filename != pinned.Name {
+		return nil, false
+	}
+
+	// releases are named after the repository, which is the last element of the home
+	name := filepath.Base(pkg.GetHome())
+	release, err := github.NewReleaseWithAssets(name, tag, github.Assets{{
+		Name:   pinned.Name,
+		URL:    pinned.URL,
+		SHA256: pinned.SHA256,
+	}}, opts...)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := release.Select(); err != nil {
+		// asset rules may be changed
+		return nil, false
+	}
+	log.Printf("[DEBUG] %s: using cached asset %s", pkg.GetName(), pinned.Name)
+	return release, true
+}
+
+// template applies template variables to a field of release block
+func (r GitHubRelease) template(pkg Package, field, value string) string {
+	data := data.New(
+		data.WithPackage(pkg),
+		data.WithRelease(data.Release{
+			Name: r.Name,
+			Tag:  r.tag(),
+		}),
+	)
+	templated, err := templates.New(data).
+		Replace(r.Asset.Replacements).
+		Apply(value)
+	if err != nil {
+		log.Printf("[WARN] %s: failed to template: %q", field, value)
+		return value
+	}
+	return templated
+}
+
+func (r GitHubRelease) templateFilename(pkg Package) string {
+	filename := r.Asset.Filename
+	if filename == "" {
+		// no filename specified
+		return ""
+	}
+
+	log.Printf("[DEBUG] asset: templating filename from %q", filename)
+	filename = r.template(pkg, "asset", filename)
+	log.Printf("[DEBUG] asset: templated filename: -> %q", filename)
+	return filename
+}
+
+// matcher returns rules to pick the asset when filename is not given
+func (r GitHubRelease) matcher() github.Matcher {
+	return github.Matcher{
+		Include: r.Asset.Include,
+		Exclude: r.Asset.Exclude,
+		Prefer:  r.Asset.Prefer,
+		Aliases: r.Asset.Aliases,
+	}
+}
+
+// checksum returns the expected checksum of the release asset.
+// from-asset can be templated as well as asset filename.
+func (r GitHubRelease) checksum(pkg Package) github.Checksum {
+	sum := r.Asset.Checksum
+	switch {
+	case sum == nil:
+		return github.Checksum{}
+	case sum.FromAsset == "":
+		return github.Checksum{Digest: sum.Value}
+	default:
+		return github.Checksum{FromAsset: r.template(pkg, "checksum", sum.FromAsset)}
+	}
+}
+
+// verifier returns the option to verify the signature of the release asset.
+// signature can be templated as well as asset filename.
+func (r GitHubRelease) verifier(pkg Package) (github.Option, error) {
+	if r.Verify == nil {
+		return func(*github.Release) {}, nil
+	}
+	verifier, err := r.Verify.Verifier()
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid verify config: %w", pkg.GetName(), err)
+	}
+	name := r.Verify.Signature
+	if name != "" {
+		name = r.template(pkg, "verify", name)
+	}
+	return github.WithVerifier(verifier, name), nil
+}
+
+// isConstraint returns true if the tag is a semver constraint (e.g. "~1.4", ">=2, <3")
+// rather than an exact tag or "latest"
+func (r GitHubRelease) isConstraint() bool {
+	return isConstraint(r.Tag)
+}
+
+func isConstraint(tag string) bool {
+	switch tag {
+	case "", "latest":
+		return false
+	}
+	if _, err := semver.NewVersion(tag); err == nil {
+		return false
+	}
+	_, err := semver.NewConstraint(tag)
+	return err == nil
+}
+
+// isResolvable returns true if the tag to be installed is resolved from releases,
+// i.e. the tag is a semver constraint or a channel is specified
+func (r GitHubRelease) isResolvable() bool {
+	return r.Channel != "" || r.isConstraint()
+}
+
+// tag returns the tag to be installed. If the tag is a constraint or a channel
+// is specified, it's the resolved one (empty until resolved)
+func (r GitHubRelease) tag() string {
+	if r.isResolvable() {
+		return r.resolved
+	}
+	return r.Tag
+}
+
+// version returns the version of the release recorded in the state.
+// Nightly releases are re-published with the same tag, so the published time
+// is also included to detect the updates.
+func (r GitHubRelease) version() string {
+	if r.Channel == channelNightly && r.resolved != "" && !r.published.IsZero() {
+		return fmt.Sprintf("%s@%s", r.resolved, r.published.UTC().Format(time.RFC3339))
+	}
+	return r.tag()
+}
+
+// resolve resolves the constraint tag or the channel to a release tag
+func (r *GitHubRelease) resolve(ctx context.Context, list releaseLister) error {
+	if list == nil {
+		return fmt.Errorf("%s: semver constraint and channel are not supported in this package type", r.target())
+	}
+	releases, err := list(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list releases: %w", err)
+	}
+	if r.Channel != "" {
+		release, err := selectRelease(releases, r.Channel, r.TagPattern)
+		if err != nil {
+			return err
+		}
+		r.resolved, r.published = release.TagName, release.PublishedAt
+	} else {
+		matched, _, err := matchTags(releaseTags(releases), r.Tag)
+		if err != nil {
+			return err
+		}
+		r.resolved = matched
+	}
+	log.Printf("[DEBUG] resolved %q to %s", r.target(), r.resolved)
+	return nil
+}
+
+// target returns what the release follows, a constraint or a channel
+func (r GitHubRelease) target() string {
+	if r.Channel != "" {
+		return r.Channel
+	}
+	return r.Tag
+}
+
+func releaseTags(releases []github.ReleaseResponse) []string {
+	var tags []string
+	for _, release := range releases {
+		tags = append(tags, release.TagName)
+	}
+	return tags
+}
+
+// selectRelease returns the newest release in a given channel whose tag matches the pattern.
+// Releases should be ordered from newest to oldest as returned by the API.
+func selectRelease(releases []github.ReleaseResponse, channel, pattern string) (github.ReleaseResponse, error) {
+	if pattern == "" && channel == channelNightly {
+		pattern = "nightly*"
+	}
+	for _, release := range releases {
+		if pattern != "" {
+			ok, err := path.Match(pattern, release.TagName)
+			if err != nil {
+				return github.ReleaseResponse{}, fmt.Errorf("%q: invalid tag pattern: %w", pattern, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		if channel == channelStable && release.Prerelease {
+			continue
+		}
+		return release, nil
+	}
+	if pattern != "" {
+		return github.ReleaseResponse{}, fmt.Errorf("no release matches %q in %s channel", pattern, channel)
+	}
+	return github.ReleaseResponse{}, fmt.Errorf("no release found in %s channel", channel)
+}
+
+// matchTags returns the highest tag satisfying the constraint and the highest tag overall.
+// Pre-releases are skipped unless the constraint has a pre-release (e.g. ">=2.0.0-0").
+func matchTags(tags []string, constraint string) (string, string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", "", fmt.Errorf("%q: invalid semver constraint: %w", constraint, err)
+	}
+
+	var matched, newest *semver.Version
+	var matchedTag, newestTag string
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil {
+			log.Printf("[TRACE] skip; %q is not semver", tag)
+			continue
+		}
+		if v.Prerelease() == "" && (newest == nil || v.GreaterThan(newest)) {
+			newest, newestTag = v, tag
+		}
+		if c.Check(v) && (matched == nil || v.GreaterThan(matched)) {
+			matched, matchedTag = v, tag
+		}
+	}
+
+	if matched == nil {
+		return "", newestTag, fmt.Errorf("no release matches %q", constraint)
+	}
+	return matchedTag, newestTag, nil
+}
@@ -0,0 +1,528 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/github"
+	"github.com/babarot/afx/internal/lock"
+	"github.com/babarot/afx/internal/runner"
+)
+
+func TestIsConstraint(t *testing.T) {
+	tests := map[string]struct {
+		tag  string
+		want bool
+	}{
+		"empty":          {tag: "", want: false},
+		"latest":         {tag: "latest", want: false},
+		"exact":          {tag: "v1.4.2", want: false},
+		"non-semver tag": {tag: "jq-1.6", want: false},
+		"nightly":        {tag: "nightly", want: false},
+		"tilde":          {tag: "~1.4", want: true},
+		"caret":          {tag: "^2", want: true},
+		"range":          {tag: ">=2, <3", want: true},
+		"wildcard":       {tag: "1.4.x", want: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := isConstraint(tt.tag); got != tt.want {
+				t.Errorf("isConstraint(%q) = %v, want %v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	tags := []string{"v2.1.0-rc.1", "v2.0.1", "v2.0.0", "v1.5.0", "v1.4.10", "v1.4.2", "nightly", "v1.3.0"}
+
+	tests := map[string]struct {
+		constraint  string
+		wantMatched string
+		wantNewest  string
+		wantErr     bool
+	}{
+		"tilde": {
+			constraint:  "~1.4",
+			wantMatched: "v1.4.10",
+			wantNewest:  "v2.0.1",
+		},
+		"range": {
+			constraint:  ">=2, <3",
+			wantMatched: "v2.0.1",
+			wantNewest:  "v2.0.1",
+		},
+		"pre-release": {
+			constraint:  ">=2.1.0-0",
+			wantMatched: "v2.1.0-rc.1",
+			wantNewest:  "v2.0.1",
+		},
+		"no match": {
+			constraint: "~3",
+			wantNewest: "v2.0.1",
+			wantErr:    true,
+		},
+		"invalid": {
+			constraint: "nightly",
+			wantErr:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			matched, newest, err := matchTags(tags, tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matchTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if matched != tt.wantMatched || newest != tt.wantNewest {
+				t.Errorf("matchTags() = (%q, %q), want (%q, %q)", matched, newest, tt.wantMatched, tt.wantNewest)
+			}
+		})
+	}
+}
+
+func TestGitHub_Install_constraint(t *testing.T) {
+	t.Setenv("AFX_DATA_DIR", t.TempDir())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/owner/tool/releases":
+			_ = json.NewEncoder(w).Encode([]map[string]string{
+				{"tag_name": "v2.0.0"},
+				{"tag_name": "v1.4.3"},
+				{"tag_name": "v1.4.2"},
+			})
+		case "/api/v3/repos/owner/tool/releases/tags/v1.4.3":
+			fmt.Fprintf(w, `{"tag_name":"v1.4.3","assets":[
+				{"name":"tool_1.4.3_linux","browser_download_url":"%s/downloads/tool_1.4.3_linux"}]}`, server.URL)
+		case "/downloads/tool_1.4.3_linux":
+			_, _ = io.WriteString(w, "#!/bin/sh\necho tool\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	pkg := GitHub{
+		Name:  "owner/tool",
+		Host:  server.URL,
+		Owner: "owner",
+		Repo:  "tool",
+		Release: &GitHubRelease{
+			Name:  "tool",
+			Tag:   "~1.4",
+			Asset: GitHubReleaseAsset{Filename: "tool_{{ trimprefix .Release.Tag \"v\" }}_linux"},
+		},
+	}
+
+	if got := pkg.GetResource().Version; got != "" {
+		t.Errorf("version before resolved = %q, want empty", got)
+	}
+
+	lk, err := lock.Open(filepath.Join(t.TempDir(), "afx.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := lock.NewContext(context.Background(), lk)
+
+	status := make(chan runner.Status, 1)
+	if err := pkg.Install(ctx, status); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if s := <-status; s.Err {
+		t.Errorf("Install() status = %#v", s)
+	}
+
+	if _, err := os.Stat(filepath.Join(pkg.GetHome(), "tool")); err != nil {
+		t.Errorf("release asset is not installed: %v", err)
+	}
+	if got := pkg.GetResource().Version; got != "v1.4.3" {
+		t.Errorf("version after installed = %q, want %q", got, "v1.4.3")
+	}
+	if locked, ok := lk.Get(pkg.Name); !ok || locked.Tag != "v1.4.3" {
+		t.Errorf("lock = %#v, want v1.4.3", locked)
+	}
+
+	report, err := pkg.checkUpdates(context.Background())
+	if err != nil {
+		t.Fatalf("checkUpdates() error: %v", err)
+	}
+	if want := "~1.4 -> v1.4.3"; !strings.HasPrefix(report.message, want) {
+		t.Errorf("checkUpdates() message = %q, want prefix %q", report.message, want)
+	}
+	if report.latest != "v1.4.3" {
+		t.Errorf("checkUpdates() latest = %q, want %q", report.latest, "v1.4.3")
+	}
+}
+
+func TestGitHubRelease_channel_UnmarshalYAML(t *testing.T) {
+	tests := map[string]struct {
+		yaml    string
+		want    string
+		wantErr bool
+	}{
+		"nightly": {
+			yaml: "channel: nightly",
+			want: "nightly",
+		},
+		"prerelease with pattern": {
+			yaml: "channel: prerelease\n        tag-pattern: 'v2.*'",
+			want: "prerelease",
+		},
+		"unknown channel": {
+			yaml:    "channel: beta",
+			wantErr: true,
+		},
+		"channel with tag": {
+			yaml:    "channel: stable\n        tag: v1.0.0",
+			wantErr: true,
+		},
+		"pattern without channel": {
+			yaml:    "tag-pattern: 'nightly-*'",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := `github:
+  - name: neovim/neovim
+    owner: neovim
+    repo: neovim
+    release:
+        name: nvim
+        ` + tt.yaml + `
+    command:
+      link:
+      - from: nvim
+`
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Read(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := cfg.GitHub[0].Release.Channel; got != tt.want {
+				t.Errorf("Release.Channel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectRelease(t *testing.T) {
+	releases := []github.ReleaseResponse{
+		{TagName: "nightly", Prerelease: true},
+		{TagName: "v0.11.0-rc1", Prerelease: true},
+		{TagName: "v0.10.2"},
+		{TagName: "nightly-2024-01-01", Prerelease: true},
+		{TagName: "v0.10.1"},
+	}
+
+	tests := map[string]struct {
+		channel string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		"stable": {
+			channel: "stable",
+			want:    "v0.10.2",
+		},
+		"prerelease": {
+			channel: "prerelease",
+			want:    "nightly",
+		},
+		"prerelease with pattern": {
+			channel: "prerelease",
+			pattern: "v*",
+			want:    "v0.11.0-rc1",
+		},
+		"nightly": {
+			channel: "nightly",
+			want:    "nightly",
+		},
+		"nightly with pattern": {
+			channel: "nightly",
+			pattern: "nightly-*",
+			want:    "nightly-2024-01-01",
+		},
+		"stable with pattern": {
+			channel: "stable",
+			pattern: "v0.10.1",
+			want:    "v0.10.1",
+		},
+		"not found": {
+			channel: "stable",
+			pattern: "v1.*",
+			wantErr: true,
+		},
+		"invalid pattern": {
+			channel: "nightly",
+			pattern: "[",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := selectRelease(releases, tt.channel, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectRelease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.TagName != tt.want {
+				t.Errorf("selectRelease() = %q, want %q", got.TagName, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHub_Install_nightly(t *testing.T) {
+	t.Setenv("AFX_DATA_DIR", t.TempDir())
+
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/neovim/neovim/releases":
+			_ = json.NewEncoder(w).Encode([]github.ReleaseResponse{
+				{TagName: "nightly", Prerelease: true, PublishedAt: published},
+				{TagName: "v0.10.2"},
+			})
+		case "/api/v3/repos/neovim/neovim/releases/tags/nightly":
+			fmt.Fprintf(w, `{"tag_name":"nightly","prerelease":true,"assets":[
+				{"name":"nvim","browser_download_url":"%s/downloads/nvim"}]}`, server.URL)
+		case "/downloads/nvim":
+			_, _ = io.WriteString(w, "#!/bin/sh\necho nvim\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	pkg := GitHub{
+		Name:    "neovim/neovim",
+		Host:    server.URL,
+		Owner:   "neovim",
+		Repo:    "neovim",
+		Release: &GitHubRelease{Name: "nvim", Channel: "nightly"},
+	}
+
+	if got := pkg.GetResource().Version; got != "" {
+		t.Errorf("version before resolved = %q, want empty", got)
+	}
+
+	report, err := pkg.checkUpdates(context.Background())
+	if err != nil {
+		t.Fatalf("checkUpdates() error: %v", err)
+	}
+	if want := "nightly -> nightly (published 2024-01-02)"; report.message != want {
+		t.Errorf("checkUpdates() message = %q, want %q", report.message, want)
+	}
+
+	status := make(chan runner.Status, 1)
+	if err := pkg.Install(context.Background(), status); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if s := <-status; s.Err {
+		t.Errorf("Install() status = %#v", s)
+	}
+
+	if _, err := os.Stat(filepath.Join(pkg.GetHome(), "neovim")); err != nil {
+		t.Errorf("release asset is not installed: %v", err)
+	}
+	if want := "nightly@2024-01-02T03:04:05Z"; pkg.GetResource().Version != want {
+		t.Errorf("version after installed = %q, want %q", pkg.GetResource().Version, want)
+	}
+}
+
+func TestGitHubReleaseAsset_rules_UnmarshalYAML(t *testing.T) {
+	tests := map[string]struct {
+		yaml    string
+		want    GitHubReleaseAsset
+		wantErr bool
+	}{
+		"rules": {
+			yaml: `asset:
+          include: ['\.tar\.gz$']
+          exclude: ['\.deb$']
+          prefer: [musl, gnu]
+          aliases:
+            arm64: [universal]`,
+			want: GitHubReleaseAsset{
+				Include: []string{`\.tar\.gz$`},
+				Exclude: []string{`\.deb$`},
+				Prefer:  []string{"musl", "gnu"},
+				Aliases: map[string][]string{"arm64": {"universal"}},
+			},
+		},
+		"invalid regexp": {
+			yaml: `asset:
+          prefer: ['(musl']`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := `github:
+  - name: BurntSushi/ripgrep
+    owner: BurntSushi
+    repo: ripgrep
+    release:
+        name: rg
+        tag: 14.1.0
+        ` + tt.yaml + `
+    command:
+      link:
+      - from: rg
+`
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Read(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, cfg.GitHub[0].Release.Asset); diff != "" {
+				t.Errorf("Release.Asset mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitHub_SelectAsset(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/BurntSushi/ripgrep/releases/tags/14.1.0":
+			_ = json.NewEncoder(w).Encode(github.ReleaseResponse{
+				TagName: "14.1.0",
+				Assets: []github.AssetsResponse{
+					{Name: "ripgrep-14.1.0-gnu.tar.gz"},
+					{Name: "ripgrep-14.1.0-musl.tar.gz"},
+					{Name: "ripgrep-14.1.0-musl.tar.gz.sha256"},
+					{Name: "ripgrep_14.1.0-1.deb"},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	pkg := GitHub{
+		Name:  "BurntSushi/ripgrep",
+		Host:  server.URL,
+		Owner: "BurntSushi",
+		Repo:  "ripgrep",
+		Release: &GitHubRelease{
+			Name: "rg",
+			Tag:  "14.1.0",
+			Asset: GitHubReleaseAsset{
+				Exclude: []string{`\.deb$`},
+				Prefer:  []string{"musl"},
+			},
+		},
+	}
+
+	selection, err := pkg.SelectAsset(context.Background())
+	if err != nil {
+		t.Fatalf("SelectAsset() error: %v", err)
+	}
+	if want := "ripgrep-14.1.0-musl.tar.gz"; selection.Asset.Name != want {
+		t.Errorf("SelectAsset() = %q, want %q", selection.Asset.Name, want)
+	}
+
+	want := map[string]string{
+		"ripgrep-14.1.0-gnu.tar.gz":         "less preferred than ripgrep-14.1.0-musl.tar.gz",
+		"ripgrep-14.1.0-musl.tar.gz":        `picked: preferred by "musl"`,
+		"ripgrep-14.1.0-musl.tar.gz.sha256": "checksum file",
+		"ripgrep_14.1.0-1.deb":              `excluded by "\\.deb$"`,
+	}
+	reasons := map[string]string{}
+	for _, c := range selection.Candidates {
+		reasons[c.Asset.Name] = c.Reason
+	}
+	if diff := cmp.Diff(want, reasons); diff != "" {
+		t.Errorf("reasons mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGitHub_Install_cached(t *testing.T) {
+	t.Setenv("AFX_DATA_DIR", t.TempDir())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/owner/tool/releases/tags/v1.0.0":
+			fmt.Fprintf(w, `{"tag_name":"v1.0.0","assets":[
+				{"name":"tool","browser_download_url":"%s/downloads/tool"}]}`, server.URL)
+		case "/downloads/tool":
+			_, _ = io.WriteString(w, "#!/bin/sh\necho tool\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	pkg := GitHub{
+		Name:    "owner/tool",
+		Host:    server.URL,
+		Owner:   "owner",
+		Repo:    "tool",
+		Release: &GitHubRelease{Name: "tool", Tag: "v1.0.0"},
+	}
+
+	lk, err := lock.Open(filepath.Join(t.TempDir(), "afx.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := lock.NewContext(context.Background(), lk)
+	ctx = cache.NewContext(ctx, cache.New(CacheDir()))
+
+	install := func() error {
+		status := make(chan runner.Status, 1)
+		err := pkg.Install(ctx, status)
+		<-status
+		return err
+	}
+
+	if err := install(); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+
+	// reinstall without the network
+	server.Close()
+	if err := os.RemoveAll(pkg.GetHome()); err != nil {
+		t.Fatal(err)
+	}
+	if err := install(); err != nil {
+		t.Fatalf("Install() from cache error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(pkg.GetHome(), "tool")); err != nil {
+		t.Errorf("release asset is not installed from cache: %v", err)
+	}
+}
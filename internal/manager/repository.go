@@ -0,0 +1,132 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/babarot/afx/internal/bundle"
+	"github.com/babarot/afx/internal/git"
+)
+
+// repository represents a git repository to be cloned into a package home
+type repository struct {
+	// name is a package name
+	name string
+	// ty is a package type recorded in lock file
+	ty     string
+	url    string
+	home   string
+	branch string
+	depth  int
+	// authHint is shown when authentication failed
+	authHint string
+}
+
+// clone runs git clone or fetches+checkouts if already cloned.
+func (r repository) clone(ctx context.Context) error {
+	gitCmd := git.NewRunner()
+
+	_, err := os.Stat(r.home)
+	switch {
+	case os.IsNotExist(err):
+		args := []string{"clone", "--no-tags"}
+		if r.depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(r.depth))
+		}
+		if r.branch != "" {
+			args = append(args, "--branch", r.branch)
+		}
+		if err := gitClone(ctx, gitCmd, r.url, r.home, args...); err != nil {
+			if git.IsAuthError(err) {
+				hint := r.authHint
+				if hint == "" {
+					hint = "Please configure git credentials for private repositories"
+				}
+				return fmt.Errorf("%s: authentication failed. %s: %w", r.name, hint, err)
+			}
+			return fmt.Errorf("%s: failed to clone repository: %w", r.name, err)
+		}
+	default:
+		// Already cloned: fetch + checkout if branch is specified
+		if r.branch != "" {
+			fetchArgs := []string{"fetch", "origin", r.branch, "--no-tags", "--force"}
+			if r.depth > 0 {
+				fetchArgs = append(fetchArgs, "--depth", strconv.Itoa(r.depth))
+			}
+
+			if _, err := gitCmd.RunInDir(ctx, r.home, fetchArgs...); err != nil {
+				return fmt.Errorf("%s: failed to fetch repository: %w", r.name, err)
+			}
+
+			if _, err := gitCmd.RunInDir(ctx, r.home, "checkout", "--force", r.branch); err != nil {
+				return fmt.Errorf("%s: failed to checkout: %w", r.name, err)
+			}
+		}
+	}
+
+	return lockCommit(ctx, gitCmd, r.name, r.ty, r.home)
+}
+
+// gitClone runs git clone with args to clone url into home. The repository is
+// cloned from its git bundle when installing from an afx bundle, and then
+// origin is set back to url so that it's updated from url later
+func gitClone(ctx context.Context, gitCmd *git.GitRunner, url, home string, args ...string) error {
+	src, bundled := bundle.GitBundle(ctx, url)
+	if !bundled {
+		src = url
+	}
+	if _, err := gitCmd.Run(ctx, append(args, src, home)...); err != nil {
+		return err
+	}
+	if !bundled {
+		return nil
+	}
+	_, err := gitCmd.RunInDir(ctx, home, "remote", "set-url", "origin", url)
+	return err
+}
+
+// parseHost returns a base URL and a host name from host field.
+// host can have a scheme (e.g. http://localhost:3000) for instances without TLS.
+func parseHost(host string) (string, string) {
+	if !strings.Contains(host, "://") {
+		return "https://" + host, host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return host, host
+	}
+	return strings.TrimSuffix(host, "/"), u.Host
+}
+
+// parseCloneURL returns a host and a path of repository from a clone URL.
+// Both URL (https://host/path.git, ssh://git@host/path.git) and
+// scp-like syntax (git@host:path.git) are supported.
+func parseCloneURL(cloneURL string) (string, string, error) {
+	var host, p string
+	if strings.Contains(cloneURL, "://") {
+		u, err := url.Parse(cloneURL)
+		if err != nil {
+			return "", "", err
+		}
+		host, p = u.Hostname(), u.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		at := strings.LastIndex(cloneURL, "@")
+		h, rest, ok := strings.Cut(cloneURL[at+1:], ":")
+		if !ok {
+			return "", "", fmt.Errorf("%s: invalid clone URL", cloneURL)
+		}
+		host, p = h, rest
+	}
+	p = strings.TrimSuffix(path.Clean("/"+p), ".git")
+	p = strings.TrimPrefix(p, "/")
+	if host == "" || p == "" {
+		return "", "", fmt.Errorf("%s: invalid clone URL", cloneURL)
+	}
+	return host, p, nil
+}
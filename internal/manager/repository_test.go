@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/babarot/afx/internal/bundle"
+	"github.com/babarot/afx/internal/git"
+	"github.com/babarot/afx/internal/lock"
+	"github.com/babarot/afx/internal/runner"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := map[string]struct {
+		host     string
+		wantBase string
+		wantName string
+	}{
+		"host only": {
+			host:     "gitlab.example.com",
+			wantBase: "https://gitlab.example.com",
+			wantName: "gitlab.example.com",
+		},
+		"with scheme and port": {
+			host:     "http://localhost:3000/",
+			wantBase: "http://localhost:3000",
+			wantName: "localhost:3000",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			base, host := parseHost(tt.host)
+			if base != tt.wantBase {
+				t.Errorf("parseHost() base = %q, want %q", base, tt.wantBase)
+			}
+			if host != tt.wantName {
+				t.Errorf("parseHost() host = %q, want %q", host, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestParseCloneURL(t *testing.T) {
+	tests := map[string]struct {
+		url      string
+		wantHost string
+		wantPath string
+		wantErr  bool
+	}{
+		"https": {
+			url:      "https://git.example.com/owner/repo.git",
+			wantHost: "git.example.com",
+			wantPath: "owner/repo",
+		},
+		"ssh with port": {
+			url:      "ssh://git@git.example.com:2222/owner/repo.git",
+			wantHost: "git.example.com",
+			wantPath: "owner/repo",
+		},
+		"scp-like": {
+			url:      "git@git.example.com:group/sub/repo.git",
+			wantHost: "git.example.com",
+			wantPath: "group/sub/repo",
+		},
+		"without .git": {
+			url:      "https://git.example.com/owner/repo",
+			wantHost: "git.example.com",
+			wantPath: "owner/repo",
+		},
+		"no path": {
+			url:     "https://git.example.com",
+			wantErr: true,
+		},
+		"invalid": {
+			url:     "repo",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			host, path, err := parseCloneURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCloneURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if host != tt.wantHost || path != tt.wantPath {
+				t.Errorf("parseCloneURL() = (%q, %q), want (%q, %q)", host, path, tt.wantHost, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestGit_Install_fromBundle(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("AFX_DATA_DIR", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "afx")
+	t.Setenv("GIT_AUTHOR_EMAIL", "afx@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "afx")
+	t.Setenv("GIT_COMMITTER_EMAIL", "afx@example.com")
+
+	ctx := context.Background()
+	gitCmd := git.NewRunner()
+
+	// a repository installed on another machine
+	src := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch", "main"},
+		{"commit", "--quiet", "--allow-empty", "-m", "first"},
+		{"commit", "--quiet", "--allow-empty", "-m", "second"},
+	} {
+		if _, err := gitCmd.RunInDir(ctx, src, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := gitCmd.RunInDir(ctx, src, "rev-parse", "HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := strings.TrimSpace(string(first))
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitCmd.Bundle(ctx, src, filepath.Join(dir, "git", "0.bundle")); err != nil {
+		t.Fatalf("Bundle() error: %v", err)
+	}
+
+	pkg := Git{Name: "tool", URL: "https://git.example.com/owner/tool.git"}
+	manifest := bundle.Manifest{Repositories: map[string]string{pkg.URL: "git/0.bundle"}}
+	ctx = bundle.NewContext(ctx, manifest.GitBundles(dir))
+
+	lk, err := lock.Open(filepath.Join(t.TempDir(), "afx.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lk.Record(pkg.Name, lock.Package{Type: "Git", Commit: commit}); err != nil {
+		t.Fatal(err)
+	}
+	lk.SetLocked(true)
+
+	status := make(chan runner.Status, 1)
+	if err := pkg.Install(lock.NewContext(ctx, lk), status); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	head, err := gitCmd.Head(ctx, pkg.GetHome())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != commit {
+		t.Errorf("HEAD = %s, want locked commit %s", head, commit)
+	}
+	origin, err := gitCmd.RunInDir(ctx, pkg.GetHome(), "remote", "get-url", "origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(origin)); got != pkg.URL {
+		t.Errorf("origin = %s, want %s", got, pkg.URL)
+	}
+}
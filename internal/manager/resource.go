@@ -1,8 +1,12 @@
 package manager
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
+	"github.com/babarot/afx/internal/git"
+	"github.com/babarot/afx/internal/lock"
 	"github.com/babarot/afx/internal/state"
 )
 
@@ -32,14 +36,15 @@ func getResource(pkg Package) state.Resource {
 
 	switch pkg := pkg.(type) {
 	case GitHub:
+		_, host := pkg.host()
 		ty = "GitHub"
 		if pkg.HasReleaseBlock() {
 			ty = "GitHub Release"
-			version = pkg.Release.Tag
+			version = pkg.Release.version()
 		}
-		id = fmt.Sprintf("github.com/%s/%s", pkg.Owner, pkg.Repo)
+		id = fmt.Sprintf("%s/%s/%s", host, pkg.Owner, pkg.Repo)
 		if pkg.HasReleaseBlock() {
-			id = fmt.Sprintf("github.com/release/%s/%s", pkg.Owner, pkg.Repo)
+			id = fmt.Sprintf("%s/release/%s/%s", host, pkg.Owner, pkg.Repo)
 		}
 		if pkg.IsGHExtension() {
 			ty = "GitHub (gh extension)"
@@ -48,6 +53,30 @@ func getResource(pkg Package) state.Resource {
 				paths = append(paths, alias)
 			}
 		}
+	case GitLab:
+		_, host := pkg.host()
+		ty = "GitLab"
+		id = fmt.Sprintf("%s/%s/%s", host, pkg.Owner, pkg.Repo)
+		if pkg.HasReleaseBlock() {
+			ty = "GitLab Release"
+			version = pkg.Release.version()
+			id = fmt.Sprintf("%s/release/%s/%s", host, pkg.Owner, pkg.Repo)
+		}
+	case Gitea:
+		_, host := pkg.host()
+		ty = "Gitea"
+		id = fmt.Sprintf("%s/%s/%s", host, pkg.Owner, pkg.Repo)
+		if pkg.HasReleaseBlock() {
+			ty = "Gitea Release"
+			version = pkg.Release.version()
+			id = fmt.Sprintf("%s/release/%s/%s", host, pkg.Owner, pkg.Repo)
+		}
+	case Git:
+		ty = "Git"
+		id = pkg.URL
+		if host, path, err := parseCloneURL(pkg.URL); err == nil {
+			id = fmt.Sprintf("%s/%s", host, path)
+		}
 	case Gist:
 		ty = "Gist"
 		id = fmt.Sprintf("gist.github.com/%s/%s", pkg.Owner, pkg.ID)
@@ -70,3 +99,24 @@ func getResource(pkg Package) state.Resource {
 		Paths:   paths,
 	}
 }
+
+// LockEntry returns a lock entry of a package taken from its installation,
+// to record packages installed before afx.lock was introduced. Release assets
+// and digests are not known after installed, so only tags and URLs are pinned
+func LockEntry(ctx context.Context, pkg Package, installed state.Resource) (lock.Package, bool) {
+	if cloner, ok := pkg.(Cloner); ok && cloner.CloneURL() != "" {
+		head, err := git.NewRunner().Head(ctx, installed.Home)
+		if err != nil {
+			return lock.Package{}, false
+		}
+		return lock.Package{Type: installed.Type, Commit: head}, true
+	}
+	switch pkg := pkg.(type) {
+	case HTTP:
+		return lock.Package{Type: "HTTP", URL: pkg.URL}, true
+	}
+	if strings.HasSuffix(installed.Type, " Release") && installed.Version != "" {
+		return lock.Package{Type: installed.Type, Tag: installed.Version}, true
+	}
+	return lock.Package{}, false
+}
@@ -1,7 +1,13 @@
 package manager
 
 import (
+	"context"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/lock"
+	"github.com/babarot/afx/internal/state"
 )
 
 func TestGetResource(t *testing.T) {
@@ -30,6 +36,18 @@ func TestGetResource(t *testing.T) {
 			wantID:   "github.com/release/o/r",
 			wantName: "test",
 		},
+		"GitHub Enterprise with Release": {
+			pkg: GitHub{
+				Name:    "test",
+				Host:    "ghe.example.com",
+				Owner:   "o",
+				Repo:    "r",
+				Release: &GitHubRelease{Name: "r", Tag: "v1.0"},
+			},
+			wantType: "GitHub Release",
+			wantID:   "ghe.example.com/release/o/r",
+			wantName: "test",
+		},
 		"GitHub GH Extension": {
 			pkg: GitHub{
 				Name:  "test",
@@ -43,6 +61,48 @@ func TestGetResource(t *testing.T) {
 			wantID:   "github.com/o/r",
 			wantName: "test",
 		},
+		"GitLab basic": {
+			pkg:      GitLab{Name: "test", Owner: "group/subgroup", Repo: "repo"},
+			wantType: "GitLab",
+			wantID:   "gitlab.com/group/subgroup/repo",
+			wantName: "test",
+		},
+		"GitLab self-hosted with Release": {
+			pkg: GitLab{
+				Name:    "test",
+				Host:    "gitlab.example.com",
+				Owner:   "o",
+				Repo:    "r",
+				Release: &GitHubRelease{Name: "r", Tag: "v1.0"},
+			},
+			wantType: "GitLab Release",
+			wantID:   "gitlab.example.com/release/o/r",
+			wantName: "test",
+		},
+		"Gitea": {
+			pkg:      Gitea{Name: "test", Host: "codeberg.org", Owner: "o", Repo: "r"},
+			wantType: "Gitea",
+			wantID:   "codeberg.org/o/r",
+			wantName: "test",
+		},
+		"Gitea with Release": {
+			pkg: Gitea{
+				Name:    "test",
+				Host:    "http://localhost:3000",
+				Owner:   "o",
+				Repo:    "r",
+				Release: &GitHubRelease{Name: "r", Tag: "v1.0"},
+			},
+			wantType: "Gitea Release",
+			wantID:   "localhost:3000/release/o/r",
+			wantName: "test",
+		},
+		"Git": {
+			pkg:      Git{Name: "test", URL: "git@git.example.com:o/r.git"},
+			wantType: "Git",
+			wantID:   "git.example.com/o/r",
+			wantName: "test",
+		},
 		"Gist": {
 			pkg:      Gist{Name: "test", Owner: "owner", ID: "abc123"},
 			wantType: "Gist",
@@ -110,3 +170,49 @@ func TestGetResource_GitHubBasicNoVersion(t *testing.T) {
 		t.Errorf("Version = %q, want empty string for non-release GitHub package", got.Version)
 	}
 }
+
+func TestLockEntry(t *testing.T) {
+	t.Setenv("HOME", "/test/home")
+
+	tests := map[string]struct {
+		pkg       Package
+		installed state.Resource
+		want      lock.Package
+		ok        bool
+	}{
+		"release": {
+			pkg:       GitHub{Name: "tool", Owner: "o", Repo: "r", Release: &GitHubRelease{Name: "r", Tag: "~1.0"}},
+			installed: state.Resource{Type: "GitHub Release", Version: "v1.0.2"},
+			want:      lock.Package{Type: "GitHub Release", Tag: "v1.0.2"},
+			ok:        true,
+		},
+		"http": {
+			pkg:       HTTP{Name: "tool", URL: "https://example.com/tool.tar.gz"},
+			installed: state.Resource{Type: "HTTP"},
+			want:      lock.Package{Type: "HTTP", URL: "https://example.com/tool.tar.gz"},
+			ok:        true,
+		},
+		"not cloned": {
+			pkg:       GitHub{Name: "repo", Owner: "o", Repo: "r"},
+			installed: state.Resource{Type: "GitHub", Home: t.TempDir()},
+			ok:        false,
+		},
+		"local": {
+			pkg:       Local{Name: "local", Directory: "/tmp"},
+			installed: state.Resource{Type: "Local"},
+			ok:        false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := LockEntry(context.Background(), tt.pkg, tt.installed)
+			if ok != tt.ok {
+				t.Fatalf("LockEntry() ok = %v, want %v", ok, tt.ok)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("LockEntry() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/babarot/afx/internal/runner"
+)
+
+// Retry represents a policy to retry installation of a package failed with
+// a transient error, e.g. HTTP 5xx/429 or a network error of git
+type Retry struct {
+	// Attempts is the maximum number of attempts including the first one
+	Attempts int `yaml:"attempts" validate:"min=1"`
+	// Backoff is how long to wait before the first retry (e.g. 2s).
+	// It's doubled on each retry
+	Backoff time.Duration `yaml:"backoff" validate:"min=0"`
+}
+
+// policy converts Retry to the one of runner. No retry if it's nil
+func (r *Retry) policy() runner.Retry {
+	if r == nil {
+		return runner.Retry{}
+	}
+	return runner.Retry{Attempts: r.Attempts, Backoff: r.Backoff}
+}
@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"github.com/babarot/afx/internal/signature"
+)
+
+// Verify represents a signature to verify a downloaded file.
+// The file is never unarchived unless its signature is valid.
+//
+//	verify:
+//	  type: minisign
+//	  public-key: RWQ...
+//	  signature: tool.tar.gz.minisig # default: <file> + .minisig
+type Verify struct {
+	Type      string `yaml:"type" validate:"required,oneof=minisign cosign gpg"`
+	Signature string `yaml:"signature"`
+	PublicKey string `yaml:"public-key"`
+
+	// for cosign keyless signing
+	Identity string `yaml:"identity"`
+	Issuer   string `yaml:"issuer"`
+	Root     string `yaml:"root"`
+}
+
+// Verifier returns the signature verifier of the given type.
+func (v Verify) Verifier() (signature.Verifier, error) {
+	return signature.New(v.Type, signature.Options{
+		PublicKey: v.PublicKey,
+		Identity:  v.Identity,
+		Issuer:    v.Issuer,
+		Root:      v.Root,
+	})
+}
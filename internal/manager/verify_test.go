@@ -0,0 +1,144 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"       //nolint:staticcheck // only used to sign test fixtures
+	"golang.org/x/crypto/openpgp/armor" //nolint:staticcheck // only used to sign test fixtures
+)
+
+func TestVerify_UnmarshalYAML(t *testing.T) {
+	tests := map[string]struct {
+		yaml    string
+		want    string
+		wantErr bool
+	}{
+		"minisign": {
+			yaml: "verify:\n      type: minisign\n      public-key: RWQ",
+			want: "minisign",
+		},
+		"unknown type": {
+			yaml:    "verify:\n      type: signify\n      public-key: RWQ",
+			wantErr: true,
+		},
+		"no type": {
+			yaml:    "verify:\n      public-key: RWQ",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := `http:
+  - name: test-pkg
+    url: https://example.com/tool.tar.gz
+    ` + tt.yaml + `
+`
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Read(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := cfg.HTTP[0].Verify.Type; got != tt.want {
+				t.Errorf("Verify.Type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTP_call_verify(t *testing.T) {
+	entity, err := openpgp.NewEntity("afx", "", "afx@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var publicKey bytes.Buffer
+	w, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	artifact := "#!/bin/sh\necho tool\n"
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewBufferString(artifact), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/tool":
+			_, _ = io.WriteString(w, artifact)
+		case "/v1/tool.asc":
+			_, _ = w.Write(sig.Bytes())
+		case "/v1/other.asc":
+			var other bytes.Buffer
+			_ = openpgp.ArmoredDetachSign(&other, entity, bytes.NewBufferString("other"), nil)
+			_, _ = w.Write(other.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		signature string
+		wantErr   bool
+	}{
+		"default signature name": {
+			signature: "",
+		},
+		"relative signature path": {
+			signature: "tool.asc",
+		},
+		"signature of another file": {
+			signature: "other.asc",
+			wantErr:   true,
+		},
+		"signature not found": {
+			signature: "tool.sig",
+			wantErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("AFX_DATA_DIR", t.TempDir())
+			pkg := HTTP{
+				Name: "tool",
+				URL:  server.URL + "/v1/tool",
+				Verify: &Verify{
+					Type:      "gpg",
+					PublicKey: publicKey.String(),
+					Signature: tt.signature,
+				},
+			}
+			err := pkg.call(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, statErr := os.Stat(filepath.Join(pkg.GetHome(), "tool"))
+			if tt.wantErr && statErr == nil {
+				t.Error("call() should remove the file on invalid signature")
+			}
+			if !tt.wantErr && statErr != nil {
+				t.Errorf("call() should keep the file: %v", statErr)
+			}
+		})
+	}
+}
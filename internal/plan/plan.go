@@ -0,0 +1,281 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/babarot/afx/internal/state"
+)
+
+// version is a format version of the plan file
+const version = 1
+
+// Op represents an operation to converge a package to the config.
+type Op string
+
+const (
+	Install   Op = "install"
+	Reinstall Op = "reinstall"
+	Update    Op = "update"
+	Uninstall Op = "uninstall"
+)
+
+// ops is the order of operations in a plan
+var ops = []Op{Uninstall, Install, Reinstall, Update}
+
+func (o Op) symbol() string {
+	switch o {
+	case Install:
+		return color.GreenString("+")
+	case Reinstall:
+		return color.YellowString("-/+")
+	case Update:
+		return color.YellowString("~")
+	case Uninstall:
+		return color.RedString("-")
+	default:
+		return "?"
+	}
+}
+
+// Action is an operation planned for one package.
+type Action struct {
+	Op      Op       `json:"op"`
+	Name    string   `json:"name"`
+	ID      state.ID `json:"id"`
+	Type    string   `json:"type"`
+	Reasons []string `json:"reasons"`
+
+	// Resource is a desired resource in config,
+	// or a resource recorded in state file for uninstall.
+	Resource state.Resource `json:"resource"`
+}
+
+// Plan is a set of actions to converge installed packages to the config.
+type Plan struct {
+	Version int `json:"version"`
+	// State is a digest of state file when the plan was made.
+	// It's used to detect whether a saved plan is stale.
+	State   string   `json:"state"`
+	Actions []Action `json:"actions"`
+}
+
+// New makes a plan from the state and the resources desired by the config.
+// resolved are resources whose version constraint or channel resolves a newer
+// version than the installed one, which are updated as well as by afx update.
+func New(s *state.State, desired []state.Resource, resolved []state.Resource) *Plan {
+	wants := map[state.ID]state.Resource{}
+	for _, resource := range desired {
+		wants[resource.ID] = resource
+	}
+
+	p := &Plan{Version: version, State: Digest(s)}
+
+	for _, resource := range s.Deletions {
+		p.add(Uninstall, resource, "removed from config")
+	}
+
+	for _, resource := range s.Additions {
+		want, ok := wants[resource.ID]
+		if !ok {
+			want = resource
+		}
+		if _, installed := s.Resources[resource.ID]; !installed {
+			p.add(Install, want, "not installed yet")
+			continue
+		}
+		var reasons []string
+		for _, path := range resource.Missing() {
+			reasons = append(reasons, fmt.Sprintf("missing path: %s", path))
+		}
+		if len(reasons) == 0 {
+			reasons = append(reasons, "no paths recorded in state")
+		}
+		p.add(Reinstall, want, reasons...)
+	}
+
+	for _, resource := range s.Changes {
+		want, ok := wants[resource.ID]
+		if !ok || p.has(resource.ID) {
+			// to be reinstalled with the desired version
+			continue
+		}
+		p.add(Update, want, fmt.Sprintf("version: %s -> %s", resource.Version, want.Version))
+	}
+
+	for _, resource := range resolved {
+		if p.has(resource.ID) {
+			continue
+		}
+		installed := s.Resources[resource.ID]
+		p.add(Update, resource, fmt.Sprintf("version: %s -> %s (resolved)", installed.Version, resource.Version))
+	}
+
+	sort.SliceStable(p.Actions, func(i, j int) bool {
+		a, b := p.Actions[i], p.Actions[j]
+		if a.Op != b.Op {
+			return order(a.Op) < order(b.Op)
+		}
+		return a.Name < b.Name
+	})
+	return p
+}
+
+func order(op Op) int {
+	for i, o := range ops {
+		if o == op {
+			return i
+		}
+	}
+	return len(ops)
+}
+
+func (p *Plan) add(op Op, resource state.Resource, reasons ...string) {
+	p.Actions = append(p.Actions, Action{
+		Op:       op,
+		Name:     resource.Name,
+		ID:       resource.ID,
+		Type:     resource.Type,
+		Reasons:  reasons,
+		Resource: resource,
+	})
+}
+
+func (p *Plan) has(id state.ID) bool {
+	for _, action := range p.Actions {
+		if action.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty returns true if nothing needs to be done.
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// Filter returns actions of given operations.
+func (p *Plan) Filter(ops ...Op) []Action {
+	var actions []Action
+	for _, action := range p.Actions {
+		for _, op := range ops {
+			if action.Op == op {
+				actions = append(actions, action)
+			}
+		}
+	}
+	return actions
+}
+
+// Names returns package names in the plan.
+func (p *Plan) Names() []string {
+	var names []string
+	for _, action := range p.Actions {
+		names = append(names, action.Name)
+	}
+	return names
+}
+
+// Render writes the plan in human readable format.
+func (p *Plan) Render(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintln(w, "No changes. Installed packages match the config.")
+		return
+	}
+
+	fmt.Fprintln(w, "afx will perform the following actions:")
+	fmt.Fprintln(w)
+	for _, action := range p.Actions {
+		fmt.Fprintf(w, "  %s %s %s\n", action.Op.symbol(), color.New(color.Bold).Sprint(action.Name),
+			color.New(color.FgHiBlack).Sprintf("(%s)", action.Type))
+		for _, reason := range action.Reasons {
+			fmt.Fprintf(w, "      %s\n", reason)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Plan: %s\n", p.summary())
+}
+
+func (p *Plan) summary() string {
+	var counts []string
+	for _, op := range []Op{Install, Reinstall, Update, Uninstall} {
+		counts = append(counts, fmt.Sprintf("%d to %s", len(p.Filter(op)), op))
+	}
+	return strings.Join(counts, ", ") + "."
+}
+
+// Save writes the plan to a file.
+func (p *Plan) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Read reads a plan saved by Save.
+func Read(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("%s: failed to parse plan file: %w", path, err)
+	}
+	if p.Version != version {
+		return nil, fmt.Errorf("%s: unsupported plan file version: %d", path, p.Version)
+	}
+	return &p, nil
+}
+
+// ErrStale is returned when a saved plan doesn't match current state or config.
+var ErrStale = errors.New("saved plan is stale, please run `afx plan` again")
+
+// Validate checks if a saved plan can be applied as it is.
+// State file should be the same as when the plan was made, and
+// resources to be installed should be still desired by the config.
+func (p *Plan) Validate(s *state.State, desired []state.Resource) error {
+	if p.State != Digest(s) {
+		return fmt.Errorf("%w: state file has been changed", ErrStale)
+	}
+	wants := map[state.ID]state.Resource{}
+	for _, resource := range desired {
+		wants[resource.ID] = resource
+	}
+	for _, action := range p.Actions {
+		want, ok := wants[action.ID]
+		switch action.Op {
+		case Uninstall:
+			if ok {
+				return fmt.Errorf("%w: %s: added to config again", ErrStale, action.Name)
+			}
+		default:
+			if !ok {
+				return fmt.Errorf("%w: %s: not found in config", ErrStale, action.Name)
+			}
+			if want.Version != action.Resource.Version {
+				return fmt.Errorf("%w: %s: version has been changed to %s", ErrStale, action.Name, want.Version)
+			}
+		}
+	}
+	return nil
+}
+
+// Digest returns a digest of resources recorded in state file.
+func Digest(s *state.State) string {
+	// map keys are sorted by encoding/json
+	b, _ := json.Marshal(s.Resources)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
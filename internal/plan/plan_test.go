@@ -0,0 +1,183 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/babarot/afx/internal/state"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+	color.NoColor = true
+}
+
+type testPackage struct {
+	r state.Resource
+}
+
+func (p testPackage) GetResource() state.Resource {
+	return p.r
+}
+
+// openState writes installed resources to a state file and opens it with desired resources
+func openState(t *testing.T, installed, desired []state.Resource) *state.State {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	self := state.Self{Resources: map[state.ID]state.Resource{}}
+	for _, r := range installed {
+		self.Resources[r.ID] = r
+	}
+	b, err := json.Marshal(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resourcers := make([]state.Resourcer, len(desired))
+	for i, r := range desired {
+		resourcers[i] = testPackage{r: r}
+	}
+	s, err := state.Open(path, resourcers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	exist := filepath.Join(dir, "exist")
+	if err := os.WriteFile(exist, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	installed := []state.Resource{
+		{ID: "github.com/a/keep", Name: "a/keep", Type: "GitHub", Paths: []string{exist}},
+		{ID: "github.com/a/broken", Name: "a/broken", Type: "GitHub", Paths: []string{exist, missing}},
+		{ID: "github.com/release/a/tool", Name: "a/tool", Type: "GitHub Release", Version: "v1.0.0", Paths: []string{exist}},
+		{ID: "github.com/a/old", Name: "a/old", Type: "GitHub", Paths: []string{exist}},
+		{ID: "github.com/release/a/ranged", Name: "a/ranged", Type: "GitHub Release", Version: "v2.0.0", Paths: []string{exist}},
+	}
+	desired := []state.Resource{
+		{ID: "github.com/a/keep", Name: "a/keep", Type: "GitHub", Paths: []string{exist}},
+		{ID: "github.com/a/broken", Name: "a/broken", Type: "GitHub", Paths: []string{exist, missing}},
+		{ID: "github.com/release/a/tool", Name: "a/tool", Type: "GitHub Release", Version: "v1.1.0", Paths: []string{exist}},
+		{ID: "github.com/a/new", Name: "a/new", Type: "GitHub", Paths: []string{missing}},
+		{ID: "github.com/release/a/ranged", Name: "a/ranged", Type: "GitHub Release", Version: "v2.0.0", Paths: []string{exist}},
+	}
+	// a newer release matching the constraint has been published
+	resolved := []state.Resource{
+		{ID: "github.com/release/a/ranged", Name: "a/ranged", Type: "GitHub Release", Version: "v2.1.0", Paths: []string{exist}},
+	}
+
+	p := New(openState(t, installed, desired), desired, resolved)
+
+	type action struct {
+		Op      Op
+		Name    string
+		Reasons []string
+	}
+	var got []action
+	for _, a := range p.Actions {
+		got = append(got, action{a.Op, a.Name, a.Reasons})
+	}
+	want := []action{
+		{Uninstall, "a/old", []string{"removed from config"}},
+		{Install, "a/new", []string{"not installed yet"}},
+		{Reinstall, "a/broken", []string{"missing path: " + missing}},
+		{Update, "a/ranged", []string{"version: v2.0.0 -> v2.1.0 (resolved)"}},
+		{Update, "a/tool", []string{"version: v1.0.0 -> v1.1.0"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("New() mismatch (-want +got):\n%s", diff)
+	}
+
+	var buf bytes.Buffer
+	p.Render(&buf)
+	if !bytes.Contains(buf.Bytes(), []byte("Plan: 1 to install, 1 to reinstall, 2 to update, 1 to uninstall.")) {
+		t.Errorf("Render() unexpected summary:\n%s", buf.String())
+	}
+}
+
+func TestNew_noChanges(t *testing.T) {
+	resources := []state.Resource{
+		{ID: "github.com/a/keep", Name: "a/keep", Type: "GitHub", Paths: []string{t.TempDir()}},
+	}
+	p := New(openState(t, resources, resources), resources, nil)
+	if !p.Empty() {
+		t.Errorf("New() should be empty: %#v", p.Actions)
+	}
+}
+
+func TestPlan_SaveRead(t *testing.T) {
+	desired := []state.Resource{
+		{ID: "github.com/a/new", Name: "a/new", Type: "GitHub", Paths: []string{"/nonexistent"}},
+	}
+	s := openState(t, nil, desired)
+	p := New(s, desired, nil)
+
+	path := filepath.Join(t.TempDir(), "afx.plan")
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if diff := cmp.Diff(p, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+	if err := got.Validate(s, desired); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+}
+
+func TestPlan_Validate_stale(t *testing.T) {
+	tool := state.Resource{ID: "github.com/release/a/tool", Name: "a/tool", Type: "GitHub Release", Version: "v1.1.0"}
+
+	tests := map[string]func(s *state.State) []state.Resource{
+		"version changed in config": func(s *state.State) []state.Resource {
+			changed := tool
+			changed.Version = "v1.2.0"
+			return []state.Resource{changed}
+		},
+		"removed from config": func(s *state.State) []state.Resource {
+			return nil
+		},
+		"state changed": func(s *state.State) []state.Resource {
+			if err := s.Add(tool); err != nil {
+				t.Fatal(err)
+			}
+			return []state.Resource{tool}
+		},
+	}
+
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			desired := []state.Resource{tool}
+			s := openState(t, nil, desired)
+			p := New(s, desired, nil)
+			err := p.Validate(s, change(s))
+			if !errors.Is(err, ErrStale) {
+				t.Errorf("Validate() error = %v, want ErrStale", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kinds of events
+const (
+	EventStart    = "start"
+	EventProgress = "progress"
+	EventDone     = "done"
+	EventError    = "error"
+)
+
+// Event is what happened to a package, written as a line of JSON (NDJSON)
+type Event struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type,omitempty"`
+	OldVersion string    `json:"old_version,omitempty"`
+	NewVersion string    `json:"new_version,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Skipped    bool      `json:"skipped,omitempty"`
+	// Attempt is which attempt it is, set if the package is retried
+	Attempt int `json:"attempt,omitempty"`
+	// Duration is how long it took in seconds, set in done and error events
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Info describes a package in its events.
+// Fields set in Status take precedence over them
+type Info struct {
+	Type       string
+	OldVersion string
+	NewVersion string
+}
+
+// Events writes events of packages as NDJSON instead of printing progress
+type Events struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	infos map[string]Info
+	now   func() time.Time
+}
+
+// NewEvents creates Events written to w. infos are keyed by package names
+func NewEvents(w io.Writer, infos map[string]Info) *Events {
+	if infos == nil {
+		infos = map[string]Info{}
+	}
+	return &Events{enc: json.NewEncoder(w), infos: infos, now: time.Now}
+}
+
+// Start writes a start event of a package and returns when it started
+func (e *Events) Start(name string) time.Time {
+	event := e.event(EventStart, Status{Name: name})
+	e.write(event)
+	return event.Time
+}
+
+// Progress writes a progress event from a status which is not done yet
+func (e *Events) Progress(s Status) {
+	e.write(e.event(EventProgress, s))
+}
+
+// Done writes a done event, or an error event if err is not nil or the status has failed
+func (e *Events) Done(s Status, err error, started time.Time) {
+	kind := EventDone
+	if err != nil || s.Err {
+		kind = EventError
+	}
+	event := e.event(kind, s)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if !started.IsZero() {
+		event.Duration = event.Time.Sub(started).Seconds()
+	}
+	e.write(event)
+}
+
+func (e *Events) event(kind string, s Status) Event {
+	info := e.infos[s.Name]
+	event := Event{
+		Event:      kind,
+		Time:       e.now(),
+		Name:       s.Name,
+		Type:       info.Type,
+		OldVersion: info.OldVersion,
+		NewVersion: info.NewVersion,
+		Message:    s.Message,
+		Skipped:    s.Skipped,
+		Attempt:    s.Attempt,
+	}
+	if s.Type != "" {
+		event.Type = s.Type
+	}
+	if s.OldVersion != "" {
+		event.OldVersion = s.OldVersion
+	}
+	if s.NewVersion != "" {
+		event.NewVersion = s.NewVersion
+	}
+	return event
+}
+
+func (e *Events) write(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_ = e.enc.Encode(event)
+}
+
+// run runs a task, writing its statuses as progress events.
+// It returns the last status sent by the task
+func (e *Events) run(ctx context.Context, name string, fn TaskFunc) (Status, error) {
+	ch := make(chan Status)
+	done := make(chan struct{})
+	last := Status{Name: name}
+	go func() {
+		defer close(done)
+		for s := range ch {
+			if !s.Done {
+				e.Progress(s)
+			}
+			last = s
+		}
+	}()
+	err := fn(ctx, ch)
+	close(ch)
+	<-done
+	return last, err
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestExecute_events(t *testing.T) {
+	pkgs := []Package{
+		testPackage{name: "base"},
+		testPackage{name: "lib", deps: []string{"base"}},
+		testPackage{name: "tool"},
+	}
+	tasks := map[string]TaskFunc{
+		"base": func(ctx context.Context, completion chan<- Status) error {
+			completion <- Status{Name: "base", Message: "downloading"}
+			completion <- Status{Name: "base", Done: true, Err: true}
+			return errors.New("base: failed")
+		},
+		"tool": func(ctx context.Context, completion chan<- Status) error {
+			completion <- Status{Name: "tool", Done: true, Message: "new!", NewVersion: "v1.1.0"}
+			return nil
+		},
+	}
+
+	var buf bytes.Buffer
+	events := NewEvents(&buf, map[string]Info{
+		"tool": {Type: "GitHub Release", OldVersion: "v1.0.0", NewVersion: "v1.0.0"},
+	})
+	err := Execute(pkgs, func(pkg Package) TaskFunc {
+		return tasks[pkg.GetName()]
+	}, WithEvents(events))
+	if err == nil {
+		t.Fatal("Execute() expected error")
+	}
+
+	var got []Event
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var event Event
+		if err := dec.Decode(&event); err != nil {
+			t.Fatalf("events should be NDJSON: %v", err)
+		}
+		got = append(got, event)
+	}
+
+	want := []Event{
+		{Event: EventStart, Name: "base"},
+		{Event: EventProgress, Name: "base", Message: "downloading"},
+		{Event: EventError, Name: "base", Error: "base: failed"},
+		{Event: EventError, Name: "lib", Message: "(skipped: base failed)", Skipped: true, Error: "lib: skipped because base failed"},
+		{Event: EventStart, Name: "tool", Type: "GitHub Release", OldVersion: "v1.0.0", NewVersion: "v1.0.0"},
+		{Event: EventDone, Name: "tool", Type: "GitHub Release", OldVersion: "v1.0.0", NewVersion: "v1.1.0", Message: "new!"},
+	}
+	// packages run in parallel, so events are compared per package
+	sort.SliceStable(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+	opts := cmpopts.IgnoreFields(Event{}, "Time", "Duration")
+	if diff := cmp.Diff(want, got, opts); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+}
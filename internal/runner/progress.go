@@ -24,6 +24,19 @@ type Status struct {
 	Err     bool
 	Message string
 	NoColor bool
+	// Skipped means the package was not run because its dependency failed.
+	Skipped bool
+
+	// Type, OldVersion and NewVersion are written in events if set.
+	// For example, check tells the version found to be the latest
+	Type       string
+	OldVersion string
+	NewVersion string
+
+	// Attempt is which attempt of Attempts the status is from.
+	// They're set only if the package is retried on failure
+	Attempt  int
+	Attempts int
 }
 
 // NewProgress creates a Progress tracker for the given package names.
@@ -44,6 +57,7 @@ func NewProgress(names []string) Progress {
 func (p Progress) Print(completion chan Status) {
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
 	white := color.New(color.FgWhite).SprintFunc()
 
 	fadedOutput := color.New(color.FgCyan)
@@ -57,11 +71,22 @@ func (p Progress) Print(completion chan Status) {
 		}
 
 		sign := green("✔")
-		if s.Err {
+		switch {
+		case s.Skipped:
+			sign = yellow("-")
+		case s.Err && !s.Done:
+			// going to be retried
+			sign = yellow("↻")
+		case s.Err:
 			sign = red("✖")
 		}
 
-		fmt.Println(sign, name, s.Message)
+		message := s.Message
+		if s.Attempt > 1 {
+			message = strings.TrimSpace(fmt.Sprintf("%s [attempt %d/%d]", message, s.Attempt, s.Attempts))
+		}
+
+		fmt.Println(sign, name, message)
 
 		p.Status[s.Name] = s
 		count, repos := countRemaining(p.Status)
@@ -0,0 +1,122 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+// DefaultConcurrency is the number of packages run at the same time by default
+const DefaultConcurrency = 16
+
+// DefaultBackoff is how long to wait before the first retry by default
+const DefaultBackoff = time.Second
+
+// Retry is a policy to retry a task failed with a transient error
+// (e.g. HTTP 5xx/429 or a network error of git)
+type Retry struct {
+	// Attempts is the maximum number of attempts including the first one
+	Attempts int
+	// Backoff is how long to wait before the first retry.
+	// It's doubled on each retry
+	Backoff time.Duration
+}
+
+// Limited is implemented by packages which limit how long their task
+// runs and how many times it's tried
+type Limited interface {
+	GetTimeout() time.Duration
+	GetRetry() Retry
+}
+
+// limit applies the timeout and the retry policy of a package to its task.
+// The timeout covers all the attempts
+func limit(pkg Package, fn TaskFunc) TaskFunc {
+	l, ok := pkg.(Limited)
+	if !ok {
+		return fn
+	}
+	return withTimeout(withRetry(pkg.GetName(), fn, l.GetRetry()), l.GetTimeout())
+}
+
+// withTimeout makes fn fail after timeout. It does nothing if timeout is zero
+func withTimeout(fn TaskFunc, timeout time.Duration) TaskFunc {
+	if timeout <= 0 {
+		return fn
+	}
+	return func(ctx context.Context, completion chan<- Status) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err := fn(ctx, completion)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w: timed out after %s", err, timeout)
+		}
+		return err
+	}
+}
+
+// withRetry retries fn while it fails with a transient error, up to the
+// attempts of a given policy. Statuses sent by fn tell which attempt they're
+// from, and a failed status of an attempt going to be retried is replaced
+// with the one telling when it's retried
+func withRetry(name string, fn TaskFunc, retry Retry) TaskFunc {
+	if retry.Attempts <= 1 {
+		return fn
+	}
+	backoff := retry.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	return func(ctx context.Context, completion chan<- Status) error {
+		for attempt := 1; ; attempt++ {
+			done, err := try(ctx, fn, completion, attempt, retry.Attempts)
+			if err == nil || attempt == retry.Attempts || !transient.Is(err) {
+				if done != nil {
+					completion <- *done
+				}
+				return err
+			}
+
+			log.Printf("[DEBUG] %s: retrying in %s (attempt %d/%d): %v", name, backoff, attempt+1, retry.Attempts, err)
+			completion <- Status{
+				Name:     name,
+				Err:      true,
+				Message:  fmt.Sprintf("(retrying in %s: %v)", backoff, err),
+				Attempt:  attempt + 1,
+				Attempts: retry.Attempts,
+			}
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return err
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// try runs fn once, passing through its statuses with the attempt count
+// but the one telling it's done. The done status is returned instead,
+// or nil if fn has not sent it
+func try(ctx context.Context, fn TaskFunc, completion chan<- Status, attempt, attempts int) (*Status, error) {
+	ch := make(chan Status)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- fn(ctx, ch)
+		close(ch)
+	}()
+
+	var done *Status
+	for s := range ch {
+		s.Attempt, s.Attempts = attempt, attempts
+		if s.Done {
+			done = &s
+			continue
+		}
+		completion <- s
+	}
+	return done, <-errc
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+type limitedPackage struct {
+	testPackage
+	timeout time.Duration
+	retry   Retry
+}
+
+func (p limitedPackage) GetTimeout() time.Duration { return p.timeout }
+func (p limitedPackage) GetRetry() Retry           { return p.retry }
+
+// flakyServer responds with code for the first failures requests, then 200
+func flakyServer(t *testing.T, failures int, code int) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(atomic.AddInt32(&requests, 1)) <= failures {
+			w.WriteHeader(code)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// download is a task getting a file from url like installation from releases
+func download(url string) TaskFunc {
+	return func(ctx context.Context, completion chan<- Status) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			completion <- Status{Name: "pkg", Done: true, Err: true}
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			completion <- Status{Name: "pkg", Done: true, Err: true}
+			return transient.FromStatus(resp.StatusCode, fmt.Errorf("failed to download: %s", resp.Status))
+		}
+		completion <- Status{Name: "pkg", Done: true}
+		return nil
+	}
+}
+
+func TestExecute_retry(t *testing.T) {
+	tests := map[string]struct {
+		failures     int
+		code         int
+		retry        Retry
+		wantRequests int32
+		wantErr      bool
+	}{
+		"recovers from 503": {
+			failures:     2,
+			code:         http.StatusServiceUnavailable,
+			retry:        Retry{Attempts: 3, Backoff: time.Millisecond},
+			wantRequests: 3,
+		},
+		"recovers from 429": {
+			failures:     1,
+			code:         http.StatusTooManyRequests,
+			retry:        Retry{Attempts: 3, Backoff: time.Millisecond},
+			wantRequests: 2,
+		},
+		"gives up": {
+			failures:     5,
+			code:         http.StatusBadGateway,
+			retry:        Retry{Attempts: 3, Backoff: time.Millisecond},
+			wantRequests: 3,
+			wantErr:      true,
+		},
+		"404 is not retried": {
+			failures:     1,
+			code:         http.StatusNotFound,
+			retry:        Retry{Attempts: 3, Backoff: time.Millisecond},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		"no retry": {
+			failures:     1,
+			code:         http.StatusServiceUnavailable,
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, requests := flakyServer(t, tt.failures, tt.code)
+			pkg := limitedPackage{testPackage: testPackage{name: "pkg"}, retry: tt.retry}
+
+			var buf strings.Builder
+			events := NewEvents(&buf, nil)
+			err := Execute([]Package{pkg}, func(Package) TaskFunc {
+				return download(server.URL)
+			}, WithEvents(events))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(requests); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			if tt.wantRequests > 1 && !strings.Contains(buf.String(), fmt.Sprintf(`"attempt":%d`, tt.wantRequests)) {
+				t.Errorf("events should tell the attempt %d:\n%s", tt.wantRequests, buf.String())
+			}
+		})
+	}
+}
+
+func TestExecute_timeout(t *testing.T) {
+	pkg := limitedPackage{testPackage: testPackage{name: "pkg"}, timeout: 10 * time.Millisecond}
+	err := Execute([]Package{pkg}, func(Package) TaskFunc {
+		return func(ctx context.Context, completion chan<- Status) error {
+			<-ctx.Done()
+			completion <- Status{Name: "pkg", Done: true, Err: true}
+			return ctx.Err()
+		}
+	}, WithEvents(NewEvents(&strings.Builder{}, nil)))
+	if err == nil || !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Errorf("Execute() error = %v, want timed out", err)
+	}
+}
+
+func TestExecute_concurrency(t *testing.T) {
+	var pkgs []Package
+	for i := range 6 {
+		pkgs = append(pkgs, testPackage{name: fmt.Sprintf("pkg%d", i)})
+	}
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	err := Execute(pkgs, func(pkg Package) TaskFunc {
+		return func(ctx context.Context, completion chan<- Status) error {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			completion <- Status{Name: pkg.GetName(), Done: true}
+			return nil
+		}
+	}, WithConcurrency(2), WithEvents(NewEvents(&strings.Builder{}, nil)))
+	if err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	if peak > 2 {
+		t.Errorf("%d packages ran at the same time, want 2 at most", peak)
+	}
+}
@@ -3,15 +3,21 @@ package runner
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"os"
 	"os/signal"
+	"time"
 
 	"golang.org/x/sync/errgroup"
+
+	"github.com/babarot/afx/internal/dependency"
 )
 
 // Package is the minimal interface needed by the runner.
 type Package interface {
 	GetName() string
+	GetDependsOn() []string
 }
 
 // TaskFunc is the function executed for each package in parallel.
@@ -24,9 +30,69 @@ type Result struct {
 	Error error
 }
 
+// SkippedError is returned for a package which was not run
+// because one of its dependencies failed.
+type SkippedError struct {
+	Name       string
+	Dependency string
+}
+
+func (e *SkippedError) Error() string {
+	return fmt.Sprintf("%s: skipped because %s failed", e.Name, e.Dependency)
+}
+
+// node is a package scheduled in the dependency graph.
+// done is closed after the package has finished, then ok tells if it succeeded.
+type node struct {
+	pkg  Package
+	deps []string
+	done chan struct{}
+	ok   bool
+}
+
+// Option configures Execute
+type Option func(o *options)
+
+type options struct {
+	events      *Events
+	concurrency int
+}
+
+// WithEvents makes Execute write events instead of printing progress
+func WithEvents(events *Events) Option {
+	return func(o *options) {
+		o.events = events
+	}
+}
+
+// WithConcurrency sets the number of packages run at the same time.
+// DefaultConcurrency is used if n is not positive
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
 // Execute runs taskFn for each package in parallel with progress reporting.
+// A package starts only after all of its dependencies have succeeded, and
+// it's skipped if any of them failed. Dependencies not included in pkgs
+// are regarded as satisfied. Tasks of packages implementing Limited are
+// canceled after their timeout, and retried on transient errors.
 // It handles signal interruption, concurrency limiting, and error aggregation.
-func Execute(pkgs []Package, taskFn func(pkg Package) TaskFunc) error {
+func Execute(pkgs []Package, taskFn func(pkg Package) TaskFunc, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = DefaultConcurrency
+	}
+
+	nodes, err := schedule(pkgs)
+	if err != nil {
+		return err
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
@@ -36,20 +102,58 @@ func Execute(pkgs []Package, taskFn func(pkg Package) TaskFunc) error {
 	}
 	progress := NewProgress(names)
 	completion := make(chan Status)
-	limit := make(chan struct{}, 16)
+	slots := make(chan struct{}, o.concurrency)
 	results := make(chan Result)
 
-	go func() {
-		progress.Print(completion)
-	}()
+	if o.events == nil {
+		go func() {
+			progress.Print(completion)
+		}()
+	}
 
 	eg := errgroup.Group{}
 	for _, pkg := range pkgs {
-		fn := taskFn(pkg)
+		n := nodes[pkg.GetName()]
+		fn := limit(pkg, taskFn(pkg))
 		eg.Go(func() error {
-			limit <- struct{}{}
-			defer func() { <-limit }()
-			err := fn(ctx, completion)
+			defer close(n.done)
+
+			var err error
+			failed, canceled := wait(ctx, n, nodes)
+			switch {
+			case canceled:
+				return nil
+			case failed != "":
+				err = &SkippedError{Name: pkg.GetName(), Dependency: failed}
+				log.Printf("[DEBUG] %v", err)
+				status := Status{
+					Name:    pkg.GetName(),
+					Done:    true,
+					Err:     true,
+					Skipped: true,
+					Message: fmt.Sprintf("(skipped: %s failed)", failed),
+				}
+				if o.events != nil {
+					o.events.Done(status, err, time.Time{})
+					break
+				}
+				completion <- status
+			case o.events != nil:
+				slots <- struct{}{}
+				started := o.events.Start(pkg.GetName())
+				var status Status
+				status, err = o.events.run(ctx, pkg.GetName(), fn)
+				<-slots
+				o.events.Done(status, err, started)
+			default:
+				// Take a slot only when ready to run so that packages
+				// waiting for dependencies don't block the others.
+				slots <- struct{}{}
+				err = fn(ctx, completion)
+				<-slots
+			}
+			n.ok = err == nil
+
 			select {
 			case results <- Result{Name: pkg.GetName(), Error: err}:
 			case <-ctx.Done():
@@ -74,3 +178,45 @@ func Execute(pkgs []Package, taskFn func(pkg Package) TaskFunc) error {
 
 	return errors.Join(errs...)
 }
+
+// schedule builds the dependency graph of given packages.
+// It returns an error if the graph has a circular dependency.
+func schedule(pkgs []Package) (map[string]*node, error) {
+	nodes := make(map[string]*node, len(pkgs))
+	for _, pkg := range pkgs {
+		nodes[pkg.GetName()] = &node{pkg: pkg, done: make(chan struct{})}
+	}
+
+	var graph dependency.Graph
+	for name, n := range nodes {
+		for _, dep := range n.pkg.GetDependsOn() {
+			if _, ok := nodes[dep]; !ok {
+				log.Printf("[DEBUG] %s: %s is not a target, regarded as satisfied", name, dep)
+				continue
+			}
+			n.deps = append(n.deps, dep)
+		}
+		graph = append(graph, dependency.NewNode(name, n.deps...))
+	}
+
+	if _, err := dependency.Resolve(graph); err != nil {
+		return nil, fmt.Errorf("%w: failed to resolve dependency graph", err)
+	}
+	return nodes, nil
+}
+
+// wait blocks until all dependencies of n have finished.
+// It returns the name of the first failed dependency, if any.
+func wait(ctx context.Context, n *node, nodes map[string]*node) (string, bool) {
+	for _, dep := range n.deps {
+		select {
+		case <-nodes[dep].done:
+			if !nodes[dep].ok {
+				return dep, false
+			}
+		case <-ctx.Done():
+			return "", true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+type testPackage struct {
+	name string
+	deps []string
+}
+
+func (p testPackage) GetName() string        { return p.name }
+func (p testPackage) GetDependsOn() []string { return p.deps }
+
+// recorder records the order in which packages were run
+type recorder struct {
+	mu   sync.Mutex
+	runs []string
+}
+
+func (r *recorder) task(fail map[string]bool) func(Package) TaskFunc {
+	return func(pkg Package) TaskFunc {
+		return func(ctx context.Context, completion chan<- Status) error {
+			// give a chance to run dependents too early if not scheduled
+			time.Sleep(10 * time.Millisecond)
+			r.mu.Lock()
+			r.runs = append(r.runs, pkg.GetName())
+			r.mu.Unlock()
+			if fail[pkg.GetName()] {
+				completion <- Status{Name: pkg.GetName(), Done: true, Err: true}
+				return errors.New(pkg.GetName() + ": failed")
+			}
+			completion <- Status{Name: pkg.GetName(), Done: true}
+			return nil
+		}
+	}
+}
+
+func (r *recorder) index(name string) int {
+	for i, run := range r.runs {
+		if run == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestExecute_order(t *testing.T) {
+	pkgs := []Package{
+		testPackage{name: "app", deps: []string{"lib", "tool"}},
+		testPackage{name: "lib", deps: []string{"base"}},
+		testPackage{name: "tool"},
+		testPackage{name: "base"},
+	}
+
+	var r recorder
+	if err := Execute(pkgs, r.task(nil)); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+
+	before := [][2]string{{"base", "lib"}, {"lib", "app"}, {"tool", "app"}}
+	for _, b := range before {
+		if r.index(b[0]) > r.index(b[1]) {
+			t.Errorf("%s should run before %s: %v", b[0], b[1], r.runs)
+		}
+	}
+}
+
+func TestExecute_skipDependents(t *testing.T) {
+	pkgs := []Package{
+		testPackage{name: "base"},
+		testPackage{name: "lib", deps: []string{"base"}},
+		testPackage{name: "app", deps: []string{"lib"}},
+		testPackage{name: "other"},
+	}
+
+	var r recorder
+	err := Execute(pkgs, r.task(map[string]bool{"base": true}))
+	if err == nil {
+		t.Fatal("Execute() expected error")
+	}
+
+	sort.Strings(r.runs)
+	if diff := cmp.Diff([]string{"base", "other"}, r.runs); diff != "" {
+		t.Errorf("runs mismatch (-want +got):\n%s", diff)
+	}
+
+	var skipped []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var se *SkippedError
+		if errors.As(e, &se) {
+			skipped = append(skipped, se.Name)
+		}
+	}
+	sort.Strings(skipped)
+	if diff := cmp.Diff([]string{"app", "lib"}, skipped); diff != "" {
+		t.Errorf("skipped mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExecute_dependencyNotInTargets(t *testing.T) {
+	pkgs := []Package{
+		testPackage{name: "app", deps: []string{"installed"}},
+	}
+
+	var r recorder
+	if err := Execute(pkgs, r.task(nil)); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"app"}, r.runs); diff != "" {
+		t.Errorf("runs mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExecute_circular(t *testing.T) {
+	pkgs := []Package{
+		testPackage{name: "a", deps: []string{"b"}},
+		testPackage{name: "b", deps: []string{"a"}},
+	}
+
+	var r recorder
+	if err := Execute(pkgs, r.task(nil)); err == nil {
+		t.Fatal("Execute() expected error for circular dependency")
+	}
+	if len(r.runs) > 0 {
+		t.Errorf("no package should run: %v", r.runs)
+	}
+}
@@ -0,0 +1,465 @@
+package scaffold
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/babarot/afx/internal/cache"
+	"github.com/babarot/afx/internal/git"
+	"github.com/babarot/afx/internal/github"
+)
+
+// Option configures how to guess a package entry
+type Option func(*guesser)
+
+type guesser struct {
+	name       string
+	githubHost string
+	cache      *cache.Cache
+}
+
+// WithName sets a package name instead of the guessed one
+func WithName(name string) Option {
+	return func(g *guesser) {
+		g.name = name
+	}
+}
+
+// WithGitHubHost sets a default host of GitHub packages in config
+func WithGitHubHost(host string) Option {
+	return func(g *guesser) {
+		g.githubHost = host
+	}
+}
+
+// WithCache downloads release assets and HTTP files through the cache
+func WithCache(c *cache.Cache) Option {
+	return func(g *guesser) {
+		g.cache = c
+	}
+}
+
+// Guess looks into a target and scaffolds a package entry for it.
+//
+// GitHub repositories having assets for the platform in the latest release
+// are regarded as release binaries, and others are cloned to find plugins or
+// commands in them. Release assets and HTTP files are downloaded to propose
+// links to executables in them.
+func Guess(ctx context.Context, t Target, opts ...Option) (Entry, error) {
+	g := guesser{}
+	for _, o := range opts {
+		o(&g)
+	}
+
+	workdir, err := os.MkdirTemp("", "afx-add")
+	if err != nil {
+		return Entry{}, err
+	}
+	defer os.RemoveAll(workdir)
+
+	switch t.Type {
+	case GitHub:
+		return g.github(ctx, t, workdir)
+	case Gist:
+		return g.gist(ctx, t, workdir)
+	case HTTP:
+		return g.http(ctx, t, workdir)
+	default:
+		return Entry{}, fmt.Errorf("%s: unsupported type", t.Type)
+	}
+}
+
+func (g guesser) nameOr(name string) string {
+	if g.name != "" {
+		return g.name
+	}
+	return name
+}
+
+func (g guesser) github(ctx context.Context, t Target, workdir string) (Entry, error) {
+	host := t.Host
+	if host == "" {
+		host = g.githubHost
+	}
+
+	fields := yaml.MapSlice{{Key: "name", Value: g.nameOr(t.Owner + "/" + t.Repo)}}
+	if t.Host != "" && !isGitHubHost(t.Host, g.githubHost) {
+		fields = append(fields, yaml.MapItem{Key: "host", Value: t.Host})
+	}
+	fields = append(fields,
+		yaml.MapItem{Key: "owner", Value: t.Owner},
+		yaml.MapItem{Key: "repo", Value: t.Repo},
+	)
+
+	release, err := github.NewRelease(ctx, host, t.Owner, t.Repo, "latest",
+		github.WithWorkdir(workdir),
+		github.WithMatcher(github.Matcher{GOOS: runtimeOS, GOARCH: runtimeArch}),
+		github.WithCache(g.cache),
+	)
+	if err == nil {
+		block, err := g.release(ctx, release, workdir)
+		if err == nil {
+			return Entry{Type: GitHub, Fields: append(fields, block...)}, nil
+		}
+		log.Printf("[WARN] %s/%s: release is not installable: %v", t.Owner, t.Repo, err)
+	} else {
+		log.Printf("[DEBUG] %s/%s: no releases: %v", t.Owner, t.Repo, err)
+	}
+
+	base := host
+	if base == "" {
+		base = github.DefaultHost
+	}
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	url := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(base, "/"), t.Owner, t.Repo)
+	dir := filepath.Join(workdir, t.Repo)
+	if err := clone(ctx, url, dir); err != nil {
+		return Entry{}, fmt.Errorf("%s/%s: %w", t.Owner, t.Repo, err)
+	}
+	if key, value := guessBlock(dir); key != "" {
+		fields = append(fields, yaml.MapItem{Key: key, Value: value})
+	} else {
+		log.Printf("[WARN] %s/%s: neither plugins nor commands are found", t.Owner, t.Repo)
+	}
+	return Entry{Type: GitHub, Fields: fields}, nil
+}
+
+// release downloads the asset for the platform and returns
+// a release block and a command block linking executables in it
+func (g guesser) release(ctx context.Context, release *github.Release, workdir string) (yaml.MapSlice, error) {
+	if _, err := release.Select(); err != nil {
+		return nil, err
+	}
+	asset, err := release.Download(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := release.Unarchive(asset); err != nil {
+		return nil, err
+	}
+
+	filename, replacements := assetTemplate(asset.Name, release.Tag, release.Assets)
+	spec := yaml.MapSlice{{Key: "filename", Value: filename}}
+	if len(replacements) > 0 {
+		spec = append(spec, yaml.MapItem{Key: "replacements", Value: replacements})
+	}
+
+	links := executables(workdir, true)
+	if len(links) == 0 {
+		log.Printf("[WARN] %s: no executables found in %s", release.Name, asset.Name)
+		links = []string{release.Name}
+	}
+
+	return yaml.MapSlice{
+		{Key: "release", Value: yaml.MapSlice{
+			{Key: "name", Value: release.Name},
+			{Key: "tag", Value: release.Tag},
+			{Key: "asset", Value: spec},
+		}},
+		{Key: "command", Value: yaml.MapSlice{
+			{Key: "link", Value: linksOf(links, "")},
+		}},
+	}, nil
+}
+
+func (g guesser) gist(ctx context.Context, t Target, workdir string) (Entry, error) {
+	url := fmt.Sprintf("https://gist.%s/%s/%s", github.DefaultHost, t.Owner, t.Repo)
+	if err := clone(ctx, url, workdir); err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", t.Repo, err)
+	}
+
+	key, value := guessBlock(workdir)
+	name := t.Repo
+	var files []string
+	switch value := value.(type) {
+	case pluginBlock:
+		files = value.Sources
+	case commandBlock:
+		for _, link := range value.Link {
+			files = append(files, link.From)
+		}
+	}
+	if len(files) == 1 {
+		// named after the only file in the gist
+		name = strings.TrimSuffix(path.Base(files[0]), path.Ext(files[0]))
+	}
+
+	fields := yaml.MapSlice{
+		{Key: "name", Value: g.nameOr(name)},
+		{Key: "owner", Value: t.Owner},
+		{Key: "id", Value: t.Repo},
+	}
+	if key != "" {
+		fields = append(fields, yaml.MapItem{Key: key, Value: value})
+	} else {
+		log.Printf("[WARN] %s: neither plugins nor commands are found", t.Repo)
+	}
+	return Entry{Type: Gist, Fields: fields}, nil
+}
+
+func (g guesser) http(ctx context.Context, t Target, workdir string) (Entry, error) {
+	dir, base := path.Split(t.URL)
+	name := g.nameOr(trimArchiveExt(base))
+
+	// the file is downloaded in the same way as release assets
+	release, err := github.NewReleaseWithAssets(base, "",
+		github.Assets{{Name: base, URL: t.URL}},
+		github.WithWorkdir(workdir),
+		github.WithOverwrite(),
+		github.WithCache(g.cache),
+	)
+	if err != nil {
+		return Entry{}, err
+	}
+	asset, err := release.Download(ctx)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := release.Unarchive(asset); err != nil {
+		return Entry{}, err
+	}
+
+	tmpl, replacements := platformTemplate(base)
+	fields := yaml.MapSlice{
+		{Key: "name", Value: name},
+		{Key: "url", Value: dir + tmpl},
+	}
+	if len(replacements) > 0 {
+		fields = append(fields, yaml.MapItem{Key: "templates", Value: yaml.MapSlice{
+			{Key: "replacements", Value: replacements},
+		}})
+	}
+
+	links := executables(workdir, true)
+	to := ""
+	if slices.Equal(links, []string{base}) {
+		// not an archive, so it's linked with the package name
+		to = name
+		if tmpl != base {
+			// the file name changes with the platform
+			links = []string{strings.NewReplacer("{{ .OS }}", "*", "{{ .Arch }}", "*").Replace(tmpl)}
+		}
+	}
+	if len(links) == 0 {
+		log.Printf("[WARN] %s: no executables found in %s", name, base)
+		links = []string{base}
+	}
+	fields = append(fields, yaml.MapItem{Key: "command", Value: yaml.MapSlice{
+		{Key: "link", Value: linksOf(links, to)},
+	}})
+	return Entry{Type: HTTP, Fields: fields}, nil
+}
+
+// clone clones a repository shallowly to look into files in it
+func clone(ctx context.Context, url, dir string) error {
+	_, err := git.NewRunner().Run(ctx, "clone", "--quiet", "--depth", "1", url, dir)
+	if err != nil {
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+	return nil
+}
+
+type link struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to,omitempty"`
+}
+
+type pluginBlock struct {
+	Sources []string `yaml:"sources"`
+}
+
+type commandBlock struct {
+	Link []link `yaml:"link"`
+}
+
+// linksOf returns links from files. Files in subdirectories are linked with globs
+// because directories in archives often have versions in their names
+func linksOf(files []string, to string) []link {
+	var links []link
+	for _, file := range files {
+		from := file
+		if strings.Contains(file, "/") {
+			from = "**/" + path.Base(file)
+		}
+		l := link{From: from}
+		if to != "" && to != path.Base(file) {
+			l.To = to
+		}
+		links = append(links, l)
+	}
+	return links
+}
+
+// guessBlock guesses whether files cloned in dir are a plugin or commands.
+// Plugin files by the convention of plugin managers are preferred, then
+// executables in the root and bin directory, and then other shell scripts
+func guessBlock(dir string) (string, any) {
+	glob := func(patterns ...string) []string {
+		var files []string
+		for _, pattern := range patterns {
+			matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+					files = append(files, filepath.Base(match))
+				}
+			}
+		}
+		return files
+	}
+
+	if sources := glob("*.plugin.zsh", "*.plugin.bash", "*.plugin.sh"); len(sources) > 0 {
+		return "plugin", pluginBlock{Sources: sources}
+	}
+	if files := executables(dir, false); len(files) > 0 {
+		var links []link
+		for _, file := range files {
+			links = append(links, link{From: file})
+		}
+		return "command", commandBlock{Link: links}
+	}
+	if sources := glob("*.zsh", "*.bash", "*.sh"); len(sources) > 0 {
+		return "plugin", pluginBlock{Sources: sources}
+	}
+	return "", nil
+}
+
+// platform to template names of assets
+var runtimeOS, runtimeArch = runtime.GOOS, runtime.GOARCH
+
+// files which are executable but not commands
+var (
+	ignoredExts = []string{
+		".md", ".txt", ".json", ".yml", ".yaml", ".toml",
+		".1", ".bash", ".zsh", ".fish", ".ps1", ".bat",
+	}
+	ignoredNames = []string{"readme", "license", "changelog", "makefile", "install.sh"}
+	ignoredDirs  = []string{".git", ".github", "completion", "completions", "autocomplete", "man", "doc", "docs"}
+)
+
+// executables returns paths of executables relative to dir. Without recursive,
+// only the root and bin directory are looked into (e.g. for git repositories).
+// Files with a shebang are also regarded as executables since Gist drops file modes
+func executables(dir string, recursive bool) []string {
+	var files []string
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			switch {
+			case rel == ".":
+				return nil
+			case slices.Contains(ignoredDirs, strings.ToLower(d.Name())):
+				return filepath.SkipDir
+			case !recursive && rel != "bin":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := strings.ToLower(d.Name())
+		if !d.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+			slices.Contains(ignoredExts, path.Ext(name)) ||
+			slices.ContainsFunc(ignoredNames, func(s string) bool { return strings.HasPrefix(name, s) }) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Mode().Perm()&0111 != 0 || hasShebang(p) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files
+}
+
+func hasShebang(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	b, err := bufio.NewReader(f).Peek(2)
+	return err == nil && string(b) == "#!"
+}
+
+var archiveExts = []string{".gz", ".tgz", ".bz2", ".xz", ".zst", ".zip", ".tar", ".7z"}
+
+// trimArchiveExt trims extensions of archives from a file name
+func trimArchiveExt(name string) string {
+	for slices.Contains(archiveExts, path.Ext(name)) {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	return name
+}
+
+// assetTemplate turns the name of an asset for the platform into a filename
+// template of the release. Replacements of OS and arch are also learned from
+// the other assets which can be templated in the same way
+func assetTemplate(name, tag string, assets github.Assets) (string, map[string]string) {
+	tmpl := name
+	version := ""
+	switch v := strings.TrimPrefix(tag, "v"); {
+	case tag != "" && strings.Contains(name, tag):
+		tmpl, version = strings.Replace(tmpl, tag, "{{ .Release.Tag }}", 1), tag
+	case v != tag && strings.Contains(name, v):
+		tmpl, version = strings.Replace(tmpl, v, `{{ trimprefix .Release.Tag "v" }}`, 1), v
+	}
+	tmpl, replacements := platformTemplate(tmpl)
+
+	for _, asset := range assets {
+		goos, osText, goarch, archText := github.DetectPlatform(asset.Name)
+		rendered := strings.NewReplacer(
+			"{{ .Release.Tag }}", version,
+			`{{ trimprefix .Release.Tag "v" }}`, version,
+			"{{ .OS }}", osText,
+			"{{ .Arch }}", archText,
+		).Replace(tmpl)
+		if rendered != asset.Name {
+			continue
+		}
+		for key, text := range map[string]string{goos: osText, goarch: archText} {
+			if _, ok := replacements[key]; !ok && key != "" && key != text {
+				replacements[key] = text
+			}
+		}
+	}
+	return tmpl, replacements
+}
+
+// platformTemplate replaces OS and arch of the platform in a given name with
+// template variables, and returns replacements if they're written differently
+func platformTemplate(name string) (string, map[string]string) {
+	goos, osText, goarch, archText := github.DetectPlatform(name)
+	replacements := map[string]string{}
+	for _, v := range []struct{ key, text, want, variable string }{
+		{goos, osText, runtimeOS, "{{ .OS }}"},
+		{goarch, archText, runtimeArch, "{{ .Arch }}"},
+	} {
+		if v.key == "" || v.key != v.want {
+			continue
+		}
+		name = strings.Replace(name, v.text, v.variable, 1)
+		if v.text != v.key {
+			replacements[v.key] = v.text
+		}
+	}
+	return name, replacements
+}
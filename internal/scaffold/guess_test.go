@@ -0,0 +1,281 @@
+package scaffold
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/github"
+)
+
+// setPlatform sets the platform to template names of assets during a test
+func setPlatform(t *testing.T, goos, goarch string) {
+	t.Helper()
+	origOS, origArch := runtimeOS, runtimeArch
+	runtimeOS, runtimeArch = goos, goarch
+	t.Cleanup(func() {
+		runtimeOS, runtimeArch = origOS, origArch
+	})
+}
+
+func TestAssetTemplate(t *testing.T) {
+	setPlatform(t, "darwin", "amd64")
+
+	tests := map[string]struct {
+		name             string
+		tag              string
+		assets           []string
+		want             string
+		wantReplacements map[string]string
+	}{
+		"tag and platform": {
+			name: "bat-v0.24.0-x86_64-apple-darwin.tar.gz",
+			tag:  "v0.24.0",
+			assets: []string{
+				"bat-v0.24.0-aarch64-apple-darwin.tar.gz",
+				"bat-v0.24.0-x86_64-apple-darwin.tar.gz",
+				"bat-v0.24.0-x86_64-unknown-linux-gnu.tar.gz",
+			},
+			want:             "bat-{{ .Release.Tag }}-{{ .Arch }}-apple-{{ .OS }}.tar.gz",
+			wantReplacements: map[string]string{"amd64": "x86_64", "arm64": "aarch64"},
+		},
+		"version without v": {
+			name: "fzf-0.44.1-darwin_amd64.zip",
+			tag:  "v0.44.1",
+			assets: []string{
+				"fzf-0.44.1-darwin_amd64.zip",
+				"fzf-0.44.1-linux_amd64.zip",
+			},
+			want:             `fzf-{{ trimprefix .Release.Tag "v" }}-{{ .OS }}_{{ .Arch }}.zip`,
+			wantReplacements: map[string]string{},
+		},
+		"OS written differently": {
+			name: "gh_2.40.0_macOS_amd64.zip",
+			tag:  "v2.40.0",
+			assets: []string{
+				"gh_2.40.0_linux_amd64.tar.gz",
+				"gh_2.40.0_macOS_amd64.zip",
+				"gh_2.40.0_windows_amd64.zip",
+			},
+			want:             `gh_{{ trimprefix .Release.Tag "v" }}_{{ .OS }}_{{ .Arch }}.zip`,
+			wantReplacements: map[string]string{"darwin": "macOS"},
+		},
+		"universal": {
+			name:             "tool-universal.tar.gz",
+			tag:              "nightly",
+			assets:           []string{"tool-universal.tar.gz"},
+			want:             "tool-universal.tar.gz",
+			wantReplacements: map[string]string{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var assets github.Assets
+			for _, name := range tt.assets {
+				assets = append(assets, github.Asset{Name: name})
+			}
+			got, replacements := assetTemplate(tt.name, tt.tag, assets)
+			if got != tt.want {
+				t.Errorf("assetTemplate() = %q, want %q", got, tt.want)
+			}
+			if diff := cmp.Diff(tt.wantReplacements, replacements); diff != "" {
+				t.Errorf("replacements mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGuessBlock(t *testing.T) {
+	tests := map[string]struct {
+		files   map[string]os.FileMode
+		wantKey string
+		want    any
+	}{
+		"zsh plugin": {
+			files: map[string]os.FileMode{
+				"zsh-autosuggestions.plugin.zsh": 0644,
+				"zsh-autosuggestions.zsh":        0644,
+				"install.sh":                     0755,
+			},
+			wantKey: "plugin",
+			want:    pluginBlock{Sources: []string{"zsh-autosuggestions.plugin.zsh"}},
+		},
+		"commands": {
+			files: map[string]os.FileMode{
+				"bin/tool":     0755,
+				"tool.1":       0755,
+				"README.md":    0644,
+				"test/run.sh":  0755,
+				"Makefile":     0755,
+				"lib/tool.zsh": 0644,
+			},
+			wantKey: "command",
+			want:    commandBlock{Link: []link{{From: "bin/tool"}}},
+		},
+		"shell scripts": {
+			files: map[string]os.FileMode{
+				"enhancd.sh": 0644,
+				"init.sh":    0644,
+			},
+			wantKey: "plugin",
+			want:    pluginBlock{Sources: []string{"enhancd.sh", "init.sh"}},
+		},
+		"nothing": {
+			files: map[string]os.FileMode{
+				"README.md": 0644,
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for file, mode := range tt.files {
+				path := filepath.Join(dir, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("content"), mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			key, got := guessBlock(dir)
+			if key != tt.wantKey {
+				t.Errorf("guessBlock() key = %q, want %q", key, tt.wantKey)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("guessBlock() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExecutables_shebang(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kube-context"), []byte("#!/bin/bash\necho"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes"), []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"kube-context"}, executables(dir, false)); diff != "" {
+		t.Errorf("executables() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGuess_release(t *testing.T) {
+	setPlatform(t, "linux", "amd64")
+
+	archive := tarGz(t, map[string]string{"tool-1.2.0/tool": "binary"})
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/api/v3/repos/owner/tool/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		var assets []github.AssetsResponse
+		for _, name := range []string{
+			"tool-1.2.0-Linux-x86_64.tar.gz",
+			"tool-1.2.0-Darwin-arm64.tar.gz",
+			"checksums.txt",
+		} {
+			assets = append(assets, github.AssetsResponse{Name: name, BrowserDownloadURL: server.URL + "/dl/" + name})
+		}
+		_ = json.NewEncoder(w).Encode(github.ReleaseResponse{TagName: "v1.2.0", Assets: assets})
+	})
+	mux.HandleFunc("/dl/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+
+	target := Target{Type: GitHub, Host: server.URL, Owner: "owner", Repo: "tool"}
+	got, err := Guess(context.Background(), target, WithGitHubHost(server.URL))
+	if err != nil {
+		t.Fatalf("Guess() error: %v", err)
+	}
+
+	b, err := got.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `github:
+- name: owner/tool
+  owner: owner
+  repo: tool
+  release:
+    name: tool
+    tag: v1.2.0
+    asset:
+      filename: tool-{{ trimprefix .Release.Tag "v" }}-{{ .OS }}-{{ .Arch }}.tar.gz
+      replacements:
+        amd64: x86_64
+        darwin: Darwin
+        linux: Linux
+  command:
+    link:
+    - from: "**/tool"
+`
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("Guess() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGuess_http(t *testing.T) {
+	setPlatform(t, "linux", "amd64")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "binary")
+	}))
+	defer server.Close()
+
+	target := Target{Type: HTTP, URL: server.URL + "/release/gcping_linux_amd64_latest"}
+	got, err := Guess(context.Background(), target, WithName("gcping"))
+	if err != nil {
+		t.Fatalf("Guess() error: %v", err)
+	}
+
+	want := Entry{
+		Type: HTTP,
+		Fields: yaml.MapSlice{
+			{Key: "name", Value: "gcping"},
+			{Key: "url", Value: server.URL + "/release/gcping_{{ .OS }}_{{ .Arch }}_latest"},
+			{Key: "command", Value: yaml.MapSlice{
+				{Key: "link", Value: []link{{From: "gcping_*_*_latest", To: "gcping"}}},
+			}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Guess() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,211 @@
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+
+	"github.com/babarot/afx/internal/github"
+)
+
+// Types of packages which can be scaffolded.
+// They're the same as the keys of packages in config
+const (
+	GitHub = "github"
+	Gist   = "gist"
+	HTTP   = "http"
+)
+
+// Target is what a package entry is scaffolded for
+type Target struct {
+	Type string
+
+	// Host is a host of GitHub Enterprise Server.
+	// Empty means the default host
+	Host  string
+	Owner string
+	// Repo is a repository name of GitHub, or an ID of Gist
+	Repo string
+	// URL is a URL of HTTP package
+	URL string
+}
+
+var ownerRepo = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// Parse parses a URL or owner/repo given to scaffold a package entry.
+// githubHost is a default host of GitHub packages in config, which is also
+// recognized as GitHub in addition to github.com
+func Parse(arg, githubHost string) (Target, error) {
+	if ownerRepo.MatchString(arg) && !strings.Contains(strings.Split(arg, "/")[0], ".") {
+		owner, repo, _ := strings.Cut(arg, "/")
+		return Target{Type: GitHub, Owner: owner, Repo: strings.TrimSuffix(repo, ".git")}, nil
+	}
+
+	raw := arg
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Target{}, fmt.Errorf("%s: invalid URL: %w", arg, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Target{}, fmt.Errorf("%s: unsupported scheme %q", arg, u.Scheme)
+	}
+	if u.Host == "" {
+		return Target{}, fmt.Errorf("%s: host is missing", arg)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch {
+	case u.Host == "gist."+github.DefaultHost:
+		if len(segments) != 2 {
+			return Target{}, fmt.Errorf("%s: gist URL should be gist.github.com/<owner>/<id>", arg)
+		}
+		return Target{Type: Gist, Owner: segments[0], Repo: segments[1]}, nil
+	case isGitHubHost(u.Host, githubHost) && len(segments) == 2:
+		t := Target{Type: GitHub, Owner: segments[0], Repo: strings.TrimSuffix(segments[1], ".git")}
+		if u.Host != github.DefaultHost {
+			t.Host = u.Host
+		}
+		return t, nil
+	case isGitHubHost(u.Host, githubHost) && len(segments) < 2:
+		return Target{}, fmt.Errorf("%s: repository URL should be %s/<owner>/<repo>", arg, u.Host)
+	}
+
+	if len(segments) == 0 || segments[len(segments)-1] == "" {
+		return Target{}, fmt.Errorf("%s: URL of HTTP package should point to a file", arg)
+	}
+	return Target{Type: HTTP, URL: u.String()}, nil
+}
+
+// isGitHubHost returns true if a given host is github.com or the default host in config.
+// Hosts can have schemes (e.g. http://ghe.local)
+func isGitHubHost(host, githubHost string) bool {
+	hostOf := func(host string) string {
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			return u.Host
+		}
+		return host
+	}
+	return hostOf(host) == github.DefaultHost || (githubHost != "" && hostOf(host) == hostOf(githubHost))
+}
+
+// Entry is a package entry to be added to config
+type Entry struct {
+	// Type is a key of packages in config such as "github"
+	Type string
+	// Fields are fields of the package in order to be written
+	Fields yaml.MapSlice
+}
+
+// Name returns a name of the package
+func (e Entry) Name() string {
+	for _, item := range e.Fields {
+		if item.Key == "name" {
+			name, _ := item.Value.(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// Marshal returns YAML of the entry as a list of packages of the type
+func (e Entry) Marshal() ([]byte, error) {
+	return yaml.Marshal(yaml.MapSlice{{Key: e.Type, Value: []yaml.MapSlice{e.Fields}}})
+}
+
+// Append appends an entry to a given config.
+// The config is looked into via its AST to find where the entry should be
+// inserted, so that comments and formatting of the config are kept as they are
+func Append(src []byte, e Entry) ([]byte, error) {
+	block, err := e.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(src)) == 0 {
+		return block, nil
+	}
+
+	file, err := parser.ParseBytes(src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	keys := topLevelKeys(file)
+	i := slices.IndexFunc(keys, func(key *ast.MappingValueNode) bool {
+		return key.Key.GetToken().Value == e.Type
+	})
+	if i < 0 {
+		// no packages of the type yet, so they're added at the end
+		out := append(bytes.TrimRight(src, "\n"), '\n', '\n')
+		return append(out, block...), nil
+	}
+
+	indent := 0
+	switch node := keys[i].Value.(type) {
+	case *ast.SequenceNode:
+		if node.IsFlowStyle {
+			return nil, fmt.Errorf("%s: packages written in flow style are not supported", e.Type)
+		}
+		indent = node.GetToken().Position.Column - 1
+	case *ast.NullNode:
+		// e.g. "github:" without any packages
+	default:
+		return nil, fmt.Errorf("%s: packages should be a list", e.Type)
+	}
+
+	list, err := yaml.Marshal([]yaml.MapSlice{e.Fields})
+	if err != nil {
+		return nil, err
+	}
+	var entry strings.Builder
+	for _, line := range strings.SplitAfter(string(list), "\n") {
+		if line != "" {
+			entry.WriteString(strings.Repeat(" ", indent) + line)
+		}
+	}
+
+	// the entry is inserted at the end of the list, that is before the next key,
+	// blank lines and top-level comments which belong to the next key
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+	at := len(lines)
+	if i+1 < len(keys) {
+		at = keys[i+1].Key.GetToken().Position.Line - 1
+	}
+	for at > keys[i].Key.GetToken().Position.Line {
+		line := lines[at-1]
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		at--
+	}
+
+	out := strings.Join(lines[:at], "") + entry.String() + strings.Join(lines[at:], "")
+	return []byte(out), nil
+}
+
+// topLevelKeys returns top-level keys and their values in config
+func topLevelKeys(file *ast.File) []*ast.MappingValueNode {
+	for _, doc := range file.Docs {
+		switch body := doc.Body.(type) {
+		case *ast.MappingNode:
+			return body.Values
+		case *ast.MappingValueNode:
+			return []*ast.MappingValueNode{body}
+		}
+	}
+	return nil
+}
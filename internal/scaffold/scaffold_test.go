@@ -0,0 +1,195 @@
+package scaffold
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		arg        string
+		githubHost string
+		want       Target
+		wantErr    bool
+	}{
+		"owner/repo": {
+			arg:  "junegunn/fzf",
+			want: Target{Type: GitHub, Owner: "junegunn", Repo: "fzf"},
+		},
+		"github.com": {
+			arg:  "github.com/junegunn/fzf",
+			want: Target{Type: GitHub, Owner: "junegunn", Repo: "fzf"},
+		},
+		"clone URL": {
+			arg:  "https://github.com/junegunn/fzf.git",
+			want: Target{Type: GitHub, Owner: "junegunn", Repo: "fzf"},
+		},
+		"GitHub Enterprise Server": {
+			arg:        "https://ghe.example.com/owner/repo",
+			githubHost: "https://ghe.example.com",
+			want:       Target{Type: GitHub, Host: "ghe.example.com", Owner: "owner", Repo: "repo"},
+		},
+		"gist": {
+			arg:  "https://gist.github.com/babarot/bb820b99fdba605ea4bd4fb29046ce58",
+			want: Target{Type: Gist, Owner: "babarot", Repo: "bb820b99fdba605ea4bd4fb29046ce58"},
+		},
+		"gist without owner": {
+			arg:     "https://gist.github.com/bb820b99fdba605ea4bd4fb29046ce58",
+			wantErr: true,
+		},
+		"release asset is HTTP": {
+			arg:  "https://github.com/owner/repo/releases/download/v1.0.0/tool.tar.gz",
+			want: Target{Type: HTTP, URL: "https://github.com/owner/repo/releases/download/v1.0.0/tool.tar.gz"},
+		},
+		"HTTP": {
+			arg:  "example.com/dl/tool_linux_amd64",
+			want: Target{Type: HTTP, URL: "https://example.com/dl/tool_linux_amd64"},
+		},
+		"HTTP without file": {
+			arg:     "https://example.com/",
+			wantErr: true,
+		},
+		"unsupported scheme": {
+			arg:     "ssh://git@github.com/junegunn/fzf",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(tt.arg, tt.githubHost)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	entry := Entry{
+		Type: GitHub,
+		Fields: yaml.MapSlice{
+			{Key: "name", Value: "junegunn/fzf"},
+			{Key: "owner", Value: "junegunn"},
+			{Key: "repo", Value: "fzf"},
+		},
+	}
+
+	tests := map[string]struct {
+		src     string
+		want    string
+		wantErr bool
+	}{
+		"empty": {
+			src: "",
+			want: `github:
+- name: junegunn/fzf
+  owner: junegunn
+  repo: fzf
+`,
+		},
+		"appended to the list keeping comments": {
+			src: `# CLI tools
+github:
+- name: cli/cli # GitHub CLI
+  owner: cli
+  repo: cli
+  # no release
+
+# HTTP packages
+http:
+- name: gcping
+  url: https://example.com/gcping
+`,
+			want: `# CLI tools
+github:
+- name: cli/cli # GitHub CLI
+  owner: cli
+  repo: cli
+  # no release
+- name: junegunn/fzf
+  owner: junegunn
+  repo: fzf
+
+# HTTP packages
+http:
+- name: gcping
+  url: https://example.com/gcping
+`,
+		},
+		"indented list": {
+			src: `github:
+  - name: cli/cli
+    owner: cli
+    repo: cli
+main:
+  shell: zsh
+`,
+			want: `github:
+  - name: cli/cli
+    owner: cli
+    repo: cli
+  - name: junegunn/fzf
+    owner: junegunn
+    repo: fzf
+main:
+  shell: zsh
+`,
+		},
+		"no packages of the type": {
+			src: `main:
+  shell: zsh # default
+`,
+			want: `main:
+  shell: zsh # default
+
+github:
+- name: junegunn/fzf
+  owner: junegunn
+  repo: fzf
+`,
+		},
+		"key without packages": {
+			src: `github: # tools
+http: []
+`,
+			want: `github: # tools
+- name: junegunn/fzf
+  owner: junegunn
+  repo: fzf
+http: []
+`,
+		},
+		"flow style": {
+			src:     "github: []\n",
+			wantErr: true,
+		},
+		"not a list": {
+			src:     "github: junegunn/fzf\n",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Append([]byte(tt.src), entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Append() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Append() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Fulcio certificate extensions to identify the OIDC issuer
+// https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md
+var (
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// CosignVerifier verifies signatures created by `cosign sign-blob`.
+//
+// With a public key, the signature file is the base64 signature written by
+// --output-signature. Without it (keyless), the signature file is the bundle
+// written by --bundle, whose certificate is verified against the given roots,
+// identity and issuer. Note that the inclusion in the Rekor transparency log
+// is not verified.
+type CosignVerifier struct {
+	publicKey crypto.PublicKey
+	roots     *x509.CertPool
+	identity  string
+	issuer    string
+}
+
+type cosignBundle struct {
+	Base64Signature string `json:"base64Signature"`
+	Cert            string `json:"cert"`
+	RekorBundle     struct {
+		Payload struct {
+			IntegratedTime int64 `json:"integratedTime"`
+		} `json:"Payload"`
+	} `json:"rekorBundle"`
+}
+
+// NewCosign returns a verifier for cosign signatures.
+func NewCosign(opts Options) (*CosignVerifier, error) {
+	v := &CosignVerifier{
+		identity: opts.Identity,
+		issuer:   opts.Issuer,
+	}
+
+	if opts.PublicKey != "" {
+		block, _ := pem.Decode([]byte(opts.PublicKey))
+		if block == nil {
+			return nil, errors.New("cosign: failed to decode public key PEM")
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cosign: failed to parse public key: %w", err)
+		}
+		v.publicKey = pub
+		return v, nil
+	}
+
+	if opts.Identity == "" || opts.Issuer == "" || opts.Root == "" {
+		return nil, errors.New("cosign: public-key, or identity, issuer and root for keyless are required")
+	}
+	v.roots = x509.NewCertPool()
+	if !v.roots.AppendCertsFromPEM([]byte(opts.Root)) {
+		return nil, errors.New("cosign: failed to parse root certificates")
+	}
+	return v, nil
+}
+
+func (v *CosignVerifier) Extension() string {
+	if v.publicKey != nil {
+		return ".sig"
+	}
+	return ".bundle"
+}
+
+func (v *CosignVerifier) Verify(artifact, signature []byte) error {
+	if v.publicKey != nil {
+		sig, err := decodeCosignSignature(signature)
+		if err != nil {
+			return err
+		}
+		return verifyWithKey(v.publicKey, artifact, sig)
+	}
+
+	var bundle cosignBundle
+	if err := json.Unmarshal(signature, &bundle); err != nil {
+		return fmt.Errorf("cosign: failed to parse bundle: %w", err)
+	}
+	cert, err := v.verifyCertificate(bundle)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(bundle.Base64Signature)
+	if err != nil {
+		return fmt.Errorf("cosign: failed to decode signature: %w", err)
+	}
+	return verifyWithKey(cert.PublicKey, artifact, sig)
+}
+
+func (v *CosignVerifier) verifyCertificate(bundle cosignBundle) (*x509.Certificate, error) {
+	certPEM, err := base64.StdEncoding.DecodeString(bundle.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("cosign: failed to decode certificate: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("cosign: failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cosign: failed to parse certificate: %w", err)
+	}
+
+	// Fulcio certificates are short-lived, so verify it at the time
+	// when the signature was recorded in the transparency log.
+	at := cert.NotBefore
+	if t := bundle.RekorBundle.Payload.IntegratedTime; t > 0 {
+		at = time.Unix(t, 0)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:       v.roots,
+		CurrentTime: at,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: cosign: untrusted certificate: %w", ErrInvalidSignature, err)
+	}
+
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	if !slices.Contains(identities, v.identity) {
+		return nil, fmt.Errorf("%w: cosign: identity mismatch (want %q, got %q)",
+			ErrInvalidSignature, v.identity, strings.Join(identities, ", "))
+	}
+
+	issuer := certificateIssuer(cert)
+	if issuer != v.issuer {
+		return nil, fmt.Errorf("%w: cosign: issuer mismatch (want %q, got %q)",
+			ErrInvalidSignature, v.issuer, issuer)
+	}
+
+	log.Printf("[DEBUG] cosign: certificate verified: %s (%s)", v.identity, issuer)
+	return cert, nil
+}
+
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidIssuerV1):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+func decodeCosignSignature(signature []byte) ([]byte, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return nil, fmt.Errorf("cosign: failed to decode signature: %w", err)
+	}
+	return sig, nil
+}
+
+func verifyWithKey(pub crypto.PublicKey, artifact, sig []byte) error {
+	digest := sha256.Sum256(artifact)
+
+	var ok bool
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, digest[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, artifact, sig)
+	default:
+		return fmt.Errorf("cosign: %T: unsupported public key type", pub)
+	}
+	if !ok {
+		return fmt.Errorf("%w: cosign", ErrInvalidSignature)
+	}
+	return nil
+}
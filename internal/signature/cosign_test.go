@@ -0,0 +1,184 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func ecdsaKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func cosignSign(t *testing.T, key *ecdsa.PrivateKey, artifact []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(artifact)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func publicKeyPEM(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// fulcio issues a root certificate and a short-lived code signing
+// certificate like Fulcio does for keyless signing.
+func fulcio(t *testing.T, email, issuer string, signed time.Time) (root string, leafKey *ecdsa.PrivateKey, leaf string) {
+	t.Helper()
+	rootKey := ecdsaKey(t)
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-fulcio-root"},
+		NotBefore:             signed.Add(-24 * time.Hour),
+		NotAfter:              signed.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCert, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuerValue, err := asn1.Marshal(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey = ecdsaKey(t)
+	leafTmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signed.Add(-time.Minute),
+		NotAfter:        signed.Add(9 * time.Minute),
+		EmailAddresses:  []string{email},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuerValue}},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, rootCert, &leafKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}))
+	leaf = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}))
+	return root, leafKey, leaf
+}
+
+func TestCosign_Verify_publicKey(t *testing.T) {
+	key := ecdsaKey(t)
+	artifact := []byte("artifact contents")
+	signature := base64.StdEncoding.EncodeToString(cosignSign(t, key, artifact))
+
+	v, err := NewCosign(Options{PublicKey: publicKeyPEM(t, key)})
+	if err != nil {
+		t.Fatalf("NewCosign() error: %v", err)
+	}
+	if err := v.Verify(artifact, []byte(signature+"\n")); err != nil {
+		t.Errorf("Verify() error: %v", err)
+	}
+	if err := v.Verify([]byte("tampered"), []byte(signature)); err == nil {
+		t.Error("Verify() expected error for tampered artifact")
+	}
+}
+
+func TestCosign_Verify_keyless(t *testing.T) {
+	const (
+		email  = "release@example.com"
+		issuer = "https://token.actions.githubusercontent.com"
+	)
+	// Fulcio certificate is already expired but valid when signed
+	signed := time.Now().Add(-time.Hour)
+	root, leafKey, leaf := fulcio(t, email, issuer, signed)
+	artifact := []byte("artifact contents")
+
+	bundle := func(artifact []byte) []byte {
+		var b cosignBundle
+		b.Base64Signature = base64.StdEncoding.EncodeToString(cosignSign(t, leafKey, artifact))
+		b.Cert = base64.StdEncoding.EncodeToString([]byte(leaf))
+		b.RekorBundle.Payload.IntegratedTime = signed.Unix()
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	otherRoot, _, _ := fulcio(t, email, issuer, signed)
+
+	tests := map[string]struct {
+		opts      Options
+		signature []byte
+		wantErr   bool
+	}{
+		"valid": {
+			opts:      Options{Identity: email, Issuer: issuer, Root: root},
+			signature: bundle(artifact),
+		},
+		"tampered artifact": {
+			opts:      Options{Identity: email, Issuer: issuer, Root: root},
+			signature: bundle([]byte("tampered")),
+			wantErr:   true,
+		},
+		"identity mismatch": {
+			opts:      Options{Identity: "evil@example.com", Issuer: issuer, Root: root},
+			signature: bundle(artifact),
+			wantErr:   true,
+		},
+		"issuer mismatch": {
+			opts:      Options{Identity: email, Issuer: "https://accounts.google.com", Root: root},
+			signature: bundle(artifact),
+			wantErr:   true,
+		},
+		"untrusted root": {
+			opts:      Options{Identity: email, Issuer: issuer, Root: otherRoot},
+			signature: bundle(artifact),
+			wantErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, err := NewCosign(tt.opts)
+			if err != nil {
+				t.Fatalf("NewCosign() error: %v", err)
+			}
+			err = v.Verify(artifact, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewCosign_missingOptions(t *testing.T) {
+	if _, err := NewCosign(Options{Identity: "a@example.com"}); err == nil {
+		t.Error("NewCosign() expected error without public key or keyless options")
+	}
+}
@@ -0,0 +1,44 @@
+package signature
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/openpgp"       //nolint:staticcheck // only used to verify detached signatures
+	"golang.org/x/crypto/openpgp/armor" //nolint:staticcheck // only used to verify detached signatures
+)
+
+// GPGVerifier verifies OpenPGP detached signatures (.asc or .sig).
+type GPGVerifier struct {
+	keyring openpgp.EntityList
+}
+
+// NewGPG returns a verifier with a given armored public key block.
+func NewGPG(publicKey string) (*GPGVerifier, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewBufferString(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("gpg: failed to read public key: %w", err)
+	}
+	return &GPGVerifier{keyring: keyring}, nil
+}
+
+func (v *GPGVerifier) Extension() string {
+	return ".asc"
+}
+
+func (v *GPGVerifier) Verify(artifact, signature []byte) error {
+	check := openpgp.CheckDetachedSignature
+	if block, err := armor.Decode(bytes.NewReader(signature)); err == nil && block.Type == openpgp.SignatureType {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+
+	signer, err := check(v.keyring, bytes.NewReader(artifact), bytes.NewReader(signature))
+	if err != nil {
+		return fmt.Errorf("%w: gpg: %w", ErrInvalidSignature, err)
+	}
+	for name := range signer.Identities {
+		log.Printf("[DEBUG] gpg: good signature from %q", name)
+	}
+	return nil
+}
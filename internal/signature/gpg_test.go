@@ -0,0 +1,92 @@
+package signature
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"       //nolint:staticcheck // only used to verify detached signatures
+	"golang.org/x/crypto/openpgp/armor" //nolint:staticcheck // only used to verify detached signatures
+)
+
+func gpgKey(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("afx", "test", "afx@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return entity, buf.String()
+}
+
+func TestGPG_Verify(t *testing.T) {
+	entity, publicKey := gpgKey(t)
+	other, _ := gpgKey(t)
+	artifact := []byte("artifact contents")
+
+	sign := func(signer *openpgp.Entity, armored bool) []byte {
+		var buf bytes.Buffer
+		var err error
+		if armored {
+			err = openpgp.ArmoredDetachSign(&buf, signer, bytes.NewReader(artifact), nil)
+		} else {
+			err = openpgp.DetachSign(&buf, signer, bytes.NewReader(artifact), nil)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	v, err := NewGPG(publicKey)
+	if err != nil {
+		t.Fatalf("NewGPG() error: %v", err)
+	}
+
+	tests := map[string]struct {
+		artifact  []byte
+		signature []byte
+		wantErr   bool
+	}{
+		"armored": {
+			artifact:  artifact,
+			signature: sign(entity, true),
+		},
+		"binary": {
+			artifact:  artifact,
+			signature: sign(entity, false),
+		},
+		"tampered artifact": {
+			artifact:  []byte("tampered contents"),
+			signature: sign(entity, true),
+			wantErr:   true,
+		},
+		"unknown signer": {
+			artifact:  artifact,
+			signature: sign(other, true),
+			wantErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := v.Verify(tt.artifact, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewGPG_invalidKey(t *testing.T) {
+	if _, err := NewGPG("not a key"); err == nil {
+		t.Error("NewGPG() expected error")
+	}
+}
@@ -0,0 +1,98 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisign format
+// https://jedisct1.github.io/minisign/#signature-format
+const (
+	minisignAlgorithm       = "Ed"
+	minisignHashedAlgorithm = "ED"
+	minisignKeyIDSize       = 8
+	trustedCommentPrefix    = "trusted comment: "
+)
+
+// MinisignVerifier verifies signatures created by minisign.
+type MinisignVerifier struct {
+	keyID     [minisignKeyIDSize]byte
+	publicKey ed25519.PublicKey
+}
+
+// NewMinisign returns a verifier with a given minisign public key.
+// Both the key itself ("RWQ...") and the contents of minisign.pub are accepted.
+func NewMinisign(publicKey string) (*MinisignVerifier, error) {
+	lines := strings.Split(strings.TrimSpace(publicKey), "\n")
+	key := strings.TrimSpace(lines[len(lines)-1])
+
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("minisign: failed to decode public key: %w", err)
+	}
+	if len(b) != 2+minisignKeyIDSize+ed25519.PublicKeySize || string(b[:2]) != minisignAlgorithm {
+		return nil, errors.New("minisign: invalid public key")
+	}
+
+	v := &MinisignVerifier{publicKey: ed25519.PublicKey(b[2+minisignKeyIDSize:])}
+	copy(v.keyID[:], b[2:2+minisignKeyIDSize])
+	return v, nil
+}
+
+func (v *MinisignVerifier) Extension() string {
+	return ".minisig"
+}
+
+func (v *MinisignVerifier) Verify(artifact, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) < 4 {
+		return errors.New("minisign: invalid signature file")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return fmt.Errorf("minisign: failed to decode signature: %w", err)
+	}
+	if len(sig) != 2+minisignKeyIDSize+ed25519.SignatureSize {
+		return errors.New("minisign: invalid signature")
+	}
+
+	algorithm, keyID, sig := string(sig[:2]), sig[2:2+minisignKeyIDSize], sig[2+minisignKeyIDSize:]
+	if !bytes.Equal(keyID, v.keyID[:]) {
+		return fmt.Errorf("%w: minisign: signed by another key", ErrInvalidSignature)
+	}
+
+	message := artifact
+	switch algorithm {
+	case minisignAlgorithm:
+	case minisignHashedAlgorithm:
+		sum := blake2b.Sum512(artifact)
+		message = sum[:]
+	default:
+		return fmt.Errorf("minisign: %q: unsupported algorithm", algorithm)
+	}
+	if !ed25519.Verify(v.publicKey, message, sig) {
+		return fmt.Errorf("%w: minisign", ErrInvalidSignature)
+	}
+
+	// the trusted comment is signed together with the signature
+	comment, ok := strings.CutPrefix(strings.TrimRight(lines[2], "\r"), trustedCommentPrefix)
+	if !ok {
+		return errors.New("minisign: trusted comment not found")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return fmt.Errorf("minisign: failed to decode global signature: %w", err)
+	}
+	if !ed25519.Verify(v.publicKey, append(sig, []byte(comment)...), globalSig) {
+		return fmt.Errorf("%w: minisign: invalid trusted comment", ErrInvalidSignature)
+	}
+
+	return nil
+}
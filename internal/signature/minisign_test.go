@@ -0,0 +1,126 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKey generates a minisign key pair in the same format as `minisign -G`.
+func minisignKey(t *testing.T) (string, ed25519.PrivateKey, []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte("afxkeyid")
+	b := append([]byte(minisignAlgorithm), keyID...)
+	b = append(b, pub...)
+	publicKey := "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(b)
+	return publicKey, priv, keyID
+}
+
+// minisignSign signs an artifact in the same format as `minisign -S`.
+func minisignSign(priv ed25519.PrivateKey, keyID []byte, algorithm string, artifact []byte) []byte {
+	message := artifact
+	if algorithm == minisignHashedAlgorithm {
+		sum := blake2b.Sum512(artifact)
+		message = sum[:]
+	}
+	sig := ed25519.Sign(priv, message)
+	comment := "timestamp:1700000000\tfile:tool.tar.gz"
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+
+	b := append([]byte(algorithm), keyID...)
+	b = append(b, sig...)
+	return fmt.Appendf(nil, "untrusted comment: signature from minisign secret key\n%s\n%s%s\n%s\n",
+		base64.StdEncoding.EncodeToString(b),
+		trustedCommentPrefix, comment,
+		base64.StdEncoding.EncodeToString(global))
+}
+
+func TestMinisign_Verify(t *testing.T) {
+	publicKey, priv, keyID := minisignKey(t)
+	_, otherPriv, otherKeyID := minisignKey(t)
+	artifact := []byte("artifact contents")
+
+	v, err := NewMinisign(publicKey)
+	if err != nil {
+		t.Fatalf("NewMinisign() error: %v", err)
+	}
+
+	tests := map[string]struct {
+		artifact  []byte
+		signature []byte
+		wantErr   bool
+	}{
+		"legacy": {
+			artifact:  artifact,
+			signature: minisignSign(priv, keyID, minisignAlgorithm, artifact),
+		},
+		"prehashed": {
+			artifact:  artifact,
+			signature: minisignSign(priv, keyID, minisignHashedAlgorithm, artifact),
+		},
+		"tampered artifact": {
+			artifact:  []byte("tampered contents"),
+			signature: minisignSign(priv, keyID, minisignHashedAlgorithm, artifact),
+			wantErr:   true,
+		},
+		"another key": {
+			artifact:  artifact,
+			signature: minisignSign(otherPriv, otherKeyID, minisignHashedAlgorithm, artifact),
+			wantErr:   true,
+		},
+		"broken signature": {
+			artifact:  artifact,
+			signature: []byte("untrusted comment: \nbroken\n"),
+			wantErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := v.Verify(tt.artifact, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMinisign_Verify_trustedComment(t *testing.T) {
+	publicKey, priv, keyID := minisignKey(t)
+	artifact := []byte("artifact contents")
+	signature := minisignSign(priv, keyID, minisignHashedAlgorithm, artifact)
+
+	v, err := NewMinisign(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(signature), "\n")
+	lines[2] = trustedCommentPrefix + "timestamp:0\tfile:evil"
+	err = v.Verify(artifact, []byte(strings.Join(lines, "\n")))
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestNewMinisign_invalidKey(t *testing.T) {
+	for name, key := range map[string]string{
+		"not base64": "!!!",
+		"too short":  base64.StdEncoding.EncodeToString([]byte("Edshort")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewMinisign(key); err == nil {
+				t.Error("NewMinisign() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/babarot/afx/internal/transient"
+)
+
+// Verifier verifies a detached signature of an artifact.
+type Verifier interface {
+	// Verify returns an error if the signature is not valid for the artifact
+	Verify(artifact, signature []byte) error
+	// Extension returns the conventional file extension of the signature
+	// (e.g. ".minisig") to find it next to the artifact when not specified.
+	Extension() string
+}
+
+// ErrInvalidSignature is returned when a signature doesn't match an artifact.
+var ErrInvalidSignature = errors.New("signature verification failed")
+
+// Type represents a kind of signature.
+type Type = string
+
+const (
+	Minisign Type = "minisign"
+	Cosign   Type = "cosign"
+	GPG      Type = "gpg"
+)
+
+// Options represents parameters to build a Verifier.
+type Options struct {
+	// PublicKey is a public key of the signer.
+	// minisign: base64 key (e.g. "RWQ..."), gpg: armored key block, cosign: PEM
+	PublicKey string
+
+	// Identity, Issuer and Root are used for cosign keyless signing.
+	// Identity is the email or URI in the certificate SAN, Issuer is the
+	// OIDC issuer, and Root is PEM of the trusted Fulcio root certificates.
+	Identity string
+	Issuer   string
+	Root     string
+}
+
+// New returns a Verifier of the given type.
+func New(ty Type, opts Options) (Verifier, error) {
+	switch ty {
+	case Minisign:
+		return NewMinisign(opts.PublicKey)
+	case GPG:
+		return NewGPG(opts.PublicKey)
+	case Cosign:
+		return NewCosign(opts)
+	default:
+		return nil, fmt.Errorf("%s: not supported signature type", ty)
+	}
+}
+
+// Fetch downloads a signature file from a given URL with a given client.
+func Fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	log.Printf("[DEBUG] fetching signature file: %s", url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, transient.FromStatus(resp.StatusCode, fmt.Errorf("%s: failed to get signature file: %s", url, resp.Status))
+	}
+
+	// signature files are small enough
+	const maxSize = 1 << 20
+	return io.ReadAll(io.LimitReader(resp.Body, maxSize))
+}
+
+// VerifyFile verifies the file at a given path with the signature.
+func VerifyFile(v Verifier, path string, signature []byte) error {
+	artifact, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return v.Verify(artifact, signature)
+}
@@ -0,0 +1,213 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// MaxBackups is the number of backups of state file.
+// state.json.1 is the newest one and state.json.5 is the oldest one
+const MaxBackups = 5
+
+// Backup is a backup of state file
+type Backup struct {
+	N       int
+	Path    string
+	ModTime time.Time
+	// Valid is false if the backup cannot be parsed
+	Valid bool
+}
+
+// CorruptedError is returned when state file cannot be parsed,
+// e.g. it was truncated by a crash while writing
+type CorruptedError struct {
+	Path string
+	Err  error
+	// Backup is the newest backup which can be restored. It's nil if there're no such backups
+	Backup *Backup
+}
+
+func (e *CorruptedError) Error() string {
+	msg := fmt.Sprintf("%s is corrupted: %v", e.Path, e.Err)
+	if e.Backup == nil {
+		return msg + " (no backups to restore)"
+	}
+	return fmt.Sprintf("%s, the last good backup is %s (%s), run 'afx state restore %d' to restore it",
+		msg, e.Backup.Path, e.Backup.ModTime.Local().Format("2006-01-02 15:04"), e.Backup.N)
+}
+
+func (e *CorruptedError) Unwrap() error {
+	return e.Err
+}
+
+func newCorruptedError(path string, err error) *CorruptedError {
+	corrupted := &CorruptedError{Path: path, Err: err}
+	for _, backup := range Backups(path) {
+		if backup.Valid {
+			corrupted.Backup = &backup
+			break
+		}
+	}
+	return corrupted
+}
+
+// Verify checks if a state file can be parsed. It returns *CorruptedError if not,
+// and *OutdatedError if its schema needs migrations
+func Verify(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var self Self
+	if err := json.Unmarshal(content, &self); err != nil {
+		return newCorruptedError(path, err)
+	}
+	if self.Version < Version {
+		return &OutdatedError{Path: path, From: self.Version, To: Version}
+	}
+	return nil
+}
+
+func backupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// Backups returns backups of a state file from the newest one
+func Backups(path string) []Backup {
+	var backups []Backup
+	for n := 1; n <= MaxBackups; n++ {
+		p := backupPath(path, n)
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(p)
+		backups = append(backups, Backup{
+			N:       n,
+			Path:    p,
+			ModTime: fi.ModTime(),
+			Valid:   err == nil && parse(content) == nil,
+		})
+	}
+	return backups
+}
+
+// Restore restores a state file from its backup.
+// The newest valid backup is restored if n is 0
+func Restore(path string, n int) (Backup, error) {
+	backups := Backups(path)
+	var backup *Backup
+	for i := range backups {
+		if (n == 0 && backups[i].Valid) || backups[i].N == n {
+			backup = &backups[i]
+			break
+		}
+	}
+	switch {
+	case backup == nil && n == 0:
+		return Backup{}, errors.New("no backups to restore")
+	case backup == nil:
+		return Backup{}, fmt.Errorf("%s: backup not found", backupPath(path, n))
+	case !backup.Valid:
+		return Backup{}, fmt.Errorf("%s: backup is corrupted too", backup.Path)
+	}
+
+	content, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return Backup{}, err
+	}
+	// a corrupted state is not kept as a backup
+	current, err := os.ReadFile(path)
+	rotate := err == nil && parse(current) == nil
+	return *backup, writeFile(path, content, rotate)
+}
+
+// parse checks if the content of a state file can be parsed
+func parse(content []byte) error {
+	var self Self
+	return json.Unmarshal(content, &self)
+}
+
+// writeFile writes a state file atomically. The content is written into
+// a temporary file, synced to disk and renamed to the state file, so that
+// the state file is never truncated by a crash. The previous state file is
+// kept as the newest backup if rotate is true
+func writeFile(path string, data []byte, rotate bool) error {
+	current, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	rotate = rotate && err == nil
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if rotate {
+		if err := rotateBackups(path); err != nil {
+			return fmt.Errorf("failed to back up state: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// rotateBackups shifts backups by one and keeps the current state file as
+// the newest backup. The state file itself is left as it is until replaced
+func rotateBackups(path string) error {
+	for n := MaxBackups - 1; n >= 1; n-- {
+		err := os.Rename(backupPath(path, n), backupPath(path, n+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	newest := backupPath(path, 1)
+	if err := os.Link(path, newest); err == nil {
+		return nil
+	}
+	// e.g. filesystems which don't support hard links
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(newest, content, 0644)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// some platforms don't support syncing directories
+	_ = d.Sync()
+	return nil
+}
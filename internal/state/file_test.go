@@ -0,0 +1,167 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	read := func(path string) string {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	for i := 0; i <= MaxBackups+1; i++ {
+		if err := writeFile(path, fmt.Appendf(nil, `{"resources":{},"n":%d}`, i), true); err != nil {
+			t.Fatalf("writeFile() error: %v", err)
+		}
+	}
+	// the same content doesn't push backups out
+	if err := writeFile(path, []byte(`{"resources":{},"n":6}`), true); err != nil {
+		t.Fatalf("writeFile() error: %v", err)
+	}
+
+	if got := read(path); got != `{"resources":{},"n":6}` {
+		t.Errorf("state = %s", got)
+	}
+	for n := 1; n <= MaxBackups; n++ {
+		want := fmt.Sprintf(`{"resources":{},"n":%d}`, 6-n)
+		if got := read(backupPath(path, n)); got != want {
+			t.Errorf("backup %d = %s, want %s", n, got, want)
+		}
+	}
+	if _, err := os.Stat(backupPath(path, MaxBackups+1)); !os.IsNotExist(err) {
+		t.Errorf("only %d backups should be kept: %v", MaxBackups, err)
+	}
+	matches, _ := filepath.Glob(path + ".tmp-*")
+	if len(matches) > 0 {
+		t.Errorf("temporary files should be removed: %v", matches)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	for name, content := range map[string]string{
+		path:                `{"resources":{"a`,
+		backupPath(path, 1): ``,
+		backupPath(path, 2): `{"version":1,"resources":{"github.com/b/b":{"name":"b/b"}}}`,
+		backupPath(path, 3): `{"version":1,"resources":{"github.com/c/c":{"name":"c/c"}}}`,
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := Open(path, nil)
+	var corrupted *CorruptedError
+	if !errors.As(err, &corrupted) {
+		t.Fatalf("Open() error = %v, want CorruptedError", err)
+	}
+	if corrupted.Backup == nil || corrupted.Backup.N != 2 {
+		t.Fatalf("CorruptedError.Backup = %v, want backup 2", corrupted.Backup)
+	}
+	if b, _ := os.ReadFile(path); string(b) != `{"resources":{"a` {
+		t.Fatalf("corrupted state should not be overwritten: %s", b)
+	}
+
+	var valid []bool
+	for _, backup := range Backups(path) {
+		valid = append(valid, backup.Valid)
+	}
+	if diff := cmp.Diff([]bool{false, true, true}, valid); diff != "" {
+		t.Errorf("Backups() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := Restore(path, 1); err == nil {
+		t.Error("Restore() should fail with a corrupted backup")
+	}
+	if _, err := Restore(path, 4); err == nil {
+		t.Error("Restore() should fail with a missing backup")
+	}
+	backup, err := Restore(path, 0)
+	if err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+	if backup.N != 2 {
+		t.Errorf("Restore() restored backup %d, want 2", backup.N)
+	}
+	if err := Verify(path); err != nil {
+		t.Fatalf("Verify() after restored error: %v", err)
+	}
+	// the corrupted state is not kept as a backup
+	if b, _ := os.ReadFile(backupPath(path, 1)); string(b) != `` {
+		t.Errorf("backups should not be rotated: %s", b)
+	}
+
+	if _, err := Restore(path, 3); err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+	if b, _ := os.ReadFile(backupPath(path, 1)); string(b) != `{"version":1,"resources":{"github.com/b/b":{"name":"b/b"}}}` {
+		t.Errorf("previous state should be kept as a backup: %s", b)
+	}
+	s, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.Get("c/c"); err != nil {
+		t.Errorf("Get() after restored error: %v", err)
+	}
+}
+
+func TestState_save_rotatesOncePerLock(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	before := fmt.Sprintf(`{"version":%d,"resources":{}}`, Version)
+	if err := os.WriteFile(path, []byte(before), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := s.Add(Resource{ID: name, Name: name}); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+	s.Close()
+
+	if b, _ := os.ReadFile(backupPath(path, 1)); string(b) != before {
+		t.Errorf("backup 1 should be the state before the command: %s", b)
+	}
+	if _, err := os.Stat(backupPath(path, 2)); !os.IsNotExist(err) {
+		t.Errorf("backups should be rotated once per lock: %v", err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = Open(path, nil)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer s.Close()
+	if err := s.Remove(Resource{ID: "a", Name: "a"}); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if b, _ := os.ReadFile(backupPath(path, 1)); string(b) != string(after) {
+		t.Errorf("backup 1 should be the state before the second command: %s", b)
+	}
+	if b, _ := os.ReadFile(backupPath(path, 2)); string(b) != before {
+		t.Errorf("backup 2 should be the state before the first command: %s", b)
+	}
+}
@@ -1,44 +1,277 @@
 package state
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
+// DefaultLockTimeout is how long to wait for the state lock held by another process
+const DefaultLockTimeout = 30 * time.Second
+
+// LockMode is a mode of the state lock
+type LockMode int
+
+const (
+	// NoLock doesn't lock state file. State opened with this mode cannot be saved
+	NoLock LockMode = iota
+	// SharedLock is for commands which only read state. Several processes can hold it at once
+	SharedLock
+	// ExclusiveLock is for commands which change state
+	ExclusiveLock
+)
+
+func (m LockMode) flag() int {
+	if m == SharedLock {
+		return syscall.LOCK_SH
+	}
+	return syscall.LOCK_EX
+}
+
+// Holder is a process which holds the exclusive lock of state file.
+// It's written into the lock file for diagnostics
+type Holder struct {
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("pid %d (%s) since %s", h.PID, h.Command, h.Started.Local().Format("2006-01-02 15:04:05"))
+}
+
+func (h Holder) running() bool {
+	err := syscall.Kill(h.PID, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// LockedError is returned when the state lock cannot be acquired in time
+type LockedError struct {
+	Path    string
+	Timeout time.Duration
+	// Holder is nil if the lock is held by processes which only read state
+	Holder *Holder
+}
+
+func (e *LockedError) Error() string {
+	holder := "other afx processes"
+	if e.Holder != nil {
+		holder = e.Holder.String()
+	}
+	return fmt.Sprintf("state is locked by %s (gave up after %s), "+
+		"run 'afx state unlock --force' if it's stuck", holder, e.Timeout)
+}
+
 // fileLock provides file-based locking to prevent concurrent access to state.json.
+// The lock is shared within a process, so opening state several times in a process
+// doesn't block itself
 type fileLock struct {
 	path string
 	f    *os.File
+	mode LockMode
+	refs int
+}
+
+var (
+	locksMu sync.Mutex
+	locks   = map[string]*fileLock{}
+)
+
+// lockPath returns the path of the lock file of state file
+var lockPath = func(path string) string {
+	return path + ".lock"
 }
 
 func newFileLock(path string) *fileLock {
-	return &fileLock{path: path + ".lock"}
+	return &fileLock{path: lockPath(path)}
 }
 
-func (l *fileLock) lock() error {
-	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0600)
-	if err != nil {
-		// Cannot create lock file (e.g. non-existent directory in tests)
-		return err
+// acquireLock acquires the lock of state file in path, waiting for other processes up to timeout.
+// waiting is called once when it starts waiting
+func acquireLock(path string, mode LockMode, timeout time.Duration, waiting func(*Holder)) (*fileLock, error) {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	l, ok := locks[path]
+	if !ok {
+		l = newFileLock(path)
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0600)
+		if err != nil {
+			return nil, err
+		}
+		l.f = f
 	}
-	l.f = f
-	// Use LOCK_NB (non-blocking) to avoid hanging if another process holds the lock
-	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
-		f.Close()
-		l.f = nil
-		return err
+	if l.mode < mode {
+		if err := l.wait(mode, timeout, waiting); err != nil {
+			if !ok {
+				l.f.Close()
+			}
+			return nil, err
+		}
+		l.mode = mode
+		if mode == ExclusiveLock {
+			l.writeHolder()
+		}
+	}
+	l.refs++
+	locks[path] = l
+	return l, nil
+}
+
+func (l *fileLock) wait(mode LockMode, timeout time.Duration, waiting func(*Holder)) error {
+	deadline := time.Now().Add(timeout)
+	notified := false
+	for {
+		err := syscall.Flock(int(l.f.Fd()), mode.flag()|syscall.LOCK_NB)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return err
+		}
+		holder := readHolder(l.path)
+		if holder != nil && !holder.running() {
+			// left by a process which was killed, so held by readers now
+			holder = nil
+		}
+		if !time.Now().Before(deadline) {
+			return &LockedError{Path: l.path, Timeout: timeout, Holder: holder}
+		}
+		if !notified && waiting != nil {
+			waiting(holder)
+			notified = true
+		}
+		time.Sleep(min(200*time.Millisecond, time.Until(deadline)))
+	}
+}
+
+func (l *fileLock) writeHolder() {
+	command := filepath.Base(os.Args[0])
+	if len(os.Args) > 1 {
+		command += " " + strings.Join(os.Args[1:], " ")
 	}
-	return nil
+	b, err := json.Marshal(Holder{PID: os.Getpid(), Command: command, Started: time.Now()})
+	if err != nil {
+		return
+	}
+	_ = l.f.Truncate(0)
+	_, _ = l.f.WriteAt(append(b, '\n'), 0)
 }
 
 func (l *fileLock) unlock() error {
-	if l.f == nil {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	if l.f == nil || l.refs == 0 {
+		return nil
+	}
+	l.refs--
+	if l.refs > 0 {
 		return nil
 	}
-	if err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN); err != nil {
-		return err
+	for path, lock := range locks {
+		if lock == l {
+			delete(locks, path)
+		}
+	}
+	if l.mode == ExclusiveLock {
+		// the lock file itself is kept. Removing it lets another process lock
+		// a new file while others are waiting for the removed one
+		_ = l.f.Truncate(0)
 	}
+	err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
 	l.f.Close()
-	os.Remove(l.path)
-	return nil
+	l.f = nil
+	l.mode = NoLock
+	return err
+}
+
+func readHolder(path string) *Holder {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	var holder Holder
+	if err := json.Unmarshal(b, &holder); err != nil || holder.PID == 0 {
+		return nil
+	}
+	return &holder
+}
+
+// LockInfo describes the lock file of a state file
+type LockInfo struct {
+	Path   string
+	Exists bool
+	// Held is true if any process holds the lock
+	Held bool
+	// Holder is the process which holds (or held) the exclusive lock.
+	// The lock is stale if it has a holder but is not held (e.g. afx was killed)
+	Holder *Holder
+}
+
+// Stale returns true if the lock file is left by a process which was killed
+func (i LockInfo) Stale() bool {
+	return i.Exists && !i.Held && i.Holder != nil
+}
+
+// InspectLock inspects the lock file of a given state file without holding it
+func InspectLock(path string) (LockInfo, error) {
+	info := LockInfo{Path: newFileLock(path).path}
+	f, err := os.Open(info.Path)
+	switch {
+	case os.IsNotExist(err):
+		return info, nil
+	case err != nil:
+		return info, err
+	}
+	defer f.Close()
+	info.Exists = true
+	info.Holder = readHolder(info.Path)
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			info.Held = true
+			if info.Holder != nil && !info.Holder.running() {
+				// left by a process which was killed, so held by readers now
+				info.Holder = nil
+			}
+			return info, nil
+		}
+		return info, err
+	}
+	_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return info, nil
+}
+
+// Unlock removes the lock file of a given state file. A lock held by
+// a running process is removed only if force is true, and then that
+// process and others may change state at the same time
+func Unlock(path string, force bool) (LockInfo, error) {
+	info, err := InspectLock(path)
+	if err != nil {
+		return info, err
+	}
+	if !info.Exists {
+		return info, nil
+	}
+	if info.Held && !force {
+		holder := "other afx processes"
+		if info.Holder != nil {
+			holder = info.Holder.String()
+		}
+		return info, fmt.Errorf("state is locked by %s, use --force to unlock it anyway", holder)
+	}
+	return info, os.Remove(info.Path)
 }
@@ -0,0 +1,200 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// holdLock holds the lock of state file as another process does
+func holdLock(t *testing.T, path string, how int, holder string) func() {
+	t.Helper()
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		t.Fatal(err)
+	}
+	if holder != "" {
+		if _, err := f.WriteString(holder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	l1, err := acquireLock(path, SharedLock, 0, nil)
+	if err != nil {
+		t.Fatalf("acquireLock() error: %v", err)
+	}
+	// the lock is shared in a process, so it can be upgraded by itself
+	l2, err := acquireLock(path, ExclusiveLock, 0, nil)
+	if err != nil {
+		t.Fatalf("acquireLock() in the same process error: %v", err)
+	}
+	holder := readHolder(path + ".lock")
+	if holder == nil || holder.PID != os.Getpid() {
+		t.Errorf("holder = %v, want this process", holder)
+	}
+	if err := l2.unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l1.unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := InspectLock(path); err != nil || !info.Exists || info.Held || info.Stale() {
+		t.Errorf("InspectLock() after unlocked = %+v (%v), want existing and not held", info, err)
+	}
+
+	release := holdLock(t, path, syscall.LOCK_SH, "")
+	l, err := acquireLock(path, SharedLock, 0, nil)
+	if err != nil {
+		t.Errorf("acquireLock() shared with a reader error: %v", err)
+	} else {
+		_ = l.unlock()
+	}
+	release()
+}
+
+func TestAcquireLock_held(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	release := holdLock(t, path, syscall.LOCK_EX,
+		`{"pid":`+strconv.Itoa(os.Getpid())+`,"command":"afx install","started":"2026-10-17T01:23:00Z"}`)
+
+	var waited *Holder
+	start := time.Now()
+	_, err := acquireLock(path, SharedLock, 300*time.Millisecond, func(h *Holder) { waited = h })
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("acquireLock() error = %v, want LockedError", err)
+	}
+	if time.Since(start) < 300*time.Millisecond {
+		t.Errorf("acquireLock() should wait for the timeout")
+	}
+	if locked.Holder == nil || locked.Holder.Command != "afx install" {
+		t.Errorf("LockedError.Holder = %v, want afx install", locked.Holder)
+	}
+	if waited == nil {
+		t.Errorf("waiting should be called with the holder")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		release()
+	}()
+	l, err := acquireLock(path, ExclusiveLock, 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("acquireLock() after released error: %v", err)
+	}
+	_ = l.unlock()
+}
+
+func TestUnlock(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	if _, err := Unlock(path, false); err != nil {
+		t.Fatalf("Unlock() without lock file error: %v", err)
+	}
+
+	release := holdLock(t, path, syscall.LOCK_EX, `{"pid":4194305,"command":"afx install","started":"2026-10-17T01:23:00Z"}`)
+	defer release()
+	if _, err := Unlock(path, false); err == nil {
+		t.Fatal("Unlock() should fail if the lock is held")
+	}
+	if _, err := Unlock(path, true); err != nil {
+		t.Fatalf("Unlock() with force error: %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file should be removed: %v", err)
+	}
+}
+
+func TestOpen_lock(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	release := holdLock(t, path, syscall.LOCK_SH, "")
+
+	// readers don't block each other
+	s, err := Open(path, nil, WithLock(SharedLock))
+	if err != nil {
+		t.Fatalf("Open() shared error: %v", err)
+	}
+	if err := s.Add(Resource{ID: "a", Name: "a"}); err == nil {
+		t.Error("Add() should fail without the exclusive lock")
+	}
+	s.Close()
+
+	_, err = Open(path, nil, WithLockTimeout(100*time.Millisecond, nil))
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Open() error = %v, want LockedError", err)
+	}
+	release()
+
+	s, err = Open(path, nil, WithLock(NoLock))
+	if err != nil {
+		t.Fatalf("Open() without lock error: %v", err)
+	}
+	defer s.Close()
+	if err := s.Lock(ExclusiveLock); err != nil {
+		t.Fatalf("Lock() error: %v", err)
+	}
+	if err := s.Add(Resource{ID: "a", Name: "a"}); err != nil {
+		t.Errorf("Add() with the exclusive lock error: %v", err)
+	}
+}
+
+func TestOpen_lockFailed(t *testing.T) {
+	useStateFile(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	// the lock file cannot be created under a regular file
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockPath = func(string) string { return filepath.Join(blocker, "state.json.lock") }
+
+	if _, err := Open(path, nil); err == nil {
+		t.Fatal("Open() should fail when the state lock cannot be acquired")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state should not be written without the lock: %v", err)
+	}
+}
+
+func TestOpen_lockCreatesDir(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "afx", "state.json")
+
+	s, err := Open(path, nil, WithLock(SharedLock))
+	if err != nil {
+		t.Fatalf("Open() shared error: %v", err)
+	}
+	s.Close()
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("readers should not create the state directory: %v", err)
+	}
+
+	s, err = Open(path, nil)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer s.Close()
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Errorf("lock file should be created: %v", err)
+	}
+}
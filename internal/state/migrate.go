@@ -0,0 +1,136 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Version is the schema version of state file which this afx reads and writes
+const Version = 1
+
+// Migration upgrades a state file to the schema Version from the previous one.
+// Migrate is given the state file decoded as generic JSON so that it can
+// handle fields which no longer exist in Self
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(self map[string]any) error
+}
+
+// migrations are applied in order. Adding a field to state (e.g. commit SHAs)
+// should add a migration and bump Version
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "record schema version",
+		Migrate:     func(self map[string]any) error { return nil },
+	},
+}
+
+// OutdatedError is returned when the schema of state file is older than Version
+type OutdatedError struct {
+	Path string
+	From int
+	To   int
+}
+
+func (e *OutdatedError) Error() string {
+	return fmt.Sprintf("%s: schema version %d is outdated (current: %d), run 'afx state migrate' to migrate it",
+		e.Path, e.From, e.To)
+}
+
+// MigrationPlan is a set of migrations to be applied to state file
+type MigrationPlan struct {
+	From       int
+	To         int
+	Migrations []Migration
+	// Backup is the state file kept before migrations
+	Backup string
+
+	before  map[string]any
+	after   map[string]any
+	content []byte
+}
+
+// Diff returns the changes of state file made by the migrations
+func (p MigrationPlan) Diff() string {
+	return cmp.Diff(p.before, p.after)
+}
+
+func planMigration(content []byte) (MigrationPlan, error) {
+	decode := func() (map[string]any, error) {
+		self := map[string]any{}
+		return self, json.Unmarshal(content, &self)
+	}
+	before, err := decode()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	after, err := decode()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	from := 0
+	if v, ok := after["version"].(float64); ok {
+		from = int(v)
+	}
+	// the same as Version unless migrations are replaced in testing
+	latest := migrations[len(migrations)-1].Version
+	if from > latest {
+		return MigrationPlan{}, fmt.Errorf("schema version %d is newer than supported one (%d), update afx", from, latest)
+	}
+
+	plan := MigrationPlan{From: from, To: from, before: before, after: after}
+	for _, m := range migrations {
+		if m.Version <= from {
+			continue
+		}
+		if err := m.Migrate(after); err != nil {
+			return MigrationPlan{}, fmt.Errorf("failed to migrate to schema version %d (%s): %w", m.Version, m.Description, err)
+		}
+		after["version"] = m.Version
+		plan.To = m.Version
+		plan.Migrations = append(plan.Migrations, m)
+	}
+
+	plan.content, err = json.Marshal(after)
+	return plan, err
+}
+
+// PlanMigration returns migrations which are needed for state file
+func (s *State) PlanMigration() (MigrationPlan, error) {
+	content, err := ReadStateFile(s.path)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	return planMigration(content)
+}
+
+// Migrate migrates state file to the current schema version. The state file
+// before migrations is kept as state.json.v<N> where N is its schema version
+func (s *State) Migrate() (MigrationPlan, error) {
+	content, err := ReadStateFile(s.path)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	plan, err := planMigration(content)
+	if err != nil {
+		return plan, err
+	}
+	if len(plan.Migrations) == 0 {
+		return plan, nil
+	}
+
+	plan.Backup = s.path + ".v" + strconv.Itoa(plan.From)
+	if err := SaveStateFile(plan.Backup, content, false); err != nil {
+		return plan, fmt.Errorf("failed to back up state before migration: %w", err)
+	}
+	if err := s.load(plan.content); err != nil {
+		return plan, err
+	}
+	return plan, s.save()
+}
@@ -0,0 +1,151 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migrations[%d].Version = %d, want %d", i, m.Version, i+1)
+		}
+	}
+	if got := migrations[len(migrations)-1].Version; got != Version {
+		t.Errorf("the last migration is for version %d, want %d", got, Version)
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	orig := migrations
+	defer func() { migrations = orig }()
+	migrations = append(orig, Migration{
+		Version:     2,
+		Description: "rename home to dir",
+		Migrate: func(self map[string]any) error {
+			resources, _ := self["resources"].(map[string]any)
+			for _, v := range resources {
+				resource := v.(map[string]any)
+				resource["dir"] = resource["home"]
+				delete(resource, "home")
+			}
+			return nil
+		},
+	})
+
+	tests := map[string]struct {
+		content string
+		from    int
+		to      int
+		want    string
+		wantErr bool
+	}{
+		"unversioned": {
+			content: `{"resources":{"a":{"home":"/a"}}}`,
+			from:    0,
+			to:      2,
+			want:    `{"resources":{"a":{"dir":"/a"}},"version":2}`,
+		},
+		"partially": {
+			content: `{"version":1,"resources":{"a":{"home":"/a"}}}`,
+			from:    1,
+			to:      2,
+			want:    `{"resources":{"a":{"dir":"/a"}},"version":2}`,
+		},
+		"up to date": {
+			content: `{"version":2,"resources":{"a":{"dir":"/a"}}}`,
+			from:    2,
+			to:      2,
+			want:    `{"resources":{"a":{"dir":"/a"}},"version":2}`,
+		},
+		"newer": {
+			content: `{"version":3,"resources":{}}`,
+			wantErr: true,
+		},
+		"corrupted": {
+			content: `{"version":`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			plan, err := planMigration([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planMigration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if plan.From != tt.from || plan.To != tt.to {
+				t.Errorf("planMigration() = %d -> %d, want %d -> %d", plan.From, plan.To, tt.from, tt.to)
+			}
+			if len(plan.Migrations) != tt.to-tt.from {
+				t.Errorf("planMigration() has %d migrations, want %d", len(plan.Migrations), tt.to-tt.from)
+			}
+			if string(plan.content) != tt.want {
+				t.Errorf("planMigration() content = %s, want %s", plan.content, tt.want)
+			}
+			if (plan.Diff() == "") != (tt.from == tt.to) {
+				t.Errorf("Diff() = %q", plan.Diff())
+			}
+		})
+	}
+}
+
+func TestState_Migrate(t *testing.T) {
+	useStateFile(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	legacy := `{"resources":{"github.com/a/a":{"id":"github.com/a/a","name":"a/a"}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(path, nil, WithoutMigration())
+	var outdated *OutdatedError
+	if !errors.As(err, &outdated) || outdated.From != 0 || outdated.To != Version {
+		t.Fatalf("Open() error = %v, want OutdatedError", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != legacy {
+		t.Fatalf("outdated state should not be saved without migration: %s", b)
+	}
+
+	plan, err := s.PlanMigration()
+	if err != nil {
+		t.Fatalf("PlanMigration() error: %v", err)
+	}
+	if !strings.Contains(plan.Diff(), "version") {
+		t.Errorf("Diff() should show the schema version: %s", plan.Diff())
+	}
+
+	if _, err := s.Migrate(); err != nil {
+		t.Fatalf("Migrate() error: %v", err)
+	}
+	s.Close()
+	if b, _ := os.ReadFile(path + ".v0"); string(b) != legacy {
+		t.Errorf("state before migration should be kept: %s", b)
+	}
+
+	s, err = Open(path, nil, WithoutMigration())
+	if err != nil {
+		t.Fatalf("Open() after migrated error: %v", err)
+	}
+	defer s.Close()
+	if s.Version != Version {
+		t.Errorf("Version = %d, want %d", s.Version, Version)
+	}
+	var self Self
+	b, _ := os.ReadFile(path)
+	if err := json.Unmarshal(b, &self); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a/a"}, Keys(Slice(self.Resources))); diff != "" {
+		t.Errorf("resources mismatch (-want +got):\n%s", diff)
+	}
+}
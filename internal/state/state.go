@@ -1,15 +1,20 @@
 package state
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+
+	"github.com/babarot/afx/internal/lock"
 )
 
 // ID is to prevent from detecting state changes unexpected by package name changing
@@ -18,6 +23,8 @@ import (
 type ID = string
 
 type Self struct {
+	// Version is the schema version of state file. See migrations
+	Version   int             `json:"version"`
 	Resources map[ID]Resource `json:"resources"`
 }
 
@@ -30,6 +37,17 @@ type State struct {
 	mu       sync.RWMutex
 	flock    *fileLock
 
+	lockMode    LockMode
+	lockTimeout time.Duration
+	lockWaiting func(*Holder)
+	noMigration bool
+
+	// saved is the content of state file last read or written
+	saved []byte
+	// rotated is true once backups are rotated in a lock session, so that
+	// state.json.1 is always the state before the command ran
+	rotated bool
+
 	// No record in state file
 	Additions []Resource
 
@@ -57,6 +75,29 @@ type Resource struct {
 	Type    string   `json:"type"`
 	Version string   `json:"version"`
 	Paths   []string `json:"paths"`
+
+	// Generations are previous installations kept to roll back to, newest first
+	Generations []Generation `json:"generations,omitempty"`
+}
+
+// Generation is a previous installation of a resource
+type Generation struct {
+	// Name identifies the generation. It's the version if the resource has it,
+	// otherwise the commit or when it was replaced
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Dir is where the home of the resource is kept
+	Dir   string   `json:"dir"`
+	Paths []string `json:"paths"`
+	Links []Link   `json:"links,omitempty"`
+	// Lock is the lock entry of the installation
+	Lock *lock.Package `json:"lock,omitempty"`
+}
+
+// Link is a symlink created for a resource
+type Link struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 func (e Resource) GetResource() Resource {
@@ -64,15 +105,18 @@ func (e Resource) GetResource() Resource {
 }
 
 func (e Resource) exists() bool {
-	if len(e.Paths) == 0 {
-		return false
-	}
+	return len(e.Paths) > 0 && len(e.Missing()) == 0
+}
+
+// Missing returns the paths of the resource which don't exist on the filesystem.
+func (e Resource) Missing() []string {
+	var paths []string
 	for _, path := range e.Paths {
 		if !exists(path) {
-			return false
+			paths = append(paths, path)
 		}
 	}
-	return true
+	return paths
 }
 
 var exists = func(path string) bool {
@@ -82,11 +126,15 @@ var exists = func(path string) bool {
 	return true
 }
 
-var ReadStateFile = func(filename string) ([]byte, error) {
+var ReadStateFile = readStateFile
+
+var SaveStateFile = saveStateFile
+
+func readStateFile(filename string) ([]byte, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		// return empty json contents if state.json does not exist
-		return []byte(`{"resources":{}}`), nil
+		return fmt.Appendf(nil, `{"version":%d,"resources":{}}`, Version), nil
 	}
 	defer f.Close()
 
@@ -98,8 +146,8 @@ var ReadStateFile = func(filename string) ([]byte, error) {
 	return data, nil
 }
 
-var SaveStateFile = func(filename string) (io.Writer, error) {
-	return os.Create(filename)
+func saveStateFile(filename string, data []byte, rotate bool) error {
+	return writeFile(filename, data, rotate)
 }
 
 func add(r Resource, s *State) {
@@ -124,20 +172,35 @@ func remove(r Resource, s *State) {
 }
 
 func update(r Resource, s *State) {
-	_, ok := s.Resources[r.ID]
+	current, ok := s.Resources[r.ID]
 	if !ok {
 		return
 	}
+	if r.Generations == nil {
+		// resources of packages don't know generations
+		r.Generations = current.Generations
+	}
 	log.Printf("[DEBUG] %s: updated in state", r.Name)
 	s.Resources[r.ID] = r
 }
 
 func (s *State) save() error {
-	f, err := SaveStateFile(s.path)
-	if err != nil {
+	if s.lockMode != ExclusiveLock {
+		return errors.New("state cannot be changed without the exclusive lock")
+	}
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(s.Self); err != nil {
 		return err
 	}
-	return json.NewEncoder(f).Encode(s.Self)
+	if bytes.Equal(b.Bytes(), s.saved) {
+		return nil
+	}
+	if err := SaveStateFile(s.path, b.Bytes(), !s.rotated); err != nil {
+		return err
+	}
+	s.saved = b.Bytes()
+	s.rotated = true
+	return nil
 }
 
 func contains(resources []Resource, name string) bool {
@@ -161,6 +224,11 @@ func (s *State) listChanges() []Resource {
 			log.Printf("[TRACE] skip; %s is not found in packages", resource.Name)
 			continue
 		}
+		if r.Version == "" {
+			// e.g. semver constraint which is not resolved yet
+			log.Printf("[TRACE] skip; version of %s is not resolved", resource.Name)
+			continue
+		}
 		if resource.Version != r.Version {
 			resources = append(resources, resource)
 		}
@@ -227,18 +295,46 @@ func Keys(resources []Resource) []string {
 	return keys
 }
 
-func Open(path string, resourcers []Resourcer) (*State, error) {
-	fl := newFileLock(path)
-	if err := fl.lock(); err != nil {
-		log.Printf("[WARN] failed to acquire state lock: %v", err)
+// Option configures State opened by Open
+type Option func(s *State)
+
+// WithoutMigration makes Open return *OutdatedError instead of migrating
+// state file, so that migrations can be reviewed before applied
+func WithoutMigration() Option {
+	return func(s *State) {
+		s.noMigration = true
+	}
+}
+
+// WithLock sets a mode of the state lock acquired by Open. It defaults to ExclusiveLock.
+// With NoLock, state is only read (it's safe as state file is replaced atomically)
+// and the lock can be acquired later by Lock
+func WithLock(mode LockMode) Option {
+	return func(s *State) {
+		s.lockMode = mode
 	}
+}
 
+// WithLockTimeout sets how long to wait for the state lock held by another process.
+// waiting is called when it starts waiting
+func WithLockTimeout(timeout time.Duration, waiting func(*Holder)) Option {
+	return func(s *State) {
+		s.lockTimeout = timeout
+		s.lockWaiting = waiting
+	}
+}
+
+func Open(path string, resourcers []Resourcer, opts ...Option) (*State, error) {
 	s := State{
-		Self:     Self{Resources: map[ID]Resource{}},
-		path:     path,
-		packages: map[ID]Resource{},
-		mu:       sync.RWMutex{},
-		flock:    fl,
+		Self:        Self{Version: Version, Resources: map[ID]Resource{}},
+		path:        path,
+		packages:    map[ID]Resource{},
+		mu:          sync.RWMutex{},
+		lockMode:    ExclusiveLock,
+		lockTimeout: DefaultLockTimeout,
+	}
+	for _, opt := range opts {
+		opt(&s)
 	}
 
 	for _, resourcer := range resourcers {
@@ -250,36 +346,110 @@ func Open(path string, resourcers []Resourcer) (*State, error) {
 		s.packages[resource.ID] = resource
 	}
 
-	content, err := ReadStateFile(path)
+	if s.lockMode == NoLock {
+		return &s, s.read()
+	}
+	mode := s.lockMode
+	s.lockMode = NoLock
+	return &s, s.Lock(mode)
+}
+
+// Lock acquires the state lock and reads state file again as it may be
+// changed by other processes until the lock is acquired
+func (s *State) Lock(mode LockMode) error {
+	dir := filepath.Dir(s.path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) && mode == SharedLock {
+		// nothing is installed yet, so there's no state to be read
+		s.lockMode = mode
+		return s.read()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	fl, err := acquireLock(s.path, mode, s.lockTimeout, s.lockWaiting)
+	if err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			return err
+		}
+		return fmt.Errorf("failed to acquire state lock: %w", err)
+	}
+	if s.flock != nil {
+		// the lock is shared in a process, so this just drops the reference
+		_ = s.flock.unlock()
+	}
+	s.flock = fl
+	s.lockMode = mode
+	s.rotated = false
+	return s.read()
+}
+
+// read reads state file and migrates it if needed. State is saved
+// only if it's locked exclusively
+func (s *State) read() error {
+	content, err := ReadStateFile(s.path)
 	if err != nil {
-		return &s, err
+		return err
 	}
+	s.saved = content
 
-	if err := json.Unmarshal(content, &s.Self); err != nil {
-		return &s, err
+	if err := s.load(content); err != nil {
+		var outdated *OutdatedError
+		if !errors.As(err, &outdated) || s.noMigration || s.lockMode != ExclusiveLock {
+			// never save over a state which cannot be read as it is
+			return err
+		}
+		plan, err := s.Migrate()
+		if err != nil {
+			return fmt.Errorf("failed to migrate state: %w", err)
+		}
+		log.Printf("[INFO] migrated state schema from %d to %d", plan.From, plan.To)
 	}
 
+	if s.lockMode != ExclusiveLock {
+		return nil
+	}
+	return s.save()
+}
+
+// load parses the content of state file and detects changes from packages
+func (s *State) load(content []byte) error {
+	var self Self
+	if err := json.Unmarshal(content, &self); err != nil {
+		return newCorruptedError(s.path, err)
+	}
+	switch {
+	case self.Version > Version:
+		return fmt.Errorf("%s: schema version %d is newer than supported one (%d), update afx",
+			s.path, self.Version, Version)
+	case self.Version < Version:
+		return &OutdatedError{Path: s.path, From: self.Version, To: Version}
+	}
+	if self.Resources == nil {
+		self.Resources = map[ID]Resource{}
+	}
+	s.Self = self
+
 	s.Additions = append(s.listAdditions(), s.listReadditions()...)
 	s.Deletions = s.listDeletions()
 	s.Changes = s.listChanges()
 	s.NoChanges = s.listNoChanges()
 
-	// TODO: maybe better to separate to dedicated command etc?
-	// this is needed to update state schema (e.g. adding new field)
-	// but maybe it's danger a bit
-	// so may be better to separate to dedicated command like `afx state refresh` etc
-	// to run state operation explicitly
+	// this syncs the fields of resources which are derived from config.
+	// changes of state schema itself are done by migrations
 	if err := s.Refresh(); err != nil {
 		log.Printf("[ERROR] there're some states or packages which needs operations: %v", err)
 	}
-
-	return &s, s.save()
+	return nil
 }
 
 // Close releases the file lock on the state file.
 func (s *State) Close() error {
+	s.lockMode = NoLock
 	if s.flock != nil {
-		return s.flock.unlock()
+		fl := s.flock
+		s.flock = nil
+		return fl.unlock()
 	}
 	return nil
 }
@@ -348,9 +518,14 @@ func (s *State) Refresh() error {
 	for _, resource := range s.packages {
 		v1 := s.Resources[resource.ID]
 		v2 := resource
+		if v2.Version == "" {
+			// keep the version resolved when installed
+			v2.Version = v1.Version
+		}
+		v2.Generations = v1.Generations
 		if diff := cmp.Diff(v1, v2); diff != "" {
 			log.Printf("[DEBUG] refresh state to %s", diff)
-			update(resource, s)
+			update(v2, s)
 			done = true
 		}
 	}
@@ -378,11 +553,49 @@ func Slice(m map[ID]Resource) []Resource {
 	return resources
 }
 
+// Installed returns the resource recorded in state file by its ID
+func (s *State) Installed(id ID) (Resource, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resource, ok := s.Resources[id]
+	return resource, ok
+}
+
+// Get returns a resource recorded in state file by its name or ID
 func (s *State) Get(name string) (Resource, error) {
 	for _, resource := range s.Resources {
 		if resource.Name == name {
 			return resource, nil
 		}
 	}
+	if resource, ok := s.Resources[name]; ok {
+		return resource, nil
+	}
 	return Resource{}, fmt.Errorf("%s: not found in state file", name)
 }
+
+// Move records a resource as another one without reinstalling it, e.g. when
+// a package is changed to be installed from GitHub release, which changes its ID.
+// The version installed and generations are taken over unless to has them
+func (s *State) Move(from ID, to Resource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.Resources[from]
+	if !ok {
+		return fmt.Errorf("%s: not found in state file", from)
+	}
+	if _, ok := s.Resources[to.ID]; ok && to.ID != from {
+		return fmt.Errorf("%s: already exists in state file", to.ID)
+	}
+	if to.Version == "" {
+		to.Version = current.Version
+	}
+	if to.Generations == nil {
+		to.Generations = current.Generations
+	}
+	delete(s.Resources, from)
+	add(to, s)
+	log.Printf("[DEBUG] %s: moved to %s in state", from, to.ID)
+	return s.save()
+}
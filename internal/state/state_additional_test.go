@@ -8,7 +8,7 @@ import (
 )
 
 func TestState_Add(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{"resources":{}}`,
 	})
 
@@ -37,7 +37,7 @@ func TestState_Add(t *testing.T) {
 }
 
 func TestState_Remove(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{
   "resources": {
     "github.com/babarot/enhancd": {
@@ -74,7 +74,7 @@ func TestState_Remove(t *testing.T) {
 }
 
 func TestState_Update(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{
   "resources": {
     "github.com/release/stedolan/jq": {
@@ -111,8 +111,60 @@ func TestState_Update(t *testing.T) {
 	}
 }
 
+func TestState_Update_keepsGenerations(t *testing.T) {
+	stubState(t, map[string]string{
+		"state.json": `{
+  "resources": {
+    "github.com/release/stedolan/jq": {
+      "id": "github.com/release/stedolan/jq",
+      "name": "stedolan/jq",
+      "home": "/home/.afx/github.com/stedolan/jq",
+      "type": "GitHub Release",
+      "version": "jq-1.7",
+      "paths": [],
+      "generations": [
+        {"name": "jq-1.6", "version": "jq-1.6", "dir": "/home/.afx/generations/github.com/release/stedolan/jq/jq-1.6", "paths": []}
+      ]
+    }
+  }
+}`,
+	})
+
+	state, err := Open("state.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := state.Resources["github.com/release/stedolan/jq"].Generations
+
+	// resources of packages don't have generations
+	updated := Resource{
+		ID:      "github.com/release/stedolan/jq",
+		Name:    "stedolan/jq",
+		Home:    "/home/.afx/github.com/stedolan/jq",
+		Type:    "GitHub Release",
+		Version: "jq-1.7",
+		Paths:   []string{"/home/.afx/github.com/stedolan/jq"},
+	}
+	if err := state.Update(testPackage{r: updated}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := state.Installed(updated.ID)
+	if diff := cmp.Diff(want, got.Generations); diff != "" {
+		t.Errorf("Update() generations mismatch (-want +got):\n%s", diff)
+	}
+
+	// generations are replaced if given
+	updated.Generations = []Generation{}
+	if err := state.Update(testPackage{r: updated}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := state.Installed(updated.ID); len(got.Generations) != 0 {
+		t.Errorf("Update() generations = %v, want empty", got.Generations)
+	}
+}
+
 func TestState_Update_nonexistent(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{"resources":{}}`,
 	})
 
@@ -135,7 +187,7 @@ func TestState_Update_nonexistent(t *testing.T) {
 }
 
 func TestState_Get(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{
   "resources": {
     "github.com/babarot/enhancd": {
@@ -165,6 +217,16 @@ func TestState_Get(t *testing.T) {
 		}
 	})
 
+	t.Run("found by id", func(t *testing.T) {
+		got, err := state.Get("github.com/babarot/enhancd")
+		if err != nil {
+			t.Fatalf("Get() unexpected error: %v", err)
+		}
+		if got.Name != "babarot/enhancd" {
+			t.Errorf("Get() name = %q, want %q", got.Name, "babarot/enhancd")
+		}
+	})
+
 	t.Run("not found", func(t *testing.T) {
 		_, err := state.Get("nonexistent/pkg")
 		if err == nil {
@@ -173,8 +235,96 @@ func TestState_Get(t *testing.T) {
 	})
 }
 
+func TestState_Move(t *testing.T) {
+	stubState(t, map[string]string{
+		"state.json": `{
+  "resources": {
+    "github.com/stedolan/jq": {
+      "id": "github.com/stedolan/jq",
+      "name": "stedolan/jq",
+      "home": "/home/.afx/github.com/stedolan/jq",
+      "type": "GitHub",
+      "version": "jq-1.6",
+      "paths": [],
+      "generations": [
+        {"name": "jq-1.5", "version": "jq-1.5", "dir": "/home/.afx/generations/github.com/stedolan/jq/jq-1.5", "paths": []}
+      ]
+    },
+    "github.com/babarot/enhancd": {
+      "id": "github.com/babarot/enhancd",
+      "name": "babarot/enhancd",
+      "home": "/home/.afx/github.com/babarot/enhancd",
+      "type": "GitHub",
+      "version": "",
+      "paths": []
+    }
+  }
+}`,
+	})
+
+	state, err := Open("state.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := Resource{
+		ID:    "github.com/release/stedolan/jq",
+		Name:  "stedolan/jq",
+		Home:  "/home/.afx/github.com/stedolan/jq",
+		Type:  "GitHub Release",
+		Paths: []string{},
+	}
+
+	tests := map[string]struct {
+		from    ID
+		to      Resource
+		wantErr bool
+	}{
+		"not found": {
+			from:    "github.com/nonexistent/pkg",
+			to:      release,
+			wantErr: true,
+		},
+		"already exists": {
+			from:    "github.com/stedolan/jq",
+			to:      Resource{ID: "github.com/babarot/enhancd", Name: "babarot/enhancd"},
+			wantErr: true,
+		},
+		"moved": {
+			from: "github.com/stedolan/jq",
+			to:   release,
+		},
+	}
+
+	for _, name := range []string{"not found", "already exists", "moved"} {
+		tt := tests[name]
+		t.Run(name, func(t *testing.T) {
+			err := state.Move(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Move() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, ok := state.Installed("github.com/stedolan/jq"); ok {
+		t.Error("Move() should remove the old resource")
+	}
+	got, ok := state.Installed(release.ID)
+	if !ok {
+		t.Fatal("Move() should add the new resource")
+	}
+	want := release
+	want.Version = "jq-1.6"
+	want.Generations = []Generation{
+		{Name: "jq-1.5", Version: "jq-1.5", Dir: "/home/.afx/generations/github.com/stedolan/jq/jq-1.5", Paths: []string{}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Move() mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestState_New(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{"resources":{}}`,
 	})
 
@@ -208,7 +358,7 @@ func TestState_New(t *testing.T) {
 
 func TestState_Refresh(t *testing.T) {
 	t.Run("no changes updates schema", func(t *testing.T) {
-		stubState(map[string]string{
+		stubState(t, map[string]string{
 			"state.json": `{"resources":{}}`,
 		})
 
@@ -250,8 +400,42 @@ func TestState_Refresh(t *testing.T) {
 		}
 	})
 
+	t.Run("unresolved version is kept", func(t *testing.T) {
+		stubState(t, map[string]string{
+			"state.json": `{"resources":{}}`,
+		})
+
+		installed := Resource{
+			ID:      "github.com/release/cli/cli",
+			Name:    "cli/cli",
+			Type:    "GitHub Release",
+			Version: "v2.40.1",
+		}
+		desired := installed
+		desired.Version = ""
+		desired.Paths = []string{"/home/.afx/github.com/cli/cli"}
+
+		state := &State{
+			Self:     Self{Resources: map[ID]Resource{installed.ID: installed}},
+			packages: map[ID]Resource{desired.ID: desired},
+			path:     "state.json",
+		}
+
+		if err := state.Refresh(); err != nil {
+			t.Fatalf("Refresh() error: %v", err)
+		}
+
+		got := state.Resources[installed.ID]
+		if got.Version != "v2.40.1" {
+			t.Errorf("Refresh() version = %q, want %q", got.Version, "v2.40.1")
+		}
+		if diff := cmp.Diff(desired.Paths, got.Paths); diff != "" {
+			t.Errorf("Refresh() did not update paths (-want +got):\n%s", diff)
+		}
+	})
+
 	t.Run("with changes returns error", func(t *testing.T) {
-		stubState(map[string]string{
+		stubState(t, map[string]string{
 			"state.json": `{"resources":{}}`,
 		})
 
@@ -427,7 +611,7 @@ func Test_add_remove_update(t *testing.T) {
 }
 
 func TestOpen_localPackageSkipped(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{"resources":{}}`,
 	})
 
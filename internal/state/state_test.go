@@ -19,7 +19,7 @@ func init() {
 }
 
 func TestOpen(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"empty.json": "{}",
 		"state.json": `
 {
@@ -45,7 +45,7 @@ func TestOpen(t *testing.T) {
 	}{
 		"Empty": {
 			filename: "empty.json",
-			state:    &State{Self: Self{Resources: map[string]Resource{}}, path: "empty.json"},
+			state:    &State{Self: Self{Version: Version, Resources: map[string]Resource{}}, path: "empty.json"},
 		},
 		"Open": {
 			filename: "state.json",
@@ -53,6 +53,8 @@ func TestOpen(t *testing.T) {
 				path:     "state.json",
 				packages: nil,
 				Self: Self{
+					// migrated from the unversioned state
+					Version: Version,
 					Resources: map[ID]Resource{
 						"github.com/babarot/enhancd": {
 							ID:      "github.com/babarot/enhancd",
@@ -106,7 +108,7 @@ func TestOpen(t *testing.T) {
 }
 
 func TestList(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `
 {
   "resources": {
@@ -167,7 +169,7 @@ func TestList(t *testing.T) {
 }
 
 func Test_listChanges(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `
 {
   "resources": {
@@ -241,6 +243,24 @@ func Test_listChanges(t *testing.T) {
 			}),
 			resources: nil,
 		},
+		"NotResolved": {
+			filename: "state.json",
+			pkgs: stubPackages([]Resource{
+				{
+					ID:      "github.com/release/stedolan/jq",
+					Name:    "stedolan/jq",
+					Home:    "/Users/babarot/.afx/github.com/stedolan/jq",
+					Type:    "GitHub Release",
+					Version: "",
+					Paths: []string{
+						"/Users/babarot/.afx/github.com/stedolan/jq",
+						"/Users/babarot/.afx/github.com/stedolan/jq/jq",
+						"/Users/babarot/bin/jq",
+					},
+				},
+			}),
+			resources: nil,
+		},
 	}
 
 	for name, tc := range testCases {
@@ -259,7 +279,7 @@ func Test_listChanges(t *testing.T) {
 }
 
 func Test_listNoChanges(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `
 {
   "resources": {
@@ -348,7 +368,7 @@ func Test_listNoChanges(t *testing.T) {
 }
 
 func Test_listAdditions(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `{"resources":{}}`,
 	})
 
@@ -416,7 +436,7 @@ func Test_listReadditions(t *testing.T) {
 }
 
 func Test_listDeletions(t *testing.T) {
-	stubState(map[string]string{
+	stubState(t, map[string]string{
 		"state.json": `
 {
   "resources": {
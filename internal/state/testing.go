@@ -1,14 +1,23 @@
 package state
 
 import (
-	"bytes"
-	"io"
 	"os"
+	"path/filepath"
 )
 
-func stubState(m map[string]string) func() {
+func stubState(t interface {
+	TempDir() string
+	Cleanup(func())
+}, m map[string]string) {
 	origRead := ReadStateFile
 	origSave := SaveStateFile
+	origLockPath := lockPath
+	// lock files are created in a temporary directory
+	// instead of next to the stubbed state files
+	dir := t.TempDir()
+	lockPath = func(path string) string {
+		return filepath.Join(dir, filepath.Base(path)+".lock")
+	}
 	ReadStateFile = func(filename string) ([]byte, error) {
 		content, ok := m[filename]
 		if !ok {
@@ -16,16 +25,32 @@ func stubState(m map[string]string) func() {
 		}
 		return []byte(content), nil
 	}
-	SaveStateFile = func(fn string) (io.Writer, error) {
-		// override with this buffer to prevent creating
+	SaveStateFile = func(fn string, data []byte, rotate bool) error {
+		// do nothing to prevent creating
 		// actual files in testing
-		var b bytes.Buffer
-		return &b, nil
+		return nil
 	}
-	return func() {
+	t.Cleanup(func() {
 		ReadStateFile = origRead
 		SaveStateFile = origSave
-	}
+		lockPath = origLockPath
+	})
+}
+
+// useStateFile makes tests read and write actual state files
+// even after other tests stubbed them
+func useStateFile(t interface{ Cleanup(func()) }) {
+	origRead := ReadStateFile
+	origSave := SaveStateFile
+	origLockPath := lockPath
+	ReadStateFile = readStateFile
+	SaveStateFile = saveStateFile
+	lockPath = func(path string) string { return path + ".lock" }
+	t.Cleanup(func() {
+		ReadStateFile = origRead
+		SaveStateFile = origSave
+		lockPath = origLockPath
+	})
 }
 
 type testConfig struct {
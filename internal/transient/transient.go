@@ -0,0 +1,79 @@
+// Package transient classifies errors which may succeed by retrying,
+// e.g. HTTP 503 or a connection reset by a flaky network.
+package transient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// transient is implemented by errors which know if they're transient
+type transient interface {
+	Transient() bool
+}
+
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string   { return e.err.Error() }
+func (e *transientError) Unwrap() error   { return e.err }
+func (e *transientError) Transient() bool { return true }
+
+// New marks err as transient. It returns nil if err is nil
+func New(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// Status returns true if a response with the status code may succeed by retrying,
+// that is 429 Too Many Requests or 5xx server errors
+func Status(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// FromStatus marks err as transient if it's caused by a response with
+// the status code which may succeed by retrying. See Status
+func FromStatus(code int, err error) error {
+	if Status(code) {
+		return New(err)
+	}
+	return err
+}
+
+// Is returns true if err may succeed by retrying. It's the case if err is
+// marked by New or it knows it's transient, or it's a network error such as
+// a timeout or a reset connection. Canceled or timed out contexts are not
+func Is(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var t transient
+	if errors.As(err, &t) {
+		return t.Transient()
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
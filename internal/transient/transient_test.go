@@ -0,0 +1,88 @@
+package transient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+type knownError struct{ transient bool }
+
+func (e knownError) Error() string   { return "known" }
+func (e knownError) Transient() bool { return e.transient }
+
+func TestIs(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil": {
+			err:  nil,
+			want: false,
+		},
+		"marked": {
+			err:  fmt.Errorf("download: %w", New(errors.New("503 Service Unavailable"))),
+			want: true,
+		},
+		"not marked": {
+			err:  errors.New("404 Not Found"),
+			want: false,
+		},
+		"knows transient": {
+			err:  fmt.Errorf("clone: %w", knownError{transient: true}),
+			want: true,
+		},
+		"knows not transient": {
+			err:  knownError{transient: false},
+			want: false,
+		},
+		"connection reset": {
+			err:  &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET},
+			want: true,
+		},
+		"unexpected EOF": {
+			err:  fmt.Errorf("read body: %w", io.ErrUnexpectedEOF),
+			want: true,
+		},
+		"dns not found": {
+			err:  &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true},
+			want: false,
+		},
+		"canceled": {
+			err:  New(context.Canceled),
+			want: false,
+		},
+		"deadline exceeded": {
+			err:  fmt.Errorf("download: %w", context.DeadlineExceeded),
+			want: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Is(tt.err); got != tt.want {
+				t.Errorf("Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := map[int]bool{
+		200: false,
+		404: false,
+		429: true,
+		500: true,
+		502: true,
+		503: true,
+	}
+	for code, want := range tests {
+		if got := Status(code); got != want {
+			t.Errorf("Status(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -62,7 +62,7 @@ func getLatestReleaseInfo(client *github.Client, repo string) (*ReleaseInfo, err
 
 	log.Printf("[DEBUG] call GitHub Release API to get release info")
 
-	api := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
+	api := fmt.Sprintf("%s/repos/%s/releases/latest", github.APIURL(github.DefaultHost), repo)
 	err := client.REST(http.MethodGet, api, nil, &latestRelease)
 	if err != nil {
 		return nil, err